@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/the-code-genin/golang_integration_testing/ratelimit"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+)
+
+// Config holds the settings loaded by loadConfig, each named by its environment variable.
+// Fields tagged secret are redacted when the config is written out, and fields tagged
+// reload are applied to the running server when the config is reloaded.
+type Config struct {
+	// One of debug, info, warn or error
+	LogLevel slog.Level `env:"LOG_LEVEL" default:"info" reload:"true"`
+
+	// Connects to this URL if set, instead of one built from the Postgres variables below
+	DatabaseURL      string `env:"DATABASE_URL" secret:"true"`
+	PostgresUser     string `env:"POSTGRES_USER" default:"postgres"`
+	PostgresPassword string `env:"POSTGRES_PASSWORD" default:"password" secret:"true"`
+	PostgresHost     string `env:"POSTGRES_HOST" default:"localhost"`
+	PostgresPort     string `env:"POSTGRES_PORT" default:"5432"`
+	PostgresDB       string `env:"POSTGRES_DB" default:"postgres"`
+	// One of disable, allow, prefer, require, verify-ca or verify-full
+	PostgresSSLMode string `env:"POSTGRES_SSLMODE" default:"disable"`
+	// The CAs to verify the server's certificate with, instead of the system's
+	PostgresSSLRootCert string `env:"POSTGRES_SSLROOTCERT"`
+
+	// Pool settings, applied whether connecting to DATABASE_URL or not
+	DBMaxConns          int32         `env:"DB_MAX_CONNS" default:"10"`
+	DBMinConns          int32         `env:"DB_MIN_CONNS" default:"0"`
+	DBMaxConnLifetime   time.Duration `env:"DB_MAX_CONN_LIFETIME" default:"1h"`
+	DBHealthCheckPeriod time.Duration `env:"DB_HEALTH_CHECK_PERIOD" default:"1m"`
+	// How long a statement can run before Postgres cancels it, 0 for no limit
+	DBStatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT" default:"30s"`
+	DBApplicationName  string        `env:"DB_APPLICATION_NAME" default:"golang_integration_testing"`
+	// Queries taking longer are logged with their name and duration, 0 to log none
+	DBSlowQueryThreshold time.Duration `env:"DB_SLOW_QUERY_THRESHOLD" default:"500ms"`
+	// Replicas to read notes from, pooled with the settings above
+	DBReplicaURLs []string `env:"DB_REPLICA_URLS" secret:"true"`
+	// How long a client's reads go to the primary after it writes, so it reads its own writes
+	DBReadYourWritesWindow time.Duration `env:"DB_READ_YOUR_WRITES_WINDOW" default:"5s"`
+
+	ServerPort int `env:"SERVER_PORT" default:"8080"`
+	GRPCPort   int `env:"GRPC_PORT" default:"9090"`
+
+	// How long notes fetched by ID are cached for, 0 to disable caching
+	CacheTTL  time.Duration `env:"CACHE_TTL" default:"1m"`
+	CacheSize int           `env:"CACHE_SIZE" default:"10000"`
+	// Caches notes in Redis rather than in-process, if set
+	RedisURL string `env:"REDIS_URL" secret:"true"`
+
+	// How long note events are kept for clients to resume from, 0 to keep them forever
+	NoteEventRetention time.Duration `env:"NOTE_EVENT_RETENTION" default:"168h"`
+
+	// Keys clients can identify themselves with instead of their IP address
+	APIKeys []string `env:"API_KEYS" secret:"true"`
+	// Proxies, as IPs or CIDRs, trusted to name the client's IP in X-Forwarded-For, none by default
+	TrustedProxies []string   `env:"TRUSTED_PROXIES"`
+	RateLimits     rateLimits `env:"RATE_LIMITS" default:"POST /v1/notes=60/1m" reload:"true"`
+	// Either memory, or postgres to share limits between instances
+	RateLimitStore string `env:"RATE_LIMIT_STORE" default:"memory"`
+	// The most notes each client can have, 0 for no limit
+	NoteQuota int `env:"NOTE_QUOTA" default:"0"`
+
+	// Origins allowed to call the API from browsers, none by default
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" default:"false"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE" default:"10m"`
+	// Set to false when the API isn't served over HTTPS
+	HSTS        bool  `env:"HSTS" default:"true"`
+	MaxBodySize int64 `env:"MAX_BODY_SIZE" default:"1048576"`
+
+	// Serves HTTPS instead of HTTP if set, reloading the files as they change
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+	// Requires clients to present a certificate signed by one of these CAs, if set
+	TLSClientCAFile       string `env:"TLS_CLIENT_CA_FILE"`
+	TLSClientCertOptional bool   `env:"TLS_CLIENT_CERT_OPTIONAL" default:"false"`
+}
+
+var (
+	sslModes        = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	rateLimitStores = []string{"memory", "postgres"}
+)
+
+// Checks what decoding the settings can't, returning every problem found rather than just the first.
+func (cfg Config) validate() error {
+	var errs []error
+
+	// pgx redacts the password from its errors
+	if cfg.DatabaseURL != "" {
+		if _, err := pgxpool.ParseConfig(cfg.DatabaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("DATABASE_URL: %w", err))
+		}
+	} else {
+		if !slices.Contains(sslModes, cfg.PostgresSSLMode) {
+			errs = append(errs, fmt.Errorf("POSTGRES_SSLMODE: must be one of %s", strings.Join(sslModes, ", ")))
+		}
+		if cfg.PostgresSSLRootCert != "" {
+			if _, err := os.Stat(cfg.PostgresSSLRootCert); err != nil {
+				errs = append(errs, fmt.Errorf("POSTGRES_SSLROOTCERT: %w", err))
+			}
+		}
+	}
+
+	for _, replicaURL := range cfg.DBReplicaURLs {
+		if _, err := pgxpool.ParseConfig(replicaURL); err != nil {
+			errs = append(errs, fmt.Errorf("DB_REPLICA_URLS: %w", err))
+		}
+	}
+
+	if cfg.DBMaxConns < 1 {
+		errs = append(errs, errors.New("DB_MAX_CONNS: must be at least 1"))
+	}
+	if cfg.DBMinConns < 0 || cfg.DBMinConns > cfg.DBMaxConns {
+		errs = append(errs, errors.New("DB_MIN_CONNS: must be between 0 and DB_MAX_CONNS"))
+	}
+	if cfg.DBMaxConnLifetime <= 0 {
+		errs = append(errs, errors.New("DB_MAX_CONN_LIFETIME: must be positive"))
+	}
+	if cfg.DBHealthCheckPeriod <= 0 {
+		errs = append(errs, errors.New("DB_HEALTH_CHECK_PERIOD: must be positive"))
+	}
+	if cfg.DBSlowQueryThreshold < 0 {
+		errs = append(errs, errors.New("DB_SLOW_QUERY_THRESHOLD: must not be negative"))
+	}
+	if cfg.DBReadYourWritesWindow < 0 {
+		errs = append(errs, errors.New("DB_READ_YOUR_WRITES_WINDOW: must not be negative"))
+	}
+	if cfg.DBStatementTimeout < 0 {
+		errs = append(errs, errors.New("DB_STATEMENT_TIMEOUT: must not be negative"))
+	} else if cfg.DBStatementTimeout%time.Millisecond != 0 {
+		errs = append(errs, errors.New("DB_STATEMENT_TIMEOUT: must be a whole number of milliseconds"))
+	}
+
+	if cfg.NoteEventRetention < 0 {
+		errs = append(errs, errors.New("NOTE_EVENT_RETENTION: must not be negative"))
+	}
+
+	for _, proxy := range cfg.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %q must be an IP address or CIDR", proxy))
+			}
+		}
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE, TLS_KEY_FILE: must be set together"))
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" {
+		errs = append(errs, errors.New("TLS_CLIENT_CA_FILE: must be set along with TLS_CERT_FILE"))
+	}
+
+	if !slices.Contains(rateLimitStores, cfg.RateLimitStore) {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_STORE: must be one of %s", strings.Join(rateLimitStores, ", ")))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the URL to connect to Postgres with.
+func (cfg Config) databaseURL() string {
+	if cfg.DatabaseURL != "" {
+		return cfg.DatabaseURL
+	}
+
+	query := url.Values{"sslmode": {cfg.PostgresSSLMode}}
+	if cfg.PostgresSSLRootCert != "" {
+		query.Set("sslrootcert", cfg.PostgresSSLRootCert)
+	}
+
+	// Built with url.URL so credentials with special characters are escaped
+	return (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.PostgresUser, cfg.PostgresPassword),
+		Host:     cfg.PostgresHost + ":" + cfg.PostgresPort,
+		Path:     "/" + cfg.PostgresDB,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// Returns the config of the pool to connect to the database at the URL with.
+func (cfg Config) poolConfig(databaseURL string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.MaxConns = cfg.DBMaxConns
+	poolConfig.MinConns = cfg.DBMinConns
+	poolConfig.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolConfig.HealthCheckPeriod = cfg.DBHealthCheckPeriod
+
+	params := poolConfig.ConnConfig.RuntimeParams
+	params["statement_timeout"] = fmt.Sprint(cfg.DBStatementTimeout.Milliseconds())
+	if cfg.DBApplicationName != "" {
+		params["application_name"] = cfg.DBApplicationName
+	}
+
+	poolConfig.AfterConnect = repository.PrepareQueries
+	if cfg.DBSlowQueryThreshold > 0 {
+		poolConfig.ConnConfig.Tracer = repository.NewSlowQueryLogger(cfg.DBSlowQueryThreshold)
+	}
+
+	return poolConfig, nil
+}
+
+// Writes every setting as NAME=value, redacting secrets so the output is safe to log.
+func (cfg Config) write(w io.Writer) error {
+	value, typ := reflect.ValueOf(cfg), reflect.TypeOf(cfg)
+	for i := range typ.NumField() {
+		field, setting := typ.Field(i), fmt.Sprint(value.Field(i).Interface())
+		secret := field.Tag.Get("secret") == "true"
+		if list, ok := value.Field(i).Interface().([]string); ok {
+			// Secret lists are redacted item by item, so URLs can still be told apart
+			if secret {
+				list = slices.Clone(list)
+				for j := range list {
+					list[j] = redact(list[j])
+				}
+			}
+			setting = strings.Join(list, ",")
+		} else if secret {
+			setting = redact(setting)
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", field.Tag.Get("env"), setting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redacts a secret, keeping all but the password of URLs so they can still be told apart.
+func redact(secret string) string {
+	if secret == "" {
+		return secret
+	}
+	if u, err := url.Parse(secret); err == nil && u.Scheme != "" && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+		}
+		if query := u.Query(); query.Has("password") {
+			query.Set("password", "REDACTED")
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+	return "REDACTED"
+}
+
+// Rate limits keyed by method and route, written as comma separated route=requests/period
+// pairs, e.g. "POST /v1/notes=10/1m,PATCH /v1/notes/:id=30/1m".
+type rateLimits map[string]ratelimit.Limit
+
+func (l *rateLimits) Decode(value string) error {
+	limits := rateLimits{}
+	for pair := range strings.SplitSeq(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		route, limit, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid rate limit %q: must be written as route=requests/period", pair)
+		}
+		parsed, err := ratelimit.ParseLimit(limit)
+		if err != nil {
+			return err
+		}
+		limits[strings.Join(strings.Fields(route), " ")] = parsed
+	}
+
+	*l = limits
+	return nil
+}
+
+// Writes the limits the way they are decoded.
+func (l rateLimits) String() string {
+	pairs := make([]string, 0, len(l))
+	for route, limit := range l {
+		pairs = append(pairs, route+"="+limit.String())
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
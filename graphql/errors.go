@@ -0,0 +1,66 @@
+package graphql
+
+import (
+	"errors"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+const (
+	codeBadUserInput  = "BAD_USER_INPUT"
+	codeNotFound      = "NOT_FOUND"
+	codeConflict      = "CONFLICT"
+	codeQuotaExceeded = "QUOTA_EXCEEDED"
+	codeInternal      = "INTERNAL_SERVER_ERROR"
+	codeQueryLimits   = "QUERY_LIMIT_EXCEEDED"
+)
+
+// An error carrying a machine readable code in its extensions.
+type codedError struct {
+	err  error
+	code string
+}
+
+func newError(err error, code string) *codedError {
+	return &codedError{err, code}
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+func (e *codedError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+// Maps domain errors from the service layer to GraphQL errors.
+func toError(err error) error {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return newError(err, codeBadUserInput)
+	case errors.Is(err, service.ErrNoteNotFound):
+		return newError(err, codeNotFound)
+	case errors.Is(err, service.ErrNoteTitleTaken):
+		return newError(err, codeConflict)
+	case errors.Is(err, service.ErrNoteQuotaExceeded):
+		return newError(err, codeQuotaExceeded)
+	default:
+		return newError(err, codeInternal)
+	}
+}
+
+// Formats errors raised outside of resolvers, which graphql-go would drop the extensions of.
+func formatError(err *codedError) []gqlerrors.FormattedError {
+	return []gqlerrors.FormattedError{{
+		Message:    err.Error(),
+		Locations:  []location.SourceLocation{},
+		Extensions: err.Extensions(),
+	}}
+}
@@ -0,0 +1,100 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+const (
+	maxDepth      = 10
+	maxComplexity = 1000
+)
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Serves GraphQL requests, resolved through the service layer.
+type Handler struct {
+	service service.Service
+	schema  graphql.Schema
+}
+
+func NewHandler(svc service.Service) *Handler {
+	schema, err := newSchema(svc)
+	if err != nil {
+		// The schema is static, so this can only be a programming error
+		panic(fmt.Sprintf("invalid graphql schema: %v", err))
+	}
+
+	return &Handler{
+		service: svc,
+		schema:  schema,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		log.Printf("invalid graphql request body: %v", err)
+		h.sendResult(w, http.StatusBadRequest, &graphql.Result{
+			Errors: formatError(newError(fmt.Errorf("bad request"), codeBadUserInput)),
+		})
+		return
+	}
+
+	h.sendResult(w, http.StatusOK, h.execute(r, req))
+}
+
+func (h *Handler) execute(r *http.Request, req request) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if validation := graphql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	// Reject expensive queries before resolving anything
+	cost, err := estimateCost(doc, req.OperationName, req.Variables)
+	if err != nil {
+		return &graphql.Result{Errors: formatError(newError(err, codeBadUserInput))}
+	}
+	if cost.depth > maxDepth {
+		err := fmt.Errorf("query depth %d exceeds the maximum of %d", cost.depth, maxDepth)
+		return &graphql.Result{Errors: formatError(newError(err, codeQueryLimits))}
+	}
+	if cost.complexity > maxComplexity {
+		err := fmt.Errorf("query complexity %d exceeds the maximum of %d", cost.complexity, maxComplexity)
+		return &graphql.Result{Errors: formatError(newError(err, codeQueryLimits))}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(r.Context(), newNoteLoader(h.service)),
+	})
+}
+
+func (h *Handler) sendResult(w http.ResponseWriter, status int, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("unable to write graphql response: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"go.uber.org/mock/gomock"
+)
+
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestHandler(t *testing.T) {
+	// Setup mocked service
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	handler := NewHandler(mockService)
+
+	do := func(t *testing.T, query string, variables map[string]any) response {
+		body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var resp response
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		return resp
+	}
+
+	newNote := func() repository.Note {
+		createdAt := time.Now()
+		return repository.Note{
+			ID:          uuid.New(),
+			Title:       gofakeit.Sentence(3),
+			Description: gofakeit.Sentence(10),
+			Format:      repository.NoteFormatPlain,
+			CreatedAt:   createdAt,
+			UpdatedAt:   &createdAt,
+		}
+	}
+
+	t.Run("note", func(t *testing.T) {
+		t.Run("should batch the lookups of sibling fields into a single call", func(t *testing.T) {
+			noteA, noteB := newNote(), newNote()
+
+			mockService.EXPECT().
+				FetchNotesByIDs(gomock.Any(), gomock.InAnyOrder([]uuid.UUID{noteA.ID, noteB.ID})).
+				Return([]repository.Note{noteA, noteB}, nil).
+				Times(1)
+
+			resp := do(t, `query($a: ID!, $b: ID!) {
+				a: note(id: $a) { id title }
+				b: note(id: $b) { id description }
+			}`, map[string]any{"a": noteA.ID.String(), "b": noteB.ID.String()})
+
+			assert.Empty(t, resp.Errors)
+			assert.Equal(t, map[string]any{"id": noteA.ID.String(), "title": noteA.Title}, resp.Data["a"])
+			assert.Equal(t, map[string]any{"id": noteB.ID.String(), "description": noteB.Description}, resp.Data["b"])
+		})
+
+		t.Run("should resolve to null if the note doesn't exist", func(t *testing.T) {
+			id := uuid.New()
+			mockService.EXPECT().FetchNotesByIDs(gomock.Any(), []uuid.UUID{id}).Return(nil, nil)
+
+			resp := do(t, `query($id: ID!) { note(id: $id) { id } }`, map[string]any{"id": id.String()})
+			assert.Empty(t, resp.Errors)
+			assert.Nil(t, resp.Data["note"])
+		})
+
+		t.Run("should return a BAD_USER_INPUT error if the ID is invalid", func(t *testing.T) {
+			resp := do(t, `{ note(id: "not-a-uuid") { id } }`, nil)
+			assert.Len(t, resp.Errors, 1)
+			assert.Equal(t, codeBadUserInput, resp.Errors[0].Extensions["code"])
+		})
+	})
+
+	t.Run("notes", func(t *testing.T) {
+		notes := []repository.Note{newNote(), newNote(), newNote()}
+		notes[1].Title = "Groceries for the week"
+
+		t.Run("should paginate with cursors", func(t *testing.T) {
+			// One more note than asked for is fetched, to tell if there is a next page
+			mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{Limit: 3}).Return(notes, nil)
+			// Cursors only keep times to the nanosecond, without a monotonic reading
+			after, err := decodeCursor(encodeCursor(repository.CursorOf(notes[1])))
+			assert.NoError(t, err)
+			mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{After: &after, Limit: 3}).Return(notes[2:], nil)
+
+			query := `query($after: String) {
+				notes(first: 2, after: $after) {
+					edges { cursor node { id } }
+					pageInfo { hasNextPage endCursor }
+				}
+			}`
+
+			resp := do(t, query, nil)
+			assert.Empty(t, resp.Errors)
+
+			connection := resp.Data["notes"].(map[string]any)
+			assert.Len(t, connection["edges"], 2)
+			pageInfo := connection["pageInfo"].(map[string]any)
+			assert.Equal(t, true, pageInfo["hasNextPage"])
+
+			resp = do(t, query, map[string]any{"after": pageInfo["endCursor"]})
+			assert.Empty(t, resp.Errors)
+
+			connection = resp.Data["notes"].(map[string]any)
+			edges := connection["edges"].([]any)
+			assert.Len(t, edges, 1)
+			assert.Equal(t, notes[2].ID.String(), edges[0].(map[string]any)["node"].(map[string]any)["id"])
+			assert.Equal(t, false, connection["pageInfo"].(map[string]any)["hasNextPage"])
+		})
+
+		t.Run("should continue from the position of a cursor's note once it is deleted", func(t *testing.T) {
+			// The cursor of a page whose last note was deleted since
+			cursor := encodeCursor(repository.CursorOf(newNote()))
+			after, err := decodeCursor(cursor)
+			assert.NoError(t, err)
+			mockService.EXPECT().
+				FetchNotes(gomock.Any(), repository.NoteFilter{After: &after, Limit: defaultPageSize + 1}).
+				Return(notes[2:], nil)
+
+			resp := do(t, `query($after: String) { notes(after: $after) { edges { node { id } } } }`, map[string]any{"after": cursor})
+			assert.Empty(t, resp.Errors)
+
+			edges := resp.Data["notes"].(map[string]any)["edges"].([]any)
+			assert.Len(t, edges, 1)
+			assert.Equal(t, notes[2].ID.String(), edges[0].(map[string]any)["node"].(map[string]any)["id"])
+		})
+
+		t.Run("should reject cursors that can't be decoded", func(t *testing.T) {
+			resp := do(t, `{ notes(after: "bm90ZTp7fQ") { edges { node { id } } } }`, nil)
+			assert.Len(t, resp.Errors, 1)
+			assert.Equal(t, codeBadUserInput, resp.Errors[0].Extensions["code"])
+		})
+
+		t.Run("should not accept the titleContains filter", func(t *testing.T) {
+			resp := do(t, `{ notes(filter: {titleContains: "groceries"}) { edges { node { id } } } }`, nil)
+			assert.Len(t, resp.Errors, 1)
+			assert.Nil(t, resp.Data)
+		})
+
+		t.Run("should pass filters and sorting to the service", func(t *testing.T) {
+			createdAfter := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			mockService.EXPECT().
+				FetchNotes(gomock.Any(), repository.NoteFilter{
+					CreatedAfter: &createdAfter,
+					Contains:     "milk",
+					Sort:         []repository.NoteSort{{Field: "updated_at", Descending: true}},
+					Limit:        defaultPageSize + 1,
+				}).
+				Return(notes, nil)
+
+			resp := do(t, `{
+				notes(filter: {contains: "milk", createdAfter: "2024-01-02T03:04:05Z"}, sort: "-updatedAt") {
+					edges { node { id } }
+				}
+			}`, nil)
+			assert.Empty(t, resp.Errors)
+		})
+
+		t.Run("should reject unknown sort fields", func(t *testing.T) {
+			resp := do(t, `{ notes(sort: "description") { edges { node { id } } } }`, nil)
+			assert.Len(t, resp.Errors, 1)
+			assert.Equal(t, codeBadUserInput, resp.Errors[0].Extensions["code"])
+		})
+
+		t.Run("should reject queries over the complexity limit", func(t *testing.T) {
+			resp := do(t, `{
+				a: notes(first: 100) { edges { node { id title description createdAt updatedAt } } }
+				b: notes(first: 100) { edges { node { id title description createdAt updatedAt } } }
+			}`, nil)
+			assert.Len(t, resp.Errors, 1)
+			assert.Equal(t, codeQueryLimits, resp.Errors[0].Extensions["code"])
+		})
+
+		t.Run("should reject queries over the depth limit", func(t *testing.T) {
+			resp := do(t, `{
+				__schema { types { fields { type { ofType { ofType { ofType { ofType { ofType { ofType {
+					name
+				} } } } } } } } } }
+			}`, nil)
+			assert.Len(t, resp.Errors, 1)
+			assert.Equal(t, codeQueryLimits, resp.Errors[0].Extensions["code"])
+		})
+	})
+
+	t.Run("mutations", func(t *testing.T) {
+		t.Run("should create a note", func(t *testing.T) {
+			note := newNote()
+			dto := repository.CreateNoteDTO{Title: note.Title, Description: note.Description}
+			mockService.EXPECT().CreateNote(gomock.Any(), dto).Return(&note, nil)
+
+			resp := do(t, `mutation($title: String!, $description: String!) {
+				createNote(input: {title: $title, description: $description}) { id }
+			}`, map[string]any{"title": note.Title, "description": note.Description})
+
+			assert.Empty(t, resp.Errors)
+			assert.Equal(t, note.ID.String(), resp.Data["createNote"].(map[string]any)["id"])
+		})
+
+		t.Run("should create a note with a description format", func(t *testing.T) {
+			note := newNote()
+			note.Format = repository.NoteFormatMarkdown
+			dto := repository.CreateNoteDTO{Title: note.Title, Description: note.Description, Format: repository.NoteFormatMarkdown}
+			mockService.EXPECT().CreateNote(gomock.Any(), dto).Return(&note, nil)
+
+			resp := do(t, `mutation($title: String!, $description: String!) {
+				createNote(input: {title: $title, description: $description, format: MARKDOWN}) { id format }
+			}`, map[string]any{"title": note.Title, "description": note.Description})
+
+			assert.Empty(t, resp.Errors)
+			assert.Equal(t, "MARKDOWN", resp.Data["createNote"].(map[string]any)["format"])
+		})
+
+		t.Run("should return a CONFLICT error if the title is taken", func(t *testing.T) {
+			note := newNote()
+			mockService.EXPECT().UpdateNote(gomock.Any(), note.ID, repository.UpdateNoteDTO{Title: &note.Title}).
+				Return(nil, service.ErrNoteTitleTaken)
+
+			resp := do(t, `mutation($id: ID!, $title: String) {
+				updateNote(id: $id, input: {title: $title}) { id }
+			}`, map[string]any{"id": note.ID.String(), "title": note.Title})
+
+			assert.Len(t, resp.Errors, 1)
+			assert.Equal(t, service.ErrNoteTitleTaken.Error(), resp.Errors[0].Message)
+			assert.Equal(t, codeConflict, resp.Errors[0].Extensions["code"])
+		})
+
+		t.Run("should return a QUOTA_EXCEEDED error if the note quota was reached", func(t *testing.T) {
+			mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(nil, service.ErrNoteQuotaExceeded)
+
+			resp := do(t, `mutation($title: String!, $description: String!) {
+				createNote(input: {title: $title, description: $description}) { id }
+			}`, map[string]any{"title": gofakeit.Sentence(3), "description": gofakeit.Sentence(10)})
+
+			assert.Len(t, resp.Errors, 1)
+			assert.Equal(t, codeQuotaExceeded, resp.Errors[0].Extensions["code"])
+		})
+
+		t.Run("should delete a note", func(t *testing.T) {
+			id := uuid.New()
+			mockService.EXPECT().DeleteNote(gomock.Any(), id).Return(nil)
+
+			resp := do(t, `mutation($id: ID!) { deleteNote(id: $id) }`, map[string]any{"id": id.String()})
+			assert.Empty(t, resp.Errors)
+			assert.Equal(t, true, resp.Data["deleteNote"])
+		})
+	})
+
+	t.Run("should return a 400 status code if the request body is invalid", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(context.Background(), http.MethodPost, "/graphql", strings.NewReader("{"))
+		handler.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
@@ -0,0 +1,109 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// The cost of resolving an operation, estimated before it is executed.
+type queryCost struct {
+	depth      int
+	complexity int
+}
+
+// Estimates the cost of the operation with the given name in doc.
+// Every field costs 1, and the fields selected below a paginated field
+// are counted once for every item of the page requested.
+func estimateCost(doc *ast.Document, operationName string, variables map[string]any) (queryCost, error) {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+
+	if operation == nil {
+		return queryCost{}, fmt.Errorf("unknown operation %q", operationName)
+	}
+
+	estimator := &costEstimator{fragments: fragments, variables: variables, visiting: map[string]bool{}}
+	return estimator.selectionSet(operation.SelectionSet), nil
+}
+
+type costEstimator struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	visiting  map[string]bool
+}
+
+func (e *costEstimator) selectionSet(set *ast.SelectionSet) queryCost {
+	var cost queryCost
+	if set == nil {
+		return cost
+	}
+
+	for _, selection := range set.Selections {
+		var selectionCost queryCost
+
+		switch selection := selection.(type) {
+		case *ast.Field:
+			children := e.selectionSet(selection.SelectionSet)
+			selectionCost = queryCost{
+				depth:      children.depth + 1,
+				complexity: 1 + e.multiplier(selection)*children.complexity,
+			}
+		case *ast.InlineFragment:
+			selectionCost = e.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			// Fragment cycles are rejected by validation, but must not hang the estimate
+			name := selection.Name.Value
+			if fragment, ok := e.fragments[name]; ok && !e.visiting[name] {
+				e.visiting[name] = true
+				selectionCost = e.selectionSet(fragment.SelectionSet)
+				e.visiting[name] = false
+			}
+		}
+
+		cost.depth = max(cost.depth, selectionCost.depth)
+		cost.complexity += selectionCost.complexity
+	}
+
+	return cost
+}
+
+// Returns the number of items a field may resolve to.
+func (e *costEstimator) multiplier(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if first, err := strconv.Atoi(value.Value); err == nil {
+				return max(first, 1)
+			}
+		case *ast.Variable:
+			switch first := e.variables[value.Name.Value].(type) {
+			case float64:
+				return max(int(first), 1)
+			case int:
+				return max(first, 1)
+			}
+		}
+	}
+
+	if field.Name.Value == "notes" {
+		return defaultPageSize
+	}
+	return 1
+}
@@ -0,0 +1,112 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+type loaderContextKey struct{}
+
+// Collects the note IDs requested while resolving one level of a query,
+// and fetches them in a single call once the first of them is needed.
+type noteLoader struct {
+	service service.Service
+
+	mu    sync.Mutex
+	batch *noteBatch
+	notes map[uuid.UUID]*repository.Note
+}
+
+type noteBatch struct {
+	ids  []uuid.UUID
+	once sync.Once
+	err  error
+}
+
+func newNoteLoader(svc service.Service) *noteLoader {
+	return &noteLoader{
+		service: svc,
+		notes:   map[uuid.UUID]*repository.Note{},
+	}
+}
+
+func withLoader(ctx context.Context, loader *noteLoader) context.Context {
+	return context.WithValue(ctx, loaderContextKey{}, loader)
+}
+
+func loaderFromContext(ctx context.Context) *noteLoader {
+	return ctx.Value(loaderContextKey{}).(*noteLoader)
+}
+
+// Returns a thunk resolving to the note, or nil if no note exists with the ID.
+func (l *noteLoader) load(ctx context.Context, id uuid.UUID) func() (any, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.notes[id]; ok {
+		return func() (any, error) { return l.get(id), nil }
+	}
+
+	if l.batch == nil {
+		l.batch = &noteBatch{}
+	}
+	batch := l.batch
+	batch.ids = append(batch.ids, id)
+
+	return func() (any, error) {
+		batch.once.Do(func() { l.fetch(ctx, batch) })
+		if batch.err != nil {
+			return nil, batch.err
+		}
+		return l.get(id), nil
+	}
+}
+
+func (l *noteLoader) fetch(ctx context.Context, batch *noteBatch) {
+	// Later loads start a new batch
+	l.mu.Lock()
+	if l.batch == batch {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	ids := make([]uuid.UUID, 0, len(batch.ids))
+	seen := map[uuid.UUID]bool{}
+	for _, id := range batch.ids {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	notes, err := l.service.FetchNotesByIDs(ctx, ids)
+	if err != nil {
+		batch.err = toError(err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range batch.ids {
+		l.notes[id] = nil
+	}
+	for i := range notes {
+		l.notes[notes[i].ID] = &notes[i]
+	}
+}
+
+func (l *noteLoader) get(id uuid.UUID) any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Avoid returning a typed nil
+	if note := l.notes[id]; note != nil {
+		return note
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package graphql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	cursorPrefix    = "note:"
+)
+
+var (
+	errInvalidNoteID   = errors.New("invalid note ID")
+	errInvalidCursor   = errors.New("invalid cursor")
+	errInvalidPageSize = fmt.Errorf("first must be between 1 and %d", maxPageSize)
+	errInvalidNote     = errors.New("title and description are required")
+)
+
+type resolver struct {
+	service service.Service
+}
+
+func newSchema(svc service.Service) (graphql.Schema, error) {
+	r := &resolver{svc}
+
+	noteFormatType := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "NoteFormat",
+		Description: "How the description of a note is written",
+		Values: graphql.EnumValueConfigMap{
+			"PLAIN":    &graphql.EnumValueConfig{Value: repository.NoteFormatPlain},
+			"MARKDOWN": &graphql.EnumValueConfig{Value: repository.NoteFormatMarkdown},
+		},
+	})
+
+	noteType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Note",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*repository.Note).ID.String(), nil
+				},
+			},
+			"title": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*repository.Note).Title, nil
+				},
+			},
+			"description": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*repository.Note).Description, nil
+				},
+			},
+			"format": &graphql.Field{
+				Type: graphql.NewNonNull(noteFormatType),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*repository.Note).Format, nil
+				},
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*repository.Note).CreatedAt, nil
+				},
+			},
+			"updatedAt": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if updatedAt := p.Source.(*repository.Note).UpdatedAt; updatedAt != nil {
+						return *updatedAt, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	noteEdgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NoteEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(noteType)},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	noteConnectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NoteConnection",
+		Fields: graphql.Fields{
+			"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(noteEdgeType)))},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	noteFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "NoteFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"titlePrefix":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"contains":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"createdAfter":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"createdBefore": &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"updatedAfter":  &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"updatedBefore": &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		},
+	})
+
+	createNoteInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateNoteInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"format":      &graphql.InputObjectFieldConfig{Type: noteFormatType},
+		},
+	})
+
+	updateNoteInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateNoteInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"format":      &graphql.InputObjectFieldConfig{Type: noteFormatType},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"note": &graphql.Field{
+				Type: noteType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.note,
+			},
+			"notes": &graphql.Field{
+				Type: graphql.NewNonNull(noteConnectionType),
+				Args: graphql.FieldConfigArgument{
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+					"filter": &graphql.ArgumentConfig{Type: noteFilterType},
+					"sort": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "Comma separated fields, each prefixed with - to sort descending, e.g. \"-updatedAt,title\"",
+					},
+				},
+				Resolve: r.notes,
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createNote": &graphql.Field{
+				Type: graphql.NewNonNull(noteType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createNoteInputType)},
+				},
+				Resolve: r.createNote,
+			},
+			"updateNote": &graphql.Field{
+				Type: graphql.NewNonNull(noteType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateNoteInputType)},
+				},
+				Resolve: r.updateNote,
+			},
+			"deleteNote": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.deleteNote,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+func (r *resolver) note(p graphql.ResolveParams) (any, error) {
+	id, err := parseNoteID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolved lazily, so lookups from sibling fields are batched together
+	return loaderFromContext(p.Context).load(p.Context, id), nil
+}
+
+func (r *resolver) notes(p graphql.ResolveParams) (any, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, newError(errInvalidPageSize, codeBadUserInput)
+	}
+
+	filter, err := noteFilter(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Continue from the position of the note the cursor points to, even if it was deleted
+	if after, ok := p.Args["after"].(string); ok {
+		cursor, err := decodeCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = &cursor
+	}
+
+	// Fetch one more note than asked for, to tell if there is a next page
+	filter.Limit = first + 1
+	notes, err := r.service.FetchNotes(p.Context, filter)
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	end := min(first, len(notes))
+	edges := make([]map[string]any, 0, end)
+	for i := range end {
+		edges = append(edges, map[string]any{
+			"cursor": encodeCursor(repository.CursorOf(notes[i])),
+			"node":   &notes[i],
+		})
+	}
+
+	pageInfo := map[string]any{"hasNextPage": len(notes) > first}
+	if len(edges) > 0 {
+		pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+	}
+
+	return map[string]any{"edges": edges, "pageInfo": pageInfo}, nil
+}
+
+func (r *resolver) createNote(p graphql.ResolveParams) (any, error) {
+	input := p.Args["input"].(map[string]any)
+
+	title, _ := input["title"].(string)
+	description, _ := input["description"].(string)
+	if title == "" || description == "" {
+		return nil, newError(errInvalidNote, codeBadUserInput)
+	}
+
+	format, _ := input["format"].(repository.NoteFormat)
+	note, err := r.service.CreateNote(p.Context, repository.CreateNoteDTO{
+		Title:       title,
+		Description: description,
+		Format:      format,
+	})
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	return note, nil
+}
+
+func (r *resolver) updateNote(p graphql.ResolveParams) (any, error) {
+	id, err := parseNoteID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	var dto repository.UpdateNoteDTO
+	input := p.Args["input"].(map[string]any)
+	if title, ok := input["title"].(string); ok {
+		dto.Title = &title
+	}
+	if description, ok := input["description"].(string); ok {
+		dto.Description = &description
+	}
+	if format, ok := input["format"].(repository.NoteFormat); ok {
+		dto.Format = &format
+	}
+
+	note, err := r.service.UpdateNote(p.Context, id, dto)
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	return note, nil
+}
+
+func (r *resolver) deleteNote(p graphql.ResolveParams) (any, error) {
+	id, err := parseNoteID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.service.DeleteNote(p.Context, id); err != nil {
+		return nil, toError(err)
+	}
+
+	return true, nil
+}
+
+// The repository's field names for the note fields notes can be sorted by.
+var sortFields = map[string]string{
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+	"title":     "title",
+}
+
+// Builds the repository filter from the filter and sort arguments of the notes query.
+func noteFilter(args map[string]any) (repository.NoteFilter, error) {
+	var filter repository.NoteFilter
+
+	if input, ok := args["filter"].(map[string]any); ok {
+		filter.TitlePrefix, _ = input["titlePrefix"].(string)
+		filter.Contains, _ = input["contains"].(string)
+
+		for name, field := range map[string]**time.Time{
+			"createdAfter":  &filter.CreatedAfter,
+			"createdBefore": &filter.CreatedBefore,
+			"updatedAfter":  &filter.UpdatedAfter,
+			"updatedBefore": &filter.UpdatedBefore,
+		} {
+			if value, ok := input[name].(time.Time); ok {
+				*field = &value
+			}
+		}
+	}
+
+	if sort, ok := args["sort"].(string); ok {
+		for field := range strings.SplitSeq(sort, ",") {
+			field = strings.TrimSpace(field)
+			descending := strings.HasPrefix(field, "-")
+
+			name, ok := sortFields[strings.TrimPrefix(field, "-")]
+			if !ok {
+				return filter, newError(fmt.Errorf("unknown sort field %q", field), codeBadUserInput)
+			}
+			filter.Sort = append(filter.Sort, repository.NoteSort{Field: name, Descending: descending})
+		}
+	}
+
+	return filter, nil
+}
+
+func parseNoteID(value any) (uuid.UUID, error) {
+	raw, _ := value.(string)
+	id, err := uuid.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return uuid.Nil, newError(errInvalidNoteID, codeBadUserInput)
+	}
+	return id, nil
+}
+
+// Encodes the position of a note, rather than just its ID, so pages can continue from it
+// whatever the notes are sorted by, and after it is deleted.
+func encodeCursor(cursor repository.NoteCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(append([]byte(cursorPrefix), raw...))
+}
+
+func decodeCursor(encoded string) (repository.NoteCursor, error) {
+	var cursor repository.NoteCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return cursor, newError(errInvalidCursor, codeBadUserInput)
+	}
+	if err := json.Unmarshal(raw[len(cursorPrefix):], &cursor); err != nil || cursor.ID == uuid.Nil {
+		return cursor, newError(errInvalidCursor, codeBadUserInput)
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// The metadata key clients send their API key in, as the X-API-Key header of the REST API.
+const apiKeyMetadata = "x-api-key"
+
+// Identifies clients sending one of keys by it, rather than by their IP address.
+// Keys that aren't known are ignored, so clients can't pick who they are counted as.
+func WithAPIKeys(keys ...string) Option {
+	return func(s *Server) {
+		for _, key := range keys {
+			s.apiKeys[hashAPIKey(key)] = true
+		}
+	}
+}
+
+// Hashed as the REST API hashes them, so a client sending the same key to both is the same client.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Returns the client's verified certificate if they presented one, their API key if it is
+// a known one, or their IP address otherwise, named as the REST API names them.
+func (s *Server) clientID(ctx context.Context) string {
+	p, _ := peer.FromContext(ctx)
+	if p != nil {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if chains := info.State.VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+				cert := chains[0][0]
+				if cert.Subject.CommonName != "" {
+					return "cert:" + cert.Subject.CommonName
+				}
+				sum := sha256.Sum256(cert.Raw)
+				return "cert:" + hex.EncodeToString(sum[:])
+			}
+		}
+	}
+	if keys := metadata.ValueFromIncomingContext(ctx, apiKeyMetadata); len(keys) > 0 && keys[0] != "" {
+		if hash := hashAPIKey(keys[0]); s.apiKeys[hash] {
+			return "key:" + hash
+		}
+	}
+	if p == nil || p.Addr == nil {
+		return "ip:"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// Makes the client the owner of the notes it creates and the session that reads its own writes.
+func (s *Server) identify(ctx context.Context) context.Context {
+	client := s.clientID(ctx)
+	return repository.WithSession(service.WithOwner(ctx, client), client)
+}
+
+func (s *Server) identifyUnary(
+	ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	return handler(s.identify(ctx), req)
+}
+
+func (s *Server) identifyStream(
+	srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	return handler(srv, &identifiedStream{ServerStream: stream, ctx: s.identify(stream.Context())})
+}
+
+// A stream whose context identifies the client.
+type identifiedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identifiedStream) Context() context.Context {
+	return s.ctx
+}
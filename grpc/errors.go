@@ -0,0 +1,28 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errInvalidNoteID = status.Error(codes.InvalidArgument, "invalid note ID")
+
+// Maps domain errors from the service layer to gRPC status errors.
+func toStatus(err error) error {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrNoteNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrNoteTitleTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrNoteQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,198 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	notesv1 "github.com/the-code-genin/golang_integration_testing/proto/notes/v1"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	notesv1.UnimplementedNotesServiceServer
+
+	service service.Service
+	server  *grpc.Server
+	apiKeys map[string]bool
+}
+
+// Configures optional behaviour of a Server.
+type Option func(*Server)
+
+func NewServer(svc service.Service, opts ...Option) *Server {
+	server := &Server{
+		service: svc,
+		apiKeys: map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	server.server = grpc.NewServer(
+		grpc.UnaryInterceptor(server.identifyUnary),
+		grpc.StreamInterceptor(server.identifyStream),
+	)
+
+	notesv1.RegisterNotesServiceServer(server.server, server)
+
+	return server
+}
+
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	return s.server.Serve(listener)
+}
+
+func (s *Server) Stop() {
+	s.server.GracefulStop()
+}
+
+func (s *Server) CreateNote(
+	ctx context.Context, req *notesv1.CreateNoteRequest,
+) (*notesv1.CreateNoteResponse, error) {
+	// Mirror the validation of the REST API
+	if req.GetTitle() == "" || req.GetDescription() == "" {
+		return nil, status.Error(codes.InvalidArgument, "title and description are required")
+	}
+
+	note, err := s.service.CreateNote(ctx, repository.CreateNoteDTO{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Format:      repository.NoteFormat(req.GetFormat()),
+	})
+	if err != nil {
+		log.Printf("unable to create note: %v", err)
+		return nil, toStatus(err)
+	}
+
+	return &notesv1.CreateNoteResponse{Note: toProtoNote(note)}, nil
+}
+
+func (s *Server) UpdateNote(
+	ctx context.Context, req *notesv1.UpdateNoteRequest,
+) (*notesv1.UpdateNoteResponse, error) {
+	id, err := parseNoteID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := s.service.UpdateNote(ctx, id, repository.UpdateNoteDTO{
+		Title:       req.Title,
+		Description: req.Description,
+		Format:      (*repository.NoteFormat)(req.Format),
+	})
+	if err != nil {
+		log.Printf("unable to update note: %v", err)
+		return nil, toStatus(err)
+	}
+
+	return &notesv1.UpdateNoteResponse{Note: toProtoNote(note)}, nil
+}
+
+func (s *Server) DeleteNote(
+	ctx context.Context, req *notesv1.DeleteNoteRequest,
+) (*notesv1.DeleteNoteResponse, error) {
+	id, err := parseNoteID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.service.DeleteNote(ctx, id); err != nil {
+		log.Printf("unable to delete note: %v", err)
+		return nil, toStatus(err)
+	}
+
+	return &notesv1.DeleteNoteResponse{}, nil
+}
+
+func (s *Server) ListNotes(
+	ctx context.Context, req *notesv1.ListNotesRequest,
+) (*notesv1.ListNotesResponse, error) {
+	notes, err := s.service.FetchNotes(ctx, repository.NoteFilter{})
+	if err != nil {
+		log.Printf("unable to fetch notes: %v", err)
+		return nil, toStatus(err)
+	}
+
+	resp := &notesv1.ListNotesResponse{Notes: make([]*notesv1.Note, 0, len(notes))}
+	for i := range notes {
+		resp.Notes = append(resp.Notes, toProtoNote(&notes[i]))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) GetNote(
+	ctx context.Context, req *notesv1.GetNoteRequest,
+) (*notesv1.GetNoteResponse, error) {
+	id, err := parseNoteID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := s.service.FetchNoteByID(ctx, id)
+	if err != nil {
+		log.Printf("unable to fetch note: %v", err)
+		return nil, toStatus(err)
+	}
+
+	return &notesv1.GetNoteResponse{Note: toProtoNote(note)}, nil
+}
+
+func (s *Server) StreamNotes(
+	req *notesv1.StreamNotesRequest, stream grpc.ServerStreamingServer[notesv1.StreamNotesResponse],
+) error {
+	var sendErr error
+	err := s.service.StreamNotes(stream.Context(), func(note repository.Note) error {
+		sendErr = stream.Send(&notesv1.StreamNotesResponse{Note: toProtoNote(&note)})
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		log.Printf("unable to stream notes: %v", err)
+		return toStatus(err)
+	}
+
+	return nil
+}
+
+func parseNoteID(value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(strings.TrimSpace(value))
+	if err != nil {
+		log.Printf("invalid UUID: %v", err)
+		return uuid.Nil, errInvalidNoteID
+	}
+	return id, nil
+}
+
+func toProtoNote(note *repository.Note) *notesv1.Note {
+	protoNote := &notesv1.Note{
+		Id:          note.ID.String(),
+		Title:       note.Title,
+		Description: note.Description,
+		Format:      string(note.Format),
+		CreatedAt:   timestamppb.New(note.CreatedAt),
+	}
+
+	if note.UpdatedAt != nil {
+		protoNote.UpdatedAt = timestamppb.New(*note.UpdatedAt)
+	}
+
+	return protoNote
+}
@@ -0,0 +1,321 @@
+package grpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	notesv1 "github.com/the-code-genin/golang_integration_testing/proto/notes/v1"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+
+	// Setup mocked service
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	// Serve over an in-memory listener
+	listener := bufconn.Listen(1024 * 1024)
+	apiKey := gofakeit.UUID()
+	server := NewServer(mockService, WithAPIKeys(apiKey))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	client := notesv1.NewNotesServiceClient(conn)
+
+	newNote := func() *repository.Note {
+		updatedAt := time.Now()
+		return &repository.Note{
+			ID:          uuid.New(),
+			Title:       gofakeit.Sentence(3),
+			Description: gofakeit.Sentence(10),
+			CreatedAt:   updatedAt,
+			UpdatedAt:   &updatedAt,
+		}
+	}
+
+	t.Run("CreateNote", func(t *testing.T) {
+		t.Run("should create a note given a title and description", func(t *testing.T) {
+			note := newNote()
+			dto := repository.CreateNoteDTO{Title: note.Title, Description: note.Description}
+
+			mockService.EXPECT().CreateNote(gomock.Any(), dto).Return(note, nil)
+
+			resp, err := client.CreateNote(ctx, &notesv1.CreateNoteRequest{
+				Title:       note.Title,
+				Description: note.Description,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, note.ID.String(), resp.GetNote().GetId())
+			assert.Equal(t, note.Title, resp.GetNote().GetTitle())
+			assert.True(t, note.CreatedAt.Equal(resp.GetNote().GetCreatedAt().AsTime()))
+		})
+
+		t.Run("should pass the description format to the service", func(t *testing.T) {
+			note := newNote()
+			note.Format = repository.NoteFormatMarkdown
+			dto := repository.CreateNoteDTO{Title: note.Title, Description: note.Description, Format: repository.NoteFormatMarkdown}
+
+			mockService.EXPECT().CreateNote(gomock.Any(), dto).Return(note, nil)
+
+			resp, err := client.CreateNote(ctx, &notesv1.CreateNoteRequest{
+				Title:       note.Title,
+				Description: note.Description,
+				Format:      "markdown",
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "markdown", resp.GetNote().GetFormat())
+		})
+
+		t.Run("should return InvalidArgument if the title is missing", func(t *testing.T) {
+			_, err := client.CreateNote(ctx, &notesv1.CreateNoteRequest{Description: gofakeit.Sentence(10)})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+
+		t.Run("should return AlreadyExists if the title is taken", func(t *testing.T) {
+			mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(nil, service.ErrNoteTitleTaken)
+
+			_, err := client.CreateNote(ctx, &notesv1.CreateNoteRequest{
+				Title:       gofakeit.Sentence(3),
+				Description: gofakeit.Sentence(10),
+			})
+			assert.Equal(t, codes.AlreadyExists, status.Code(err))
+			assert.Equal(t, service.ErrNoteTitleTaken.Error(), status.Convert(err).Message())
+		})
+
+		t.Run("should return ResourceExhausted if the note quota was reached", func(t *testing.T) {
+			mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(nil, service.ErrNoteQuotaExceeded)
+
+			_, err := client.CreateNote(ctx, &notesv1.CreateNoteRequest{
+				Title:       gofakeit.Sentence(3),
+				Description: gofakeit.Sentence(10),
+			})
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		})
+	})
+
+	t.Run("GetNote", func(t *testing.T) {
+		t.Run("should fetch a note given a valid ID", func(t *testing.T) {
+			note := newNote()
+			mockService.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(note, nil)
+
+			resp, err := client.GetNote(ctx, &notesv1.GetNoteRequest{Id: note.ID.String()})
+			assert.NoError(t, err)
+			assert.Equal(t, note.Description, resp.GetNote().GetDescription())
+		})
+
+		t.Run("should return NotFound if the note doesn't exist", func(t *testing.T) {
+			id := uuid.New()
+			mockService.EXPECT().FetchNoteByID(gomock.Any(), id).Return(nil, service.ErrNoteNotFound)
+
+			_, err := client.GetNote(ctx, &notesv1.GetNoteRequest{Id: id.String()})
+			assert.Equal(t, codes.NotFound, status.Code(err))
+		})
+
+		t.Run("should return InvalidArgument if the ID is invalid", func(t *testing.T) {
+			_, err := client.GetNote(ctx, &notesv1.GetNoteRequest{Id: "not-a-uuid"})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	})
+
+	t.Run("UpdateNote", func(t *testing.T) {
+		t.Run("should only update the fields given", func(t *testing.T) {
+			note := newNote()
+			dto := repository.UpdateNoteDTO{Title: &note.Title}
+
+			mockService.EXPECT().UpdateNote(gomock.Any(), note.ID, dto).Return(note, nil)
+
+			resp, err := client.UpdateNote(ctx, &notesv1.UpdateNoteRequest{Id: note.ID.String(), Title: &note.Title})
+			assert.NoError(t, err)
+			assert.Equal(t, note.Title, resp.GetNote().GetTitle())
+		})
+
+		t.Run("should return InvalidArgument if the note breaks a validation rule", func(t *testing.T) {
+			title := ""
+			validationErr := &service.ValidationError{Violations: []service.Violation{{Field: "title", Message: "must not be empty"}}}
+			mockService.EXPECT().UpdateNote(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, validationErr)
+
+			_, err := client.UpdateNote(ctx, &notesv1.UpdateNoteRequest{Id: uuid.NewString(), Title: &title})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.Equal(t, validationErr.Error(), status.Convert(err).Message())
+		})
+	})
+
+	t.Run("DeleteNote", func(t *testing.T) {
+		t.Run("should return Internal for unknown errors", func(t *testing.T) {
+			id := uuid.New()
+			mockService.EXPECT().DeleteNote(gomock.Any(), id).Return(service.ErrInternal)
+
+			_, err := client.DeleteNote(ctx, &notesv1.DeleteNoteRequest{Id: id.String()})
+			assert.Equal(t, codes.Internal, status.Code(err))
+		})
+	})
+
+	t.Run("ListNotes", func(t *testing.T) {
+		notes := []repository.Note{*newNote(), *newNote()}
+
+		t.Run("should list all notes", func(t *testing.T) {
+			mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{}).Return(notes, nil)
+
+			resp, err := client.ListNotes(ctx, &notesv1.ListNotesRequest{})
+			assert.NoError(t, err)
+			assert.Len(t, resp.GetNotes(), len(notes))
+		})
+
+		t.Run("should stream all notes", func(t *testing.T) {
+			mockService.EXPECT().
+				StreamNotes(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, fn func(repository.Note) error) error {
+					for _, note := range notes {
+						if err := fn(note); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+
+			stream, err := client.StreamNotes(ctx, &notesv1.StreamNotesRequest{})
+			assert.NoError(t, err)
+
+			var ids []string
+			for {
+				resp, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				assert.NoError(t, err)
+				ids = append(ids, resp.GetNote().GetId())
+			}
+			assert.Equal(t, []string{notes[0].ID.String(), notes[1].ID.String()}, ids)
+		})
+
+		t.Run("should send each note as it is streamed, without buffering them", func(t *testing.T) {
+			received := make(chan struct{})
+			mockService.EXPECT().
+				StreamNotes(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, fn func(repository.Note) error) error {
+					if err := fn(notes[0]); err != nil {
+						return err
+					}
+					// The client gets the first note before the rest are fetched
+					select {
+					case <-received:
+					case <-time.After(5 * time.Second):
+						t.Error("the first note wasn't sent before the stream ended")
+					}
+					return fn(notes[1])
+				})
+
+			stream, err := client.StreamNotes(ctx, &notesv1.StreamNotesRequest{})
+			assert.NoError(t, err)
+
+			resp, err := stream.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, notes[0].ID.String(), resp.GetNote().GetId())
+			close(received)
+
+			resp, err = stream.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, notes[1].ID.String(), resp.GetNote().GetId())
+
+			_, err = stream.Recv()
+			assert.Equal(t, io.EOF, err)
+		})
+	})
+
+	t.Run("Clients", func(t *testing.T) {
+		t.Run("should make clients the owners of the notes they create, by their API key", func(t *testing.T) {
+			note := newNote()
+			mockService.EXPECT().
+				CreateNote(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, _ repository.CreateNoteDTO) (*repository.Note, error) {
+					assert.Equal(t, "key:"+hashAPIKey(apiKey), service.OwnerFromContext(ctx))
+					return note, nil
+				})
+
+			ctx := metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, apiKey)
+			_, err := client.CreateNote(ctx, &notesv1.CreateNoteRequest{Title: note.Title, Description: note.Description})
+			assert.NoError(t, err)
+		})
+
+		t.Run("should identify clients with unknown API keys by their address", func(t *testing.T) {
+			mockService.EXPECT().
+				FetchNotes(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, _ repository.NoteFilter, _ ...repository.NoteField) ([]repository.Note, error) {
+					assert.Equal(t, "ip:bufconn", service.OwnerFromContext(ctx))
+					return nil, nil
+				})
+
+			ctx := metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, gofakeit.UUID())
+			_, err := client.ListNotes(ctx, &notesv1.ListNotesRequest{})
+			assert.NoError(t, err)
+		})
+
+		t.Run("should identify clients of streams", func(t *testing.T) {
+			mockService.EXPECT().
+				StreamNotes(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, _ func(repository.Note) error) error {
+					assert.Equal(t, "key:"+hashAPIKey(apiKey), service.OwnerFromContext(ctx))
+					return nil
+				})
+
+			ctx := metadata.AppendToOutgoingContext(ctx, apiKeyMetadata, apiKey)
+			stream, err := client.StreamNotes(ctx, &notesv1.StreamNotesRequest{})
+			assert.NoError(t, err)
+			_, err = stream.Recv()
+			assert.Equal(t, io.EOF, err)
+		})
+
+		t.Run("should identify clients by their verified certificate first", func(t *testing.T) {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}, Raw: []byte("alice")}
+			ctx := peer.NewContext(ctx, &peer.Peer{
+				Addr:     &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443},
+				AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+			})
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(apiKeyMetadata, apiKey))
+			assert.Equal(t, "cert:alice", server.clientID(ctx))
+
+			cert.Subject.CommonName = ""
+			sum := sha256.Sum256(cert.Raw)
+			assert.Equal(t, "cert:"+hex.EncodeToString(sum[:]), server.clientID(ctx))
+
+			ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443}})
+			assert.Equal(t, "key:"+hashAPIKey(apiKey), server.clientID(ctx))
+
+			ctx = metadata.NewIncomingContext(ctx, metadata.MD{})
+			assert.Equal(t, "ip:192.0.2.1", server.clientID(ctx))
+		})
+	})
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lets clients store notes, but makes them revalidate with the ETag before reusing them.
+const defaultCacheControl = "no-cache"
+
+// Sets the Cache-Control policy of a route, e.g.
+// WithCacheControl(http.MethodGet, "/v1/notes/:id", "private, max-age=60").
+func WithCacheControl(method, route, policy string) Option {
+	return func(s *Server) {
+		s.cacheControl[method+" "+route] = policy
+	}
+}
+
+func defaultCacheControls() map[string]string {
+	return map[string]string{
+		http.MethodGet + " /v1/notes":     defaultCacheControl,
+		http.MethodGet + " /v1/notes/:id": defaultCacheControl,
+	}
+}
+
+// Builds a weak ETag from the parts given. Weak, as representations with
+// equal ETags are equivalent but may not be byte for byte identical.
+func weakETag(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Reports whether any of the ETags in an If-None-Match header weakly match etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the caching headers of a successful response, naming the representation it
+// carries. Only set once the response is known to succeed, so error responses are never
+// cached or revalidated. A zero lastModified is omitted.
+func (s *Server) setCachingHeaders(c *gin.Context, etag string, lastModified time.Time) {
+	if policy, ok := s.cacheControl[c.Request.Method+" "+c.FullPath()]; ok {
+		c.Header("Cache-Control", policy)
+	}
+
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Replies with a 304 status code and the caching headers if the client's copy is still
+// fresh. If-Modified-Since is only checked if checkModifiedSince is set, and is ignored
+// if If-None-Match is sent. Otherwise the handler goes on, calling setCachingHeaders
+// before sending the representation.
+func (s *Server) notModified(c *gin.Context, etag string, lastModified time.Time, checkModifiedSince bool) bool {
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		if !etagMatches(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		ifModifiedSince, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		// HTTP dates only have second precision
+		if !checkModifiedSince || lastModified.IsZero() || err != nil ||
+			lastModified.Truncate(time.Second).After(ifModifiedSince) {
+			return false
+		}
+	}
+
+	s.setCachingHeaders(c, etag, lastModified)
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+func timeKey(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return fmt.Sprint(t.UnixNano())
+}
@@ -0,0 +1,431 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+const (
+	collabPersistInterval = 5 * time.Second
+	collabHistoryLimit    = 1000
+	collabSendBufferSize  = 64
+	collabWriteTimeout    = 10 * time.Second
+	// Fits an op inserting the longest description allowed
+	collabMaxMessageSize = 1 << 20
+	// Clients are disconnected if they don't answer a ping for this long
+	collabPongTimeout  = 60 * time.Second
+	collabPingInterval = collabPongTimeout * 9 / 10
+)
+
+const (
+	collabMessageInit     = "init"
+	collabMessageOp       = "op"
+	collabMessageAck      = "ack"
+	collabMessagePresence = "presence"
+	collabMessageError    = "error"
+)
+
+var errCollabVersion = errors.New("the operation is based on an unknown version of the description")
+
+type collabViewer struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+}
+
+type collabMessage struct {
+	Type        string         `json:"type"`
+	Version     int            `json:"version"`
+	Op          *textOp        `json:"op,omitempty"`
+	ClientID    string         `json:"client_id,omitempty"`
+	Description *string        `json:"description,omitempty"`
+	Viewers     []collabViewer `json:"viewers,omitempty"`
+	Message     string         `json:"message,omitempty"`
+}
+
+type collabClient struct {
+	id   string
+	name string
+	conn *websocket.Conn
+	send chan collabMessage
+}
+
+// Serializes the edits of every client editing the same note.
+type collabRoom struct {
+	noteID  uuid.UUID
+	service service.Service
+
+	mu      sync.Mutex
+	text    []rune
+	version int
+	// history[i] took the description from version historyBase+i to historyBase+i+1
+	history     []textOp
+	historyBase int
+	clients     map[*collabClient]struct{}
+	dirty       bool
+
+	// Closed once the note is loaded, or failed to load with loadErr
+	loaded  chan struct{}
+	loadErr error
+
+	refs int
+	stop chan struct{}
+	done chan struct{}
+	// Closed once the room is removed from its hub, after its final edits are persisted
+	closed chan struct{}
+}
+
+// Keeps track of the rooms with at least one client.
+type collabHub struct {
+	service service.Service
+	mu      sync.Mutex
+	rooms   map[uuid.UUID]*collabRoom
+}
+
+func newCollabHub(svc service.Service) *collabHub {
+	return &collabHub{
+		service: svc,
+		rooms:   map[uuid.UUID]*collabRoom{},
+	}
+}
+
+// Joins the room of the note, loading the note into a new room if it has none.
+// The note is loaded, and the edits of a closing room persisted, without holding h.mu,
+// so rooms of other notes aren't held up.
+func (h *collabHub) acquire(ctx context.Context, id uuid.UUID) (*collabRoom, error) {
+	h.mu.Lock()
+	room, ok := h.rooms[id]
+	for ok && room.refs == 0 {
+		// Wait for the last room to persist its final edits before loading the note again
+		h.mu.Unlock()
+		select {
+		case <-room.closed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		h.mu.Lock()
+		room, ok = h.rooms[id]
+	}
+
+	if !ok {
+		room = &collabRoom{
+			noteID:  id,
+			service: h.service,
+			clients: map[*collabClient]struct{}{},
+			loaded:  make(chan struct{}),
+			stop:    make(chan struct{}),
+			done:    make(chan struct{}),
+			closed:  make(chan struct{}),
+		}
+		h.rooms[id] = room
+	}
+	room.refs++
+	h.mu.Unlock()
+
+	if !ok {
+		room.load(ctx)
+	}
+
+	select {
+	case <-room.loaded:
+	case <-ctx.Done():
+		h.release(room)
+		return nil, ctx.Err()
+	}
+	if room.loadErr != nil {
+		h.release(room)
+		return nil, room.loadErr
+	}
+	return room, nil
+}
+
+func (h *collabHub) release(room *collabRoom) {
+	h.mu.Lock()
+	room.refs--
+	last := room.refs == 0
+	h.mu.Unlock()
+
+	if !last {
+		return
+	}
+
+	// Persist the final edits before anyone else can load the note into a new room
+	if room.loadErr == nil {
+		close(room.stop)
+		<-room.done
+	}
+
+	h.mu.Lock()
+	delete(h.rooms, room.noteID)
+	h.mu.Unlock()
+	close(room.closed)
+}
+
+// Loads the note's description, then persists the room's edits until it is stopped.
+func (r *collabRoom) load(ctx context.Context) {
+	defer close(r.loaded)
+
+	note, err := r.service.FetchNoteByID(ctx, r.noteID)
+	if err != nil {
+		r.loadErr = err
+		return
+	}
+
+	r.text = []rune(note.Description)
+	go r.persistPeriodically()
+}
+
+func (r *collabRoom) persistPeriodically() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(collabPersistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.persist()
+		case <-r.stop:
+			r.persist()
+			return
+		}
+	}
+}
+
+func (r *collabRoom) persist() {
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return
+	}
+	description := string(r.text)
+	r.dirty = false
+	r.mu.Unlock()
+
+	_, err := r.service.UpdateNote(
+		context.Background(), r.noteID, repository.UpdateNoteDTO{Description: &description},
+	)
+	if err != nil {
+		log.Printf("unable to persist collaborative edits to note %s: %v", r.noteID, err)
+
+		// Retrying can't persist a description the service rejects, or a deleted note
+		var validationErr *service.ValidationError
+		if errors.As(err, &validationErr) || errors.Is(err, service.ErrNoteNotFound) {
+			return
+		}
+
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+	}
+}
+
+func (r *collabRoom) join(client *collabClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clients[client] = struct{}{}
+
+	description := string(r.text)
+	r.sendTo(client, collabMessage{
+		Type:        collabMessageInit,
+		Version:     r.version,
+		ClientID:    client.id,
+		Description: &description,
+	})
+	r.broadcastPresence()
+}
+
+func (r *collabRoom) leave(client *collabClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[client]; ok {
+		delete(r.clients, client)
+		close(client.send)
+	}
+	r.broadcastPresence()
+}
+
+func (r *collabRoom) applyOp(client *collabClient, version int, op textOp) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if version < r.historyBase || version > r.version {
+		return errCollabVersion
+	}
+
+	// Rebase the op on every op the client hadn't seen yet
+	op, ok := transformOp(op, r.history[version-r.historyBase:])
+	if ok {
+		if err := op.validate(r.text); err != nil {
+			return err
+		}
+
+		// Reject ops the note couldn't be persisted with, such as deleting every character
+		text := op.apply(r.text)
+		if err := service.ValidateDescription(string(text)); err != nil {
+			return err
+		}
+
+		r.text = text
+		r.version++
+		r.dirty = true
+
+		r.history = append(r.history, op)
+		if len(r.history) > collabHistoryLimit {
+			r.historyBase += len(r.history) - collabHistoryLimit
+			r.history = r.history[len(r.history)-collabHistoryLimit:]
+		}
+
+		for other := range r.clients {
+			if other != client {
+				r.sendTo(other, collabMessage{
+					Type: collabMessageOp, Version: r.version, Op: &op, ClientID: client.id,
+				})
+			}
+		}
+	}
+
+	r.sendTo(client, collabMessage{Type: collabMessageAck, Version: r.version})
+	return nil
+}
+
+// Must be called with r.mu held.
+func (r *collabRoom) broadcastPresence() {
+	viewers := make([]collabViewer, 0, len(r.clients))
+	for client := range r.clients {
+		viewers = append(viewers, collabViewer{ClientID: client.id, Name: client.name})
+	}
+
+	for client := range r.clients {
+		r.sendTo(client, collabMessage{Type: collabMessagePresence, Version: r.version, Viewers: viewers})
+	}
+}
+
+func (r *collabRoom) sendError(client *collabClient, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sendTo(client, collabMessage{Type: collabMessageError, Version: r.version, Message: message})
+}
+
+// Must be called with r.mu held.
+func (r *collabRoom) sendTo(client *collabClient, message collabMessage) {
+	if _, ok := r.clients[client]; !ok {
+		return
+	}
+
+	select {
+	case client.send <- message:
+	default:
+		// Disconnect clients that can't keep up, they can rejoin and resync
+		delete(r.clients, client)
+		close(client.send)
+	}
+}
+
+// Writes the messages sent to the client, pinging it in between so dead connections time out.
+func (c *collabClient) writeMessages() {
+	defer c.conn.Close()
+
+	ping := time.NewTicker(collabPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(collabWriteTimeout))
+			if err := c.conn.WriteJSON(message); err != nil {
+				log.Printf("unable to write to collaboration client %s: %v", c.id, err)
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(collabWriteTimeout)); err != nil {
+				log.Printf("unable to ping collaboration client %s: %v", c.id, err)
+				return
+			}
+		}
+	}
+}
+
+var collabUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+func (s *Server) collabHandler(c *gin.Context) {
+	id, err := uuid.Parse(strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		log.Printf("invalid UUID: %v", err)
+		s.sendBadRequest(c, "invalid note ID")
+		return
+	}
+
+	room, err := s.collab.acquire(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("unable to open collaboration room: %v", err)
+
+		switch {
+		case errors.Is(err, service.ErrNoteNotFound):
+			s.sendNotFound(c, err.Error())
+		default:
+			s.sendInternalError(c, err.Error())
+		}
+		return
+	}
+	defer s.collab.release(room)
+
+	conn, err := collabUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("unable to upgrade to a websocket connection: %v", err)
+		return
+	}
+
+	client := &collabClient{
+		id:   uuid.NewString(),
+		name: strings.TrimSpace(c.Query("name")),
+		conn: conn,
+		send: make(chan collabMessage, collabSendBufferSize),
+	}
+	go client.writeMessages()
+
+	room.join(client)
+	defer room.leave(client)
+
+	conn.SetReadLimit(collabMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(collabPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(collabPongTimeout))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message collabMessage
+		if err := json.Unmarshal(data, &message); err != nil || message.Type != collabMessageOp || message.Op == nil {
+			room.sendError(client, "invalid message")
+			continue
+		}
+
+		if err := room.applyOp(client, message.Version, *message.Op); err != nil {
+			room.sendError(client, err.Error())
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package http
+
+import "errors"
+
+var errInvalidTextOp = errors.New("an operation must either insert text or delete characters within the description")
+
+// A single edit to a note's description, with positions counted in runes.
+type textOp struct {
+	Position int    `json:"position"`
+	Insert   string `json:"insert,omitempty"`
+	Delete   int    `json:"delete,omitempty"`
+}
+
+func (op textOp) validate(text []rune) error {
+	insert, remove := op.Insert != "", op.Delete > 0
+	if insert == remove || op.Position < 0 || op.Delete < 0 {
+		return errInvalidTextOp
+	}
+	if op.Position > len(text) || op.Position+op.Delete > len(text) {
+		return errInvalidTextOp
+	}
+	return nil
+}
+
+func (op textOp) apply(text []rune) []rune {
+	if op.Insert != "" {
+		insert := []rune(op.Insert)
+		result := make([]rune, 0, len(text)+len(insert))
+		result = append(result, text[:op.Position]...)
+		result = append(result, insert...)
+		return append(result, text[op.Position:]...)
+	}
+
+	result := make([]rune, 0, len(text)-op.Delete)
+	result = append(result, text[:op.Position]...)
+	return append(result, text[op.Position+op.Delete:]...)
+}
+
+// Rewrites op so it can be applied after prior, which was applied concurrently but
+// serialized first. Returns false if nothing is left of op once prior is applied.
+func (op textOp) transform(prior textOp) (textOp, bool) {
+	if prior.Insert != "" {
+		inserted := len([]rune(prior.Insert))
+
+		switch {
+		case prior.Position <= op.Position:
+			op.Position += inserted
+		case op.Insert == "" && prior.Position < op.Position+op.Delete:
+			// Text inserted inside a deleted range is deleted along with it
+			op.Delete += inserted
+		}
+
+		return op, true
+	}
+
+	priorEnd := prior.Position + prior.Delete
+
+	if op.Insert != "" {
+		switch {
+		case op.Position >= priorEnd:
+			op.Position -= prior.Delete
+		case op.Position > prior.Position:
+			op.Position = prior.Position
+		}
+		return op, true
+	}
+
+	end := op.Position + op.Delete
+	switch {
+	case end <= prior.Position:
+	case op.Position >= priorEnd:
+		op.Position -= prior.Delete
+	default:
+		// Only delete what the prior op hasn't already
+		overlap := min(end, priorEnd) - max(op.Position, prior.Position)
+		op.Delete -= overlap
+		op.Position = min(op.Position, prior.Position)
+	}
+
+	return op, op.Delete > 0
+}
+
+// Transforms op against every op in history, in order.
+func transformOp(op textOp, history []textOp) (textOp, bool) {
+	for _, prior := range history {
+		var ok bool
+		if op, ok = op.transform(prior); !ok {
+			return op, false
+		}
+	}
+	return op, true
+}
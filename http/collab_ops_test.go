@@ -0,0 +1,140 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextOp(t *testing.T) {
+	t.Run("should transform ops against concurrent ops serialized before them", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			prior textOp
+			op    textOp
+			want  textOp
+			// The description once prior and the transformed op are applied to "hello world"
+			text string
+		}{
+			{
+				name:  "insert after a prior insert",
+				prior: textOp{Position: 5, Insert: ","},
+				op:    textOp{Position: 11, Insert: "!"},
+				want:  textOp{Position: 12, Insert: "!"},
+				text:  "hello, world!",
+			},
+			{
+				name:  "insert at the position of a prior insert, after it",
+				prior: textOp{Position: 0, Insert: "oh "},
+				op:    textOp{Position: 0, Insert: "well "},
+				want:  textOp{Position: 3, Insert: "well "},
+				text:  "oh well hello world",
+			},
+			{
+				name:  "insert before a prior insert",
+				prior: textOp{Position: 11, Insert: "!"},
+				op:    textOp{Position: 5, Insert: ","},
+				want:  textOp{Position: 5, Insert: ","},
+				text:  "hello, world!",
+			},
+			{
+				name:  "insert after a prior delete",
+				prior: textOp{Position: 0, Delete: 6},
+				op:    textOp{Position: 11, Insert: "!"},
+				want:  textOp{Position: 5, Insert: "!"},
+				text:  "world!",
+			},
+			{
+				name:  "insert inside a prior delete",
+				prior: textOp{Position: 0, Delete: 5},
+				op:    textOp{Position: 2, Insert: "y"},
+				want:  textOp{Position: 0, Insert: "y"},
+				text:  "y world",
+			},
+			{
+				name:  "delete after a prior insert",
+				prior: textOp{Position: 0, Insert: "oh "},
+				op:    textOp{Position: 5, Delete: 6},
+				want:  textOp{Position: 8, Delete: 6},
+				text:  "oh hello",
+			},
+			{
+				name:  "delete around a prior insert",
+				prior: textOp{Position: 8, Insert: "o"},
+				op:    textOp{Position: 5, Delete: 6},
+				want:  textOp{Position: 5, Delete: 7},
+				text:  "hello",
+			},
+			{
+				name:  "delete before a prior delete",
+				prior: textOp{Position: 6, Delete: 5},
+				op:    textOp{Position: 0, Delete: 6},
+				want:  textOp{Position: 0, Delete: 6},
+				text:  "",
+			},
+			{
+				name:  "delete after a prior delete",
+				prior: textOp{Position: 0, Delete: 6},
+				op:    textOp{Position: 6, Delete: 5},
+				want:  textOp{Position: 0, Delete: 5},
+				text:  "",
+			},
+			{
+				name:  "delete overlapping a prior delete",
+				prior: textOp{Position: 0, Delete: 7},
+				op:    textOp{Position: 5, Delete: 6},
+				want:  textOp{Position: 0, Delete: 4},
+				text:  "",
+			},
+			{
+				name:  "delete containing a prior delete",
+				prior: textOp{Position: 2, Delete: 2},
+				op:    textOp{Position: 0, Delete: 6},
+				want:  textOp{Position: 0, Delete: 4},
+				text:  "world",
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				op, ok := test.op.transform(test.prior)
+				assert.True(t, ok)
+				assert.Equal(t, test.want, op)
+
+				text := test.prior.apply([]rune("hello world"))
+				if assert.NoError(t, op.validate(text)) {
+					assert.Equal(t, test.text, string(op.apply(text)))
+				}
+			})
+		}
+	})
+
+	t.Run("should drop deletes of text a prior op already deleted", func(t *testing.T) {
+		_, ok := textOp{Position: 1, Delete: 3}.transform(textOp{Position: 0, Delete: 5})
+		assert.False(t, ok)
+	})
+
+	t.Run("should transform ops against every op in the history, in order", func(t *testing.T) {
+		history := []textOp{{Position: 0, Insert: "oh "}, {Position: 3, Delete: 6}}
+
+		op, ok := transformOp(textOp{Position: 11, Insert: "!"}, history)
+		assert.True(t, ok)
+		assert.Equal(t, textOp{Position: 8, Insert: "!"}, op)
+
+		_, ok = transformOp(textOp{Position: 0, Delete: 5}, history)
+		assert.False(t, ok)
+	})
+
+	t.Run("should reject ops that neither insert nor delete, or reach past the text", func(t *testing.T) {
+		text := []rune("hello")
+		for _, op := range []textOp{
+			{Position: 0},
+			{Position: 0, Insert: "a", Delete: 1},
+			{Position: -1, Insert: "a"},
+			{Position: 6, Insert: "a"},
+			{Position: 3, Delete: 3},
+		} {
+			assert.ErrorIs(t, op.validate(text), errInvalidTextOp, "%+v", op)
+		}
+	})
+}
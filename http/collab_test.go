@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollabHub(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	t.Run("should load notes into rooms without holding up the rooms of other notes", func(t *testing.T) {
+		hub := newCollabHub(mockService)
+		slowID, fastID := uuid.New(), uuid.New()
+
+		loading := make(chan struct{})
+		unblock := make(chan struct{})
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), slowID).
+			DoAndReturn(func(context.Context, uuid.UUID, ...repository.NoteField) (*repository.Note, error) {
+				close(loading)
+				<-unblock
+				return &repository.Note{ID: slowID, Description: "slow"}, nil
+			})
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), fastID).
+			Return(&repository.Note{ID: fastID, Description: "fast"}, nil)
+
+		slow := make(chan *collabRoom)
+		go func() {
+			room, err := hub.acquire(ctx, slowID)
+			assert.NoError(t, err)
+			slow <- room
+		}()
+		<-loading
+
+		room, err := hub.acquire(ctx, fastID)
+		assert.NoError(t, err)
+		assert.Equal(t, "fast", string(room.text))
+		hub.release(room)
+
+		close(unblock)
+		hub.release(<-slow)
+	})
+
+	t.Run("should persist the final edits of a room before loading its note again", func(t *testing.T) {
+		hub := newCollabHub(mockService)
+		id := uuid.New()
+
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), id).
+			Return(&repository.Note{ID: id, Description: "hello"}, nil)
+		room, err := hub.acquire(ctx, id)
+		assert.NoError(t, err)
+		assert.NoError(t, room.applyOp(&collabClient{}, 0, textOp{Position: 5, Insert: "!"}))
+
+		var persisted atomic.Bool
+		persisting := make(chan struct{})
+		unblock := make(chan struct{})
+		mockService.EXPECT().UpdateNote(gomock.Any(), id, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ uuid.UUID, dto repository.UpdateNoteDTO) (*repository.Note, error) {
+				assert.Equal(t, "hello!", *dto.Description)
+				close(persisting)
+				<-unblock
+				persisted.Store(true)
+				return &repository.Note{ID: id, Description: *dto.Description}, nil
+			})
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), id).
+			DoAndReturn(func(context.Context, uuid.UUID, ...repository.NoteField) (*repository.Note, error) {
+				assert.True(t, persisted.Load())
+				return &repository.Note{ID: id, Description: "hello!"}, nil
+			})
+
+		go hub.release(room)
+		<-persisting
+
+		reopened := make(chan *collabRoom)
+		go func() {
+			room, err := hub.acquire(ctx, id)
+			assert.NoError(t, err)
+			reopened <- room
+		}()
+
+		select {
+		case <-reopened:
+			t.Fatal("the note was loaded again before its edits were persisted")
+		case <-time.After(100 * time.Millisecond):
+		}
+		close(unblock)
+
+		room = <-reopened
+		assert.Equal(t, "hello!", string(room.text))
+		hub.release(room)
+	})
+
+	t.Run("should reject ops that would leave the description invalid", func(t *testing.T) {
+		room := &collabRoom{text: []rune("hi"), clients: map[*collabClient]struct{}{}}
+
+		var validationErr *service.ValidationError
+		err := room.applyOp(&collabClient{}, 0, textOp{Position: 0, Delete: 2})
+		assert.ErrorAs(t, err, &validationErr)
+
+		err = room.applyOp(&collabClient{}, 0, textOp{Position: 2, Insert: strings.Repeat("a", service.MaxDescriptionLength)})
+		assert.ErrorAs(t, err, &validationErr)
+
+		assert.Equal(t, "hi", string(room.text))
+		assert.Zero(t, room.version)
+		assert.False(t, room.dirty)
+	})
+
+	t.Run("should not retry persisting edits the service rejects", func(t *testing.T) {
+		room := &collabRoom{noteID: uuid.New(), service: mockService, text: []rune("hi"), dirty: true}
+
+		mockService.EXPECT().UpdateNote(gomock.Any(), room.noteID, gomock.Any()).
+			Return(nil, &service.ValidationError{Violations: []service.Violation{{Field: "description", Message: "must not be empty"}}})
+		room.persist()
+		assert.False(t, room.dirty)
+
+		// Nothing is left to persist, so the service isn't called again
+		room.persist()
+	})
+
+	t.Run("should stop persisting edits once the note is deleted", func(t *testing.T) {
+		hub := newCollabHub(mockService)
+		id := uuid.New()
+
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), id).
+			Return(&repository.Note{ID: id, Description: "hello"}, nil)
+		room, err := hub.acquire(ctx, id)
+		assert.NoError(t, err)
+
+		// The note is deleted while the room is open, so only the first edit is tried
+		mockService.EXPECT().UpdateNote(gomock.Any(), id, gomock.Any()).Return(nil, service.ErrNoteNotFound)
+		assert.NoError(t, room.applyOp(&collabClient{}, 0, textOp{Position: 5, Insert: "!"}))
+		room.persist()
+		assert.False(t, room.dirty)
+
+		room.persist()
+		hub.release(room)
+	})
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Configures which other origins browsers let call the API.
+type CORSConfig struct {
+	// Origins such as https://app.example.com, or * for any origin
+	AllowedOrigins []string
+	// Defaults to every method the API serves
+	AllowedMethods []string
+	// Request headers allowed besides the CORS-safelisted ones, defaulting to the headers the API reads
+	AllowedHeaders []string
+	// Lets browsers send cookies and HTTP authentication. Never allowed for origins only matched by *.
+	AllowCredentials bool
+	// How long browsers can cache the result of a preflight request
+	MaxAge time.Duration
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete}
+	defaultCORSHeaders = []string{"Content-Type", "If-None-Match", "If-Modified-Since", "Last-Event-ID", apiKeyHeader}
+
+	// Response headers scripts can read besides the CORS-safelisted ones
+	corsExposedHeaders = []string{
+		"ETag", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+	}
+)
+
+// Answers CORS preflight requests, and lets browsers share responses with the origins allowed.
+// Requests from other origins are still served, but browsers won't share the responses.
+func WithCORS(config CORSConfig) Option {
+	if len(config.AllowedMethods) == 0 {
+		config.AllowedMethods = defaultCORSMethods
+	}
+	if len(config.AllowedHeaders) == 0 {
+		config.AllowedHeaders = defaultCORSHeaders
+	}
+
+	return func(s *Server) {
+		s.corsConfig = &config
+	}
+}
+
+// Reports whether the origin is allowed, and if it is only allowed by a wildcard.
+func (config *CORSConfig) allowsOrigin(origin string) (allowed, wildcard bool) {
+	for _, allowedOrigin := range config.AllowedOrigins {
+		if strings.EqualFold(allowedOrigin, origin) {
+			return true, false
+		}
+		if allowedOrigin == "*" {
+			wildcard = true
+		}
+	}
+	return wildcard, wildcard
+}
+
+func (s *Server) cors(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if s.corsConfig == nil || origin == "" {
+		c.Next()
+		return
+	}
+
+	// Responses differ by origin, so mustn't be cached across origins
+	c.Writer.Header().Add("Vary", "Origin")
+
+	requestedMethod := c.GetHeader("Access-Control-Request-Method")
+	preflight := c.Request.Method == http.MethodOptions && requestedMethod != ""
+	if preflight {
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	allowed, wildcard := s.corsConfig.allowsOrigin(origin)
+	if !allowed || (preflight && !slices.Contains(s.corsConfig.AllowedMethods, requestedMethod)) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+		return
+	}
+
+	if s.corsConfig.AllowCredentials && !wildcard {
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Allow-Credentials", "true")
+	} else if wildcard {
+		c.Header("Access-Control-Allow-Origin", "*")
+	} else {
+		c.Header("Access-Control-Allow-Origin", origin)
+	}
+
+	if !preflight {
+		c.Header("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		c.Next()
+		return
+	}
+
+	c.Header("Access-Control-Allow-Methods", strings.Join(s.corsConfig.AllowedMethods, ", "))
+	c.Header("Access-Control-Allow-Headers", strings.Join(s.corsConfig.AllowedHeaders, ", "))
+	if s.corsConfig.MaxAge > 0 {
+		c.Header("Access-Control-Max-Age", strconv.Itoa(seconds(s.corsConfig.MaxAge)))
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
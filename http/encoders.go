@@ -0,0 +1,352 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// Encodes notes in a media type, for the routes fetching them to negotiate with the Accept header.
+// Notes are passed projected onto the fields given, which list every field if none were asked for.
+type Encoder interface {
+	EncodeNote(w io.Writer, note repository.Note, fields []repository.NoteField) error
+	EncodeNotes(w io.Writer, notes []repository.Note, fields []repository.NoteField) error
+}
+
+const (
+	mediaTypeJSON     = "application/json"
+	mediaTypeNDJSON   = "application/x-ndjson"
+	mediaTypeCSV      = "text/csv"
+	mediaTypeYAML     = "application/yaml"
+	mediaTypeMarkdown = "text/markdown"
+	mediaTypeHTML     = "text/html"
+)
+
+// Encodes notes in the media type given, replacing its encoder if it has one. Media types
+// are preferred in the order they were registered when the client accepts several equally.
+func WithEncoder(mediaType string, encoder Encoder) Option {
+	return func(s *Server) {
+		if _, ok := s.encoders[mediaType]; !ok {
+			s.mediaTypes = append(s.mediaTypes, mediaType)
+		}
+		s.encoders[mediaType] = encoder
+	}
+}
+
+func defaultEncoders() ([]string, map[string]Encoder) {
+	mediaTypes := []string{mediaTypeJSON, mediaTypeNDJSON, mediaTypeCSV, mediaTypeYAML, mediaTypeMarkdown}
+	return mediaTypes, map[string]Encoder{
+		mediaTypeJSON:     jsonEncoder{},
+		mediaTypeNDJSON:   ndjsonEncoder{},
+		mediaTypeCSV:      csvEncoder{},
+		mediaTypeYAML:     yamlEncoder{},
+		mediaTypeMarkdown: markdownEncoder{},
+	}
+}
+
+// A media range of an Accept header, e.g. text/* with a quality of 0.5.
+type acceptedRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Parses an Accept header, skipping malformed media ranges.
+func parseAccept(header string) []acceptedRange {
+	var ranges []acceptedRange
+	for value := range strings.SplitSeq(header, ",") {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(value)
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptedRange{mediaType: mediaType, quality: quality})
+	}
+	return ranges
+}
+
+// Returns the quality the client gives a media type, taken from the most
+// specific range matching it, e.g. text/csv over text/* over */*.
+func acceptedQuality(ranges []acceptedRange, mediaType string) float64 {
+	kind, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, r := range ranges {
+		rangeSpecificity := -1
+		switch r.mediaType {
+		case mediaType:
+			rangeSpecificity = 2
+		case kind + "/*":
+			rangeSpecificity = 1
+		case "*/*":
+			rangeSpecificity = 0
+		}
+		if rangeSpecificity > specificity {
+			quality, specificity = r.quality, rangeSpecificity
+		}
+	}
+	return quality
+}
+
+// Picks the offered media type the client accepts with the highest quality,
+// the first offered on ties or if the client sent no Accept header.
+func negotiateMediaType(accept string, offered []string) (string, bool) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return offered[0], true
+	}
+
+	best, bestQuality := "", 0.0
+	for _, mediaType := range offered {
+		if quality := acceptedQuality(ranges, mediaType); quality > bestQuality {
+			best, bestQuality = mediaType, quality
+		}
+	}
+	return best, best != ""
+}
+
+// Negotiates the media type of the response among those offered, replying with
+// a 406 status code if the client accepts none of them. As the response depends
+// on the Accept header, caches are told to vary on it.
+func (s *Server) negotiate(c *gin.Context, offered ...string) (string, bool) {
+	c.Writer.Header().Add("Vary", "Accept")
+
+	mediaType, ok := negotiateMediaType(c.GetHeader("Accept"), offered)
+	if !ok {
+		s.sendNotAcceptable(c, offered)
+	}
+	return mediaType, ok
+}
+
+func (s *Server) sendNotAcceptable(c *gin.Context, offered []string) {
+	c.JSON(http.StatusNotAcceptable, gin.H{
+		"message": "the response can only be encoded as " + strings.Join(offered, ", "),
+	})
+}
+
+// Sends a note encoded in a media type negotiated with negotiate.
+func (s *Server) sendNote(c *gin.Context, mediaType string, note repository.Note, fields []repository.NoteField) {
+	c.Header("Content-Type", mediaType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := s.encoders[mediaType].EncodeNote(c.Writer, note, fields); err != nil {
+		log.Printf("unable to encode note as %s: %v", mediaType, err)
+	}
+}
+
+// Sends notes encoded in a media type negotiated with negotiate.
+func (s *Server) sendNotes(c *gin.Context, mediaType string, notes []repository.Note, fields []repository.NoteField) {
+	c.Header("Content-Type", mediaType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := s.encoders[mediaType].EncodeNotes(c.Writer, notes, fields); err != nil {
+		log.Printf("unable to encode notes as %s: %v", mediaType, err)
+	}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) EncodeNote(w io.Writer, note repository.Note, _ []repository.NoteField) error {
+	return json.NewEncoder(w).Encode(note)
+}
+
+func (jsonEncoder) EncodeNotes(w io.Writer, notes []repository.Note, _ []repository.NoteField) error {
+	if notes == nil {
+		notes = []repository.Note{}
+	}
+	return json.NewEncoder(w).Encode(notes)
+}
+
+// Writes a JSON object per line, flushing every line so clients can process
+// notes as they arrive.
+type ndjsonEncoder struct{}
+
+func (e ndjsonEncoder) EncodeNote(w io.Writer, note repository.Note, fields []repository.NoteField) error {
+	return e.EncodeNotes(w, []repository.Note{note}, fields)
+}
+
+func (ndjsonEncoder) EncodeNotes(w io.Writer, notes []repository.Note, _ []repository.NoteField) error {
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for _, note := range notes {
+		if err := encoder.Encode(note); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
+// Writes a header row of the fields, then a row per note.
+type csvEncoder struct{}
+
+func (e csvEncoder) EncodeNote(w io.Writer, note repository.Note, fields []repository.NoteField) error {
+	return e.EncodeNotes(w, []repository.Note{note}, fields)
+}
+
+func (csvEncoder) EncodeNotes(w io.Writer, notes []repository.Note, fields []repository.NoteField) error {
+	writer := csv.NewWriter(w)
+
+	record := make([]string, len(fields))
+	for i, field := range fields {
+		record[i] = string(field)
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		for i, field := range fields {
+			record[i] = csvCell(formatValue(note.Value(field)))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Prefixes cells spreadsheets would run as formulas with a quote, which they show the cell
+// without, so notes written by other clients can't run formulas when a CSV is opened.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// Formats a field of a note as text, empty if it is unset.
+func formatValue(value any) string {
+	switch value := value.(type) {
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case *time.Time:
+		if value == nil {
+			return ""
+		}
+		return value.Format(time.RFC3339Nano)
+	case uuid.UUID:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Writes a mapping per note, with its fields in order.
+type yamlEncoder struct{}
+
+func (yamlEncoder) EncodeNote(w io.Writer, note repository.Note, fields []repository.NoteField) error {
+	node, err := yamlNote(note, fields)
+	if err != nil {
+		return err
+	}
+	return encodeYAML(w, node)
+}
+
+func (yamlEncoder) EncodeNotes(w io.Writer, notes []repository.Note, fields []repository.NoteField) error {
+	sequence := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if len(notes) == 0 {
+		sequence.Style = yaml.FlowStyle
+	}
+	for _, note := range notes {
+		node, err := yamlNote(note, fields)
+		if err != nil {
+			return err
+		}
+		sequence.Content = append(sequence.Content, node)
+	}
+	return encodeYAML(w, sequence)
+}
+
+func yamlNote(note repository.Note, fields []repository.NoteField) (*yaml.Node, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, field := range fields {
+		value := &yaml.Node{}
+		if err := value.Encode(note.Value(field)); err != nil {
+			return nil, err
+		}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: string(field)}, value)
+	}
+	return mapping, nil
+}
+
+func encodeYAML(w io.Writer, node *yaml.Node) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Writes a note as a document headed by its title, listing its other fields
+// before its description. Notes of a list are separated by thematic breaks.
+type markdownEncoder struct{}
+
+func (markdownEncoder) EncodeNote(w io.Writer, note repository.Note, fields []repository.NoteField) error {
+	_, err := io.WriteString(w, markdownNote(note, fields))
+	return err
+}
+
+func (markdownEncoder) EncodeNotes(w io.Writer, notes []repository.Note, fields []repository.NoteField) error {
+	for i, note := range notes {
+		document := markdownNote(note, fields)
+		if i > 0 {
+			document = "\n---\n\n" + document
+		}
+		if _, err := io.WriteString(w, document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func markdownNote(note repository.Note, fields []repository.NoteField) string {
+	var b strings.Builder
+	if slices.Contains(fields, repository.NoteFieldTitle) {
+		b.WriteString("# " + note.Title + "\n\n")
+	}
+
+	listed := false
+	for _, field := range fields {
+		if field == repository.NoteFieldTitle || field == repository.NoteFieldDescription {
+			continue
+		}
+		if value := formatValue(note.Value(field)); value != "" {
+			b.WriteString("- " + string(field) + ": " + value + "\n")
+			listed = true
+		}
+	}
+	if listed {
+		b.WriteString("\n")
+	}
+
+	if slices.Contains(fields, repository.NoteFieldDescription) {
+		b.WriteString(strings.TrimRight(note.Description, "\n") + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+const (
+	eventsBufferSize = 64
+	eventsKeepAlive  = 15 * time.Second
+	// How many missed events are fetched at a time when a client resumes
+	eventsReplayPageSize = 100
+)
+
+var errEventsListenerStopped = errors.New("the note events listener stopped")
+
+// Fans note events out to every connected subscriber.
+// The database listener only runs while there is at least one subscriber.
+type eventHub struct {
+	service     service.Service
+	mu          sync.Mutex
+	subscribers map[chan repository.NoteEvent]struct{}
+	stop        context.CancelFunc
+	// Closed once the running listener is listening, and once it stops
+	listening, stopped chan struct{}
+}
+
+func newEventHub(svc service.Service) *eventHub {
+	return &eventHub{
+		service:     svc,
+		subscribers: map[chan repository.NoteEvent]struct{}{},
+	}
+}
+
+// Returns a channel of the events committed from now on, once the listener is listening,
+// so events fetched after it returns can't be missed. The channel must be unsubscribed.
+func (h *eventHub) subscribe(ctx context.Context) (chan repository.NoteEvent, error) {
+	h.mu.Lock()
+	ch := make(chan repository.NoteEvent, eventsBufferSize)
+	h.subscribers[ch] = struct{}{}
+
+	if h.stop == nil {
+		listenCtx, cancel := context.WithCancel(context.Background())
+		h.stop = cancel
+		h.listening, h.stopped = make(chan struct{}), make(chan struct{})
+		go h.listen(listenCtx, h.listening, h.stopped)
+	}
+	listening, stopped := h.listening, h.stopped
+	h.mu.Unlock()
+
+	select {
+	case <-listening:
+		return ch, nil
+	case <-stopped:
+		return ch, errEventsListenerStopped
+	case <-ctx.Done():
+		return ch, ctx.Err()
+	}
+}
+
+func (h *eventHub) unsubscribe(ch chan repository.NoteEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(ch)
+}
+
+func (h *eventHub) broadcast(event repository.NoteEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Drop subscribers that can't keep up, they can resume with Last-Event-ID
+			h.remove(ch)
+		}
+	}
+
+	return nil
+}
+
+// Closes the subscriber's channel, stopping the listener once none are left.
+// Must be called with h.mu held.
+func (h *eventHub) remove(ch chan repository.NoteEvent) {
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+
+	if len(h.subscribers) == 0 && h.stop != nil {
+		h.stop()
+		h.stop = nil
+	}
+}
+
+func (h *eventHub) listen(ctx context.Context, listening, stopped chan struct{}) {
+	defer close(stopped)
+	err := h.service.ListenNoteEvents(ctx, func() { close(listening) }, h.broadcast)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Stopped once every subscriber left
+	if ctx.Err() != nil {
+		return
+	}
+	log.Printf("note events listener stopped: %v", err)
+
+	// Subscribers may have missed events since, so they are dropped to resume with
+	// Last-Event-ID, and the next subscriber starts a new listener
+	for ch := range h.subscribers {
+		h.remove(ch)
+	}
+	if h.stop != nil {
+		h.stop()
+		h.stop = nil
+	}
+}
+
+func (s *Server) noteEventsHandler(c *gin.Context) {
+	var lastEventID int64
+	if header := strings.TrimSpace(c.GetHeader("Last-Event-ID")); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			log.Printf("invalid Last-Event-ID: %v", err)
+			s.sendBadRequest(c, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+
+	// Listen before replaying, so no events are lost in between
+	events, err := s.events.subscribe(c.Request.Context())
+	defer s.events.unsubscribe(events)
+	if err != nil {
+		log.Printf("unable to listen for note events: %v", err)
+		s.sendInternalError(c, "unable to listen for note events")
+		return
+	}
+
+	var page []repository.NoteEvent
+	if lastEventID > 0 {
+		page, err = s.service.FetchNoteEventsSince(c, lastEventID, eventsReplayPageSize)
+		if err != nil {
+			log.Printf("unable to fetch note events: %v", err)
+			s.sendInternalError(c, err.Error())
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// Replay the missed events a page at a time
+	var lastReplayed *repository.NoteEvent
+	for {
+		for _, event := range page {
+			s.sendEvent(c, event)
+			lastEventID, lastReplayed = event.ID, &event
+		}
+		c.Writer.Flush()
+		if len(page) < eventsReplayPageSize {
+			break
+		}
+
+		page, err = s.service.FetchNoteEventsSince(c, lastEventID, eventsReplayPageSize)
+		if err != nil {
+			// Ending the stream makes the client resume after the last event sent
+			log.Printf("unable to fetch note events: %v", err)
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			// Events are released in order, so the events listened for while replaying
+			// are sent once by skipping those replayed
+			if lastReplayed == nil || event.After(*lastReplayed) {
+				s.sendEvent(c, event)
+			}
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (s *Server) sendEvent(c *gin.Context, event repository.NoteEvent) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatInt(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
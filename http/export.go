@@ -0,0 +1,244 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+)
+
+// Writes notes to an export one at a time, as they are streamed.
+type noteExporter interface {
+	export(note repository.Note) error
+	// Writes what's left of the export once every note is in it
+	close() error
+}
+
+type exportFormat struct {
+	mediaType   string
+	newExporter func(w io.Writer) noteExporter
+}
+
+// The formats of GET /v1/notes/export, the first being the default.
+var exportFormatNames = []string{"ndjson", "csv", "zip"}
+
+var exportFormats = map[string]exportFormat{
+	"ndjson": {mediaTypeNDJSON, newNDJSONExporter},
+	"csv":    {mediaTypeCSV, newCSVExporter},
+	"zip":    {"application/zip", newZIPExporter},
+}
+
+// Streams every note without holding them in memory. The status code is sent with
+// the first note, so errors past it abort the response instead, for clients to see
+// the transfer failed rather than take the export cut short for a complete one.
+func (s *Server) exportNotesHandler(c *gin.Context) {
+	name := c.DefaultQuery("format", exportFormatNames[0])
+	format, ok := exportFormats[name]
+	if !ok {
+		s.sendBadRequest(c, "invalid export format")
+		return
+	}
+
+	var exporter noteExporter
+	start := func() {
+		c.Header("Content-Type", format.mediaType)
+		c.Header("Content-Disposition", `attachment; filename="notes.`+name+`"`)
+		c.Status(http.StatusOK)
+		exporter = format.newExporter(c.Writer)
+	}
+
+	err := s.service.StreamNotes(c, func(note repository.Note) error {
+		if exporter == nil {
+			start()
+		}
+		return exporter.export(note)
+	})
+	if err != nil {
+		log.Printf("unable to export notes: %v", err)
+		if exporter == nil {
+			s.sendInternalError(c, err.Error())
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	if exporter == nil {
+		start()
+	}
+	if err := exporter.close(); err != nil {
+		log.Printf("unable to finish the export of notes: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func flush(w io.Writer) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+type ndjsonExporter struct {
+	w       io.Writer
+	encoder *json.Encoder
+}
+
+func newNDJSONExporter(w io.Writer) noteExporter {
+	return &ndjsonExporter{w: w, encoder: json.NewEncoder(w)}
+}
+
+func (e *ndjsonExporter) export(note repository.Note) error {
+	if err := e.encoder.Encode(note); err != nil {
+		return err
+	}
+	flush(e.w)
+	return nil
+}
+
+func (e *ndjsonExporter) close() error {
+	return nil
+}
+
+type csvExporter struct {
+	w           io.Writer
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVExporter(w io.Writer) noteExporter {
+	return &csvExporter{w: w, writer: csv.NewWriter(w)}
+}
+
+func (e *csvExporter) writeHeader() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+
+	record := make([]string, len(repository.NoteFields))
+	for i, field := range repository.NoteFields {
+		record[i] = string(field)
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvExporter) export(note repository.Note) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	record := make([]string, len(repository.NoteFields))
+	for i, field := range repository.NoteFields {
+		record[i] = csvCell(formatValue(note.Value(field)))
+	}
+	if err := e.writer.Write(record); err != nil {
+		return err
+	}
+
+	e.writer.Flush()
+	flush(e.w)
+	return e.writer.Error()
+}
+
+func (e *csvExporter) close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// Writes a Markdown file per note, with the fields other than the
+// description as YAML front matter.
+type zipExporter struct {
+	w      io.Writer
+	writer *zip.Writer
+}
+
+func newZIPExporter(w io.Writer) noteExporter {
+	return &zipExporter{w: w, writer: zip.NewWriter(w)}
+}
+
+func (e *zipExporter) export(note repository.Note) error {
+	document, err := markdownFile(note)
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{Name: exportFileName(note), Method: zip.Deflate, Modified: note.CreatedAt}
+	if note.UpdatedAt != nil {
+		header.Modified = *note.UpdatedAt
+	}
+	file, err := e.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(document); err != nil {
+		return err
+	}
+
+	if err := e.writer.Flush(); err != nil {
+		return err
+	}
+	flush(e.w)
+	return nil
+}
+
+func (e *zipExporter) close() error {
+	return e.writer.Close()
+}
+
+// Returns the note as a Markdown document with front matter, e.g.
+//
+//	---
+//	id: 0b6f...
+//	title: Groceries
+//	---
+//
+//	Milk and eggs
+func markdownFile(note repository.Note) ([]byte, error) {
+	fields := slices.DeleteFunc(slices.Clone(repository.NoteFields), func(field repository.NoteField) bool {
+		return field == repository.NoteFieldDescription
+	})
+	frontMatter, err := yamlNote(note, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	if err := encodeYAML(&b, frontMatter); err != nil {
+		return nil, err
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(strings.TrimRight(note.Description, "\n") + "\n")
+	return b.Bytes(), nil
+}
+
+var nonSlugCharacters = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// The most characters of a title kept in the name of its file.
+const maxSlugLength = 50
+
+// Names the file of a note after its title, made unique by the start of its ID,
+// e.g. "groceries-for-the-week-0b6f2a4c.md".
+func exportFileName(note repository.Note) string {
+	slug := []rune(strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(note.Title), "-"), "-"))
+	if len(slug) > maxSlugLength {
+		slug = []rune(strings.TrimRight(string(slug[:maxSlugLength]), "-"))
+	}
+
+	id := note.ID.String()[:8]
+	if len(slug) == 0 {
+		return id + ".md"
+	}
+	return string(slug) + "-" + id + ".md"
+}
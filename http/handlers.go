@@ -3,7 +3,11 @@ package http
 import (
 	"errors"
 	"log"
+	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,20 +22,30 @@ func (s *Server) createNoteHandler(c *gin.Context) {
 		s.sendBadRequest(c, "bad request")
 		return
 	}
+	req.OnConflict = repository.ConflictStrategy(c.Query("on_conflict"))
 
 	note, err := s.service.CreateNote(c, req)
 	if err != nil {
 		log.Printf("unable to create note: %v", err)
 
+		var validationErr *service.ValidationError
 		switch {
+		case errors.As(err, &validationErr):
+			s.sendUnprocessableEntity(c, validationErr, "body")
 		case errors.Is(err, service.ErrNoteTitleTaken):
-			s.sendConflict(c, err.Error())
+			s.sendConflict(c, err)
+		case errors.Is(err, service.ErrNoteQuotaExceeded):
+			s.sendForbidden(c, err.Error())
 		default:
 			s.sendInternalError(c, err.Error())
 		}
 		return
 	}
 
+	if note.Updated {
+		s.sendOk(c, *note)
+		return
+	}
 	s.sendCreated(c, *note)
 }
 
@@ -43,11 +57,51 @@ func (s *Server) fetchNoteByIDHandler(c *gin.Context) {
 		return
 	}
 
-	note, err := s.service.FetchNoteByID(c, id)
+	fields, ok := s.queryFields(c)
+	if !ok {
+		return
+	}
+
+	renderOption := c.Query("render")
+	if renderOption != "" && renderOption != renderHTML {
+		s.sendBadRequest(c, "invalid render option")
+		return
+	}
+	// The rendered description is only included in JSON, but browsers can get it as HTML
+	offered := append(slices.Clone(s.mediaTypes), mediaTypeHTML)
+	if renderOption == renderHTML {
+		offered = []string{mediaTypeJSON, mediaTypeHTML}
+	}
+	mediaType, ok := s.negotiate(c, offered...)
+	if !ok {
+		return
+	}
+	asHTML := mediaType == mediaTypeHTML
+	rendering := renderOption == renderHTML || asHTML
+
+	// updated_at is needed for the ETag, and the description and its format
+	// to render it, even if they aren't returned
+	fetchFields := fields
+	if len(fields) > 0 {
+		needed := []repository.NoteField{repository.NoteFieldUpdatedAt}
+		if rendering {
+			needed = append(needed, repository.NoteFieldDescription, repository.NoteFieldFormat)
+		}
+		for _, field := range needed {
+			if !slices.Contains(fetchFields, field) {
+				fetchFields = append(slices.Clone(fetchFields), field)
+			}
+		}
+	}
+
+	note, err := s.service.FetchNoteByID(c, id, fetchFields...)
 	if err != nil {
 		log.Printf("unable to fetch note: %v", err)
 
+		var validationErr *service.ValidationError
 		switch {
+		case errors.As(err, &validationErr):
+			s.sendUnprocessableEntity(c, validationErr, "query")
 		case errors.Is(err, service.ErrNoteNotFound):
 			s.sendNotFound(c, err.Error())
 		default:
@@ -56,17 +110,182 @@ func (s *Server) fetchNoteByIDHandler(c *gin.Context) {
 		return
 	}
 
-	s.sendOk(c, *note)
+	var lastModified time.Time
+	if note.UpdatedAt != nil {
+		lastModified = *note.UpdatedAt
+	}
+	etag := weakETag(id.String(), timeKey(note.UpdatedAt), c.Query("fields"), renderOption, mediaType)
+	if s.notModified(c, etag, lastModified, true) {
+		return
+	}
+
+	if !rendering {
+		s.setCachingHeaders(c, etag, lastModified)
+		s.sendNote(c, mediaType, note.Project(fields...), orAllNoteFields(fields))
+		return
+	}
+
+	rendered, err := renderDescription(*note)
+	if err != nil {
+		log.Printf("unable to render note: %v", err)
+		s.sendInternalError(c, "unable to render note")
+		return
+	}
+
+	s.setCachingHeaders(c, etag, lastModified)
+	if asHTML {
+		s.sendHTML(c, rendered.HTML)
+		return
+	}
+	s.sendOk(c, renderedNote{Note: note.Project(fields...), Rendered: rendered})
+}
+
+// Parses the fields query param, replying with a 400 status code if it is invalid.
+func (s *Server) queryFields(c *gin.Context) ([]repository.NoteField, bool) {
+	value := c.Query("fields")
+	if value == "" {
+		return nil, true
+	}
+
+	fields, err := repository.ParseNoteFields(value)
+	if err != nil {
+		log.Printf("invalid fields: %v", err)
+		s.sendBadRequest(c, "bad request")
+		return nil, false
+	}
+	return fields, true
+}
+
+// Returns the fields given, or every field of a note if none are.
+func orAllNoteFields(fields []repository.NoteField) []repository.NoteField {
+	if len(fields) == 0 {
+		return repository.NoteFields
+	}
+	return fields
+}
+
+type fetchNotesQuery struct {
+	CreatedAfter  *time.Time `form:"created_after"`
+	CreatedBefore *time.Time `form:"created_before"`
+	UpdatedAfter  *time.Time `form:"updated_after"`
+	UpdatedBefore *time.Time `form:"updated_before"`
+	TitlePrefix   string     `form:"title_prefix"`
+	Contains      string     `form:"contains"`
+	Sort          string     `form:"sort"`
 }
 
 func (s *Server) fetchNotesHandler(c *gin.Context) {
-	notes, err := s.service.FetchNotes(c)
+	var query fetchNotesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		log.Printf("invalid query params: %v", err)
+		s.sendBadRequest(c, "bad request")
+		return
+	}
+
+	filter := repository.NoteFilter{
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		UpdatedAfter:  query.UpdatedAfter,
+		UpdatedBefore: query.UpdatedBefore,
+		TitlePrefix:   query.TitlePrefix,
+		Contains:      query.Contains,
+	}
+	if query.Sort != "" {
+		sort, err := repository.ParseNoteSort(query.Sort)
+		if err != nil {
+			log.Printf("invalid sort: %v", err)
+			s.sendBadRequest(c, "bad request")
+			return
+		}
+		filter.Sort = sort
+	}
+
+	fields, ok := s.queryFields(c)
+	if !ok {
+		return
+	}
+
+	mediaType, ok := s.negotiate(c, s.mediaTypes...)
+	if !ok {
+		return
+	}
+
+	// Check if the client's copy is fresh before fetching the notes themselves
+	version, err := s.service.FetchNotesVersion(c, filter)
+	if err != nil {
+		log.Printf("unable to fetch the version of notes: %v", err)
+		s.sendFetchNotesError(c, err)
+		return
+	}
+
+	var lastModified time.Time
+	if version.LastUpdatedAt != nil {
+		lastModified = *version.LastUpdatedAt
+	}
+	etag := weakETag(strconv.Itoa(version.Count), timeKey(version.LastUpdatedAt), c.Request.URL.Query().Encode(), mediaType)
+	// Deleting a note doesn't change when notes were last updated, so only the ETag
+	// can tell if the collection changed
+	if s.notModified(c, etag, lastModified, false) {
+		return
+	}
+
+	// StreamNotes only streams every note, oldest first, so other filters are fetched at once
+	if _, ok := s.encoders[mediaType].(ndjsonEncoder); ok && filter.IsZero() {
+		s.streamNotes(c, etag, lastModified, fields)
+		return
+	}
+
+	notes, err := s.service.FetchNotes(c, filter, fields...)
 	if err != nil {
 		log.Printf("unable to fetch notes: %v", err)
-		s.sendInternalError(c, err.Error())
+		s.sendFetchNotesError(c, err)
 		return
 	}
-	s.sendOk(c, notes)
+	s.setCachingHeaders(c, etag, lastModified)
+	s.sendNotes(c, mediaType, notes, orAllNoteFields(fields))
+}
+
+// Writes every note as NDJSON as it is streamed, without holding them in memory. As with
+// exports, errors past the first note abort the response, so clients don't take the notes
+// cut short for all of them.
+func (s *Server) streamNotes(c *gin.Context, etag string, lastModified time.Time, fields []repository.NoteField) {
+	started := false
+	start := func() {
+		s.setCachingHeaders(c, etag, lastModified)
+		c.Header("Content-Type", mediaTypeNDJSON+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		started = true
+	}
+
+	err := s.service.StreamNotes(c, func(note repository.Note) error {
+		if !started {
+			start()
+		}
+		return ndjsonEncoder{}.EncodeNote(c.Writer, note.Project(fields...), fields)
+	})
+	if err != nil {
+		log.Printf("unable to stream notes: %v", err)
+		if !started {
+			s.sendFetchNotesError(c, err)
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		start()
+		c.Writer.WriteHeaderNow()
+	}
+}
+
+func (s *Server) sendFetchNotesError(c *gin.Context, err error) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		s.sendUnprocessableEntity(c, validationErr, "query")
+	default:
+		s.sendInternalError(c, err.Error())
+	}
 }
 
 func (s *Server) updateNoteHandler(c *gin.Context) {
@@ -88,9 +307,14 @@ func (s *Server) updateNoteHandler(c *gin.Context) {
 	if err != nil {
 		log.Printf("unable to update note: %v", err)
 
+		var validationErr *service.ValidationError
 		switch {
+		case errors.As(err, &validationErr):
+			s.sendUnprocessableEntity(c, validationErr, "body")
+		case errors.Is(err, service.ErrNoteNotFound):
+			s.sendNotFound(c, err.Error())
 		case errors.Is(err, service.ErrNoteTitleTaken):
-			s.sendConflict(c, err.Error())
+			s.sendConflict(c, err)
 		default:
 			s.sendInternalError(c, err.Error())
 		}
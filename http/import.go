@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/the-code-genin/golang_integration_testing/importer"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+// How long finished import jobs can be fetched for.
+const importJobRetention = time.Hour
+
+// The most imports running at once on this instance, and for each client, as each holds
+// its file in memory until it finishes.
+const (
+	maxRunningImportJobs          = 8
+	maxRunningImportJobsPerClient = 1
+)
+
+var (
+	errImportJobRunning  = errors.New("an import is already running for the client, retry once it has finished")
+	errTooManyImportJobs = errors.New("too many imports are running, retry later")
+)
+
+type importJobStatus string
+
+const (
+	importJobRunning   importJobStatus = "running"
+	importJobSucceeded importJobStatus = "succeeded"
+	importJobFailed    importJobStatus = "failed"
+)
+
+var importJobStatuses = []string{string(importJobRunning), string(importJobSucceeded), string(importJobFailed)}
+
+// An import of notes running in the background, with the result of every note imported so far.
+type importJob struct {
+	ID         uuid.UUID                   `json:"id" binding:"required"`
+	Status     importJobStatus             `json:"status" binding:"required"`
+	Format     importer.Format             `json:"format" binding:"required"`
+	OnConflict repository.ConflictStrategy `json:"on_conflict" binding:"required"`
+	Imported   int                         `json:"imported" binding:"required"`
+	Failed     int                         `json:"failed" binding:"required"`
+	Results    []importer.Result           `json:"results" binding:"required"`
+	// Why the import stopped before reading every note
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" binding:"required"`
+	FinishedAt *time.Time `json:"finished_at"`
+
+	// The client that started the import
+	client string
+}
+
+// Keeps track of the import jobs started on this instance, until they have been
+// finished for importJobRetention.
+type importJobs struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*importJob
+}
+
+func newImportJobs() *importJobs {
+	return &importJobs{jobs: map[uuid.UUID]*importJob{}}
+}
+
+// Returns errImportJobRunning or errTooManyImportJobs if the client or the instance
+// is running as many imports as it can.
+func (j *importJobs) start(format importer.Format, onConflict repository.ConflictStrategy, client string) (importJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	running, runningForClient := 0, 0
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > importJobRetention {
+			delete(j.jobs, id)
+		}
+		if job.Status == importJobRunning {
+			running++
+			if job.client == client {
+				runningForClient++
+			}
+		}
+	}
+	if runningForClient >= maxRunningImportJobsPerClient {
+		return importJob{}, errImportJobRunning
+	}
+	if running >= maxRunningImportJobs {
+		return importJob{}, errTooManyImportJobs
+	}
+
+	job := &importJob{
+		ID:         uuid.New(),
+		Status:     importJobRunning,
+		Format:     format,
+		OnConflict: onConflict,
+		Results:    []importer.Result{},
+		CreatedAt:  now,
+		client:     client,
+	}
+	j.jobs[job.ID] = job
+	return *job, nil
+}
+
+func (j *importJobs) record(id uuid.UUID, result importer.Result) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job := j.jobs[id]
+	job.Results = append(job.Results, result)
+	if result.Status == importer.StatusImported {
+		job.Imported++
+	} else {
+		job.Failed++
+	}
+}
+
+func (j *importJobs) finish(id uuid.UUID, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job := j.jobs[id]
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = importJobSucceeded
+	if err != nil {
+		job.Status, job.Error = importJobFailed, err.Error()
+	}
+}
+
+// Returns a copy of the job, which is safe to read as the import goes on.
+func (j *importJobs) get(id uuid.UUID) (importJob, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return importJob{}, false
+	}
+	snapshot := *job
+	snapshot.Results = slices.Clone(job.Results)
+	return snapshot, true
+}
+
+// Starts importing the notes in the request body, replying with the job to poll for
+// the result of each note.
+func (s *Server) importNotesHandler(c *gin.Context) {
+	format := importer.Format(c.DefaultQuery("format", string(importer.FormatNDJSON)))
+	if !slices.Contains(importer.Formats, format) {
+		s.sendBadRequest(c, "invalid import format")
+		return
+	}
+	onConflict := repository.ConflictStrategy(c.DefaultQuery("on_conflict", string(repository.ConflictError)))
+	if !slices.Contains(repository.ConflictStrategies, onConflict) {
+		s.sendBadRequest(c, "invalid conflict strategy")
+		return
+	}
+
+	// The job outlives the request, so the body is read first. It is limited by limitBodySize.
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("unable to read request body: %v", err)
+		s.sendBadRequest(c, "bad request")
+		return
+	}
+
+	client := s.clientID(c)
+	job, err := s.imports.start(format, onConflict, client)
+	switch {
+	case errors.Is(err, errImportJobRunning):
+		s.sendTooManyRequests(c, err.Error())
+		return
+	case errors.Is(err, errTooManyImportJobs):
+		s.sendServiceUnavailable(c, err.Error())
+		return
+	}
+
+	// Keep the client's identity, so its notes count against its quota, but not the request's deadline
+	ctx := context.WithoutCancel(c.Request.Context())
+	svc := rateLimitedService{Service: s.service, server: s, client: client}
+	go func() {
+		err := importer.Import(ctx, svc, format, bytes.NewReader(body), onConflict, func(result importer.Result) {
+			s.imports.record(job.ID, result)
+		})
+		if err != nil {
+			log.Printf("unable to import notes: %v", err)
+		}
+		s.imports.finish(job.ID, err)
+	}()
+
+	s.sendAccepted(c, "/v1/notes/import/"+job.ID.String(), job)
+}
+
+// The route whose rate limit imported notes count against.
+const createNoteRoute = http.MethodPost + " /v1/notes"
+
+// Takes a token from the client's bucket for createNoteRoute before creating each note,
+// waiting for one while the bucket is empty, so importing notes is no faster than the
+// client creating them one by one.
+type rateLimitedService struct {
+	service.Service
+	server *Server
+	client string
+}
+
+func (s rateLimitedService) CreateNote(ctx context.Context, dto repository.CreateNoteDTO) (*repository.Note, error) {
+	if err := s.server.waitForToken(ctx, createNoteRoute, s.client); err != nil {
+		return nil, err
+	}
+	return s.Service.CreateNote(ctx, dto)
+}
+
+func (s *Server) fetchImportJobHandler(c *gin.Context) {
+	id, err := uuid.Parse(strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		log.Printf("invalid UUID: %v", err)
+		s.sendBadRequest(c, "invalid import job ID")
+		return
+	}
+
+	// Jobs hold the titles and IDs of the notes imported, so only the client that started one can see it
+	job, ok := s.imports.get(id)
+	if !ok || job.client != s.clientID(c) {
+		s.sendNotFound(c, "no import job was found with the ID")
+		return
+	}
+	s.sendOk(c, job)
+}
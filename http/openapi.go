@@ -0,0 +1,692 @@
+package http
+
+import (
+	"embed"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/the-code-genin/golang_integration_testing/importer"
+	"github.com/the-code-genin/golang_integration_testing/render"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// The Swagger UI release the docs page runs, served from this origin.
+//
+//go:embed swagger-ui/*.js swagger-ui/*.css
+var docsAssets embed.FS
+
+// Serialized as a single type when there is only one, as most tools expect.
+type schemaType []string
+
+func (t schemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref,omitempty"`
+	Type       schemaType                `json:"type,omitempty"`
+	Format     string                    `json:"format,omitempty"`
+	MinLength  *int                      `json:"minLength,omitempty"`
+	MaxLength  *int                      `json:"maxLength,omitempty"`
+	Pattern    string                    `json:"pattern,omitempty"`
+	Enum       []string                  `json:"enum,omitempty"`
+	Minimum    *float64                  `json:"minimum,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	Required   []string                  `json:"required,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	// Either a schema for the values of extra properties, or false if none are allowed
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIHeader struct {
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIDocument struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+var (
+	timeType = reflect.TypeFor[time.Time]()
+	uuidType = reflect.TypeFor[uuid.UUID]()
+)
+
+// Builds a JSON schema from the json, binding and openapi tags of a struct's fields.
+// The openapi tag holds comma separated constraints, e.g. `openapi:"minLength=1,maxLength=255"`.
+func schemaFor(t reflect.Type) *openAPISchema {
+	nullable := false
+	if t.Kind() == reflect.Pointer {
+		nullable = true
+		t = t.Elem()
+	}
+
+	var schema *openAPISchema
+	switch {
+	case t == timeType:
+		schema = &openAPISchema{Type: schemaType{"string"}, Format: "date-time"}
+	case t == uuidType:
+		schema = &openAPISchema{Type: schemaType{"string"}, Format: "uuid"}
+	case t.Kind() == reflect.String:
+		schema = &openAPISchema{Type: schemaType{"string"}}
+	case t.Kind() == reflect.Bool:
+		schema = &openAPISchema{Type: schemaType{"boolean"}}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema = &openAPISchema{Type: schemaType{"integer"}}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = &openAPISchema{Type: schemaType{"number"}}
+	case t.Kind() == reflect.Slice:
+		schema = &openAPISchema{Type: schemaType{"array"}, Items: schemaFor(t.Elem())}
+	case t.Kind() == reflect.Map:
+		schema = &openAPISchema{Type: schemaType{"object"}, AdditionalProperties: schemaFor(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		schema = &openAPISchema{Type: schemaType{"object"}, Properties: map[string]*openAPISchema{}}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			schema.Properties[name] = withConstraints(schemaFor(field.Type), field.Tag.Get("openapi"))
+			if strings.Contains(field.Tag.Get("binding"), "required") {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	default:
+		schema = &openAPISchema{}
+	}
+
+	if nullable && len(schema.Type) > 0 {
+		schema.Type = append(schema.Type, "null")
+	}
+
+	return schema
+}
+
+func withConstraints(schema *openAPISchema, tag string) *openAPISchema {
+	for constraint := range strings.SplitSeq(tag, ",") {
+		key, value, _ := strings.Cut(constraint, "=")
+		switch key {
+		case "minLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				schema.MinLength = &n
+			}
+		case "maxLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				schema.MaxLength = &n
+			}
+		case "pattern":
+			schema.Pattern = value
+		case "format":
+			schema.Format = value
+		}
+	}
+	return schema
+}
+
+// Rejects properties not in the schema.
+func closed(schema *openAPISchema) *openAPISchema {
+	schema.AdditionalProperties = false
+	return schema
+}
+
+func schemaRef(name string) *openAPISchema {
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(schema *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+func errorResponse(description string) openAPIResponse {
+	return openAPIResponse{Description: description, Content: jsonContent(schemaRef("Error"))}
+}
+
+func problemResponse(description string) openAPIResponse {
+	return openAPIResponse{
+		Description: description,
+		Content:     map[string]openAPIMediaType{"application/problem+json": {Schema: schemaRef("Problem")}},
+	}
+}
+
+var tooManyRequestsResponse = openAPIResponse{
+	Description: "The client has made too many requests to the route",
+	Headers: map[string]openAPIHeader{
+		"Retry-After": {
+			Description: "The seconds to wait before retrying",
+			Schema:      &openAPISchema{Type: schemaType{"integer"}, Minimum: ptr(0.0)},
+		},
+	},
+	Content: jsonContent(schemaRef("Error")),
+}
+
+var renderParameter = openAPIParameter{
+	Name:        "render",
+	In:          "query",
+	Description: "Include the description rendered as sanitized HTML, with its headings, links and tasks, under rendered",
+	Schema:      &openAPISchema{Type: schemaType{"string"}, Enum: []string{renderHTML}},
+}
+
+var noteIDParameter = openAPIParameter{
+	Name:     "id",
+	In:       "path",
+	Required: true,
+	Schema:   &openAPISchema{Type: schemaType{"string"}, Format: "uuid"},
+}
+
+// Describes every route registered in NewServer.
+func newOpenAPIDocument() *openAPIDocument {
+	doc := &openAPIDocument{OpenAPI: "3.1.0"}
+	doc.Info.Title = "Notes API"
+	doc.Info.Version = "1.0.0"
+
+	doc.Components.Schemas = map[string]*openAPISchema{
+		"Note":          schemaFor(reflect.TypeFor[repository.Note]()),
+		"NoteEvent":     schemaFor(reflect.TypeFor[repository.NoteEvent]()),
+		"CreateNoteDTO": closed(schemaFor(reflect.TypeFor[repository.CreateNoteDTO]())),
+		"UpdateNoteDTO": closed(schemaFor(reflect.TypeFor[repository.UpdateNoteDTO]())),
+		"Error": {
+			Type: schemaType{"object"},
+			Properties: map[string]*openAPISchema{
+				"message": {Type: schemaType{"string"}},
+				"errors":  {Type: schemaType{"array"}, Items: schemaRef("FieldError")},
+				"note_id": {Type: schemaType{"string"}, Format: "uuid"},
+			},
+			Required: []string{"message"},
+		},
+		"ImportJob":           schemaFor(reflect.TypeFor[importJob]()),
+		"ImportResult":        schemaFor(reflect.TypeFor[importer.Result]()),
+		"RenderedDescription": schemaFor(reflect.TypeFor[render.Rendered]()),
+		"FieldError":          schemaFor(reflect.TypeFor[fieldError]()),
+		"Problem":             schemaFor(reflect.TypeFor[problem]()),
+	}
+	// Reference the note schema instead of inlining it
+	doc.Components.Schemas["NoteEvent"].Properties["note"] = schemaRef("Note")
+	// Only included when the note is fetched with ?render=html
+	doc.Components.Schemas["Note"].Properties["rendered"] = schemaRef("RenderedDescription")
+	// UpdateNoteDTO's format is left out, as it may be null
+	doc.Components.Schemas["Note"].Properties["format"].Enum = noteFormats()
+	doc.Components.Schemas["CreateNoteDTO"].Properties["format"].Enum = noteFormats()
+	doc.Components.Schemas["ImportJob"].Properties["status"].Enum = importJobStatuses
+	doc.Components.Schemas["ImportJob"].Properties["format"].Enum = importFormats()
+	doc.Components.Schemas["ImportJob"].Properties["on_conflict"].Enum = conflictStrategies()
+	doc.Components.Schemas["ImportJob"].Properties["results"].Items = schemaRef("ImportResult")
+	doc.Components.Schemas["ImportResult"].Properties["status"].Enum = []string{
+		string(importer.StatusImported), string(importer.StatusFailed),
+	}
+
+	doc.Paths = map[string]map[string]*openAPIOperation{
+		"/v1/notes": {
+			"post": {
+				OperationID: "createNote",
+				Summary:     "Create a note",
+				Tags:        []string{"notes"},
+				Parameters: []openAPIParameter{{
+					Name:        "on_conflict",
+					In:          "query",
+					Description: "How to resolve a title held by another note: fail, suffix the title with a number, or update that note's description",
+					Schema:      &openAPISchema{Type: schemaType{"string"}, Enum: conflictStrategies()},
+				}},
+				RequestBody: &openAPIRequestBody{Required: true, Content: jsonContent(schemaRef("CreateNoteDTO"))},
+				Responses: map[string]openAPIResponse{
+					"200": {Description: "The note holding the title, updated as upserted", Content: jsonContent(schemaRef("Note"))},
+					"201": {Description: "The note created", Content: jsonContent(schemaRef("Note"))},
+					"400": errorResponse("The request body is invalid"),
+					"403": errorResponse("The client has as many notes as its quota allows"),
+					"409": errorResponse("An existing note has the title given"),
+					"413": problemResponse("The request body is too large"),
+					"422": errorResponse("The note breaks a validation rule"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+			"get": {
+				OperationID: "fetchNotes",
+				Summary:     "Fetch the notes matching the filters given",
+				Tags:        []string{"notes"},
+				Parameters:  append(fetchNotesParameters(), fieldsParameter(), ifNoneMatchParameter),
+				Responses: map[string]openAPIResponse{
+					"200": {
+						Description: "The notes matching the filters",
+						Content:     jsonContent(&openAPISchema{Type: schemaType{"array"}, Items: schemaRef("Note")}),
+					},
+					"304": {Description: "The client's copy of the notes is fresh"},
+					"400": errorResponse("A query parameter is invalid"),
+					"422": errorResponse("The filters or fields are invalid"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+		},
+		"/v1/notes/events": {
+			"get": {
+				OperationID: "streamNoteEvents",
+				Summary:     "Stream note changes as Server-Sent Events",
+				Tags:        []string{"notes"},
+				Parameters: []openAPIParameter{{
+					Name:        "Last-Event-ID",
+					In:          "header",
+					Description: "Replay the events after this one before streaming new events",
+					Schema:      &openAPISchema{Type: schemaType{"integer"}, Minimum: ptr(0.0)},
+				}},
+				Responses: map[string]openAPIResponse{
+					"200": {
+						Description: "A stream of note events",
+						Content:     map[string]openAPIMediaType{"text/event-stream": {Schema: schemaRef("NoteEvent")}},
+					},
+					"400": errorResponse("The Last-Event-ID header is invalid"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+		},
+		"/v1/notes/export": {
+			"get": {
+				OperationID: "exportNotes",
+				Summary:     "Stream every note, oldest first, for backups",
+				Tags:        []string{"notes"},
+				Parameters: []openAPIParameter{{
+					Name:        "format",
+					In:          "query",
+					Description: "ndjson for a JSON object per line, csv for a row per note, or zip for a Markdown file per note with front matter. Defaults to ndjson",
+					Schema:      &openAPISchema{Type: schemaType{"string"}, Enum: exportFormatNames},
+				}},
+				Responses: map[string]openAPIResponse{
+					"200": {
+						Description: "Every note, cut short if an error occurs once the export has started",
+						Content: map[string]openAPIMediaType{
+							mediaTypeNDJSON:   {Schema: schemaRef("Note")},
+							mediaTypeCSV:      {},
+							"application/zip": {},
+						},
+					},
+					"400": errorResponse("The format is invalid"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+		},
+		"/v1/notes/import": {
+			"post": {
+				OperationID: "importNotes",
+				Summary:     "Start importing notes from a file, as exported, in the background",
+				Tags:        []string{"notes"},
+				Parameters: []openAPIParameter{
+					{
+						Name:        "format",
+						In:          "query",
+						Description: "ndjson for a JSON object per line, csv for a header row then a row per note, or zip for a Markdown file per note with front matter. Defaults to ndjson",
+						Schema:      &openAPISchema{Type: schemaType{"string"}, Enum: importFormats()},
+					},
+					{
+						Name:        "on_conflict",
+						In:          "query",
+						Description: "How to resolve a title held by another note: fail the note, suffix the title with a number, or update that note's description. Defaults to error",
+						Schema:      &openAPISchema{Type: schemaType{"string"}, Enum: conflictStrategies()},
+					},
+				},
+				RequestBody: &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+					mediaTypeNDJSON:   {},
+					mediaTypeCSV:      {},
+					"application/zip": {},
+				}},
+				Responses: map[string]openAPIResponse{
+					"202": {
+						Description: "The import job started",
+						Headers: map[string]openAPIHeader{
+							"Location": {Description: "Where to fetch the import job from", Schema: &openAPISchema{Type: schemaType{"string"}}},
+						},
+						Content: jsonContent(schemaRef("ImportJob")),
+					},
+					"400": errorResponse("The format, conflict strategy or request body is invalid"),
+					"413": problemResponse("The request body is too large"),
+					"503": errorResponse("The server is running as many imports as it can"),
+				},
+			},
+		},
+		"/v1/notes/import/{id}": {
+			"get": {
+				OperationID: "fetchImportJob",
+				Summary:     "Fetch an import job with the result of every note imported so far",
+				Tags:        []string{"notes"},
+				Parameters: []openAPIParameter{{
+					Name:        "id",
+					In:          "path",
+					Description: "The ID of the import job",
+					Required:    true,
+					Schema:      &openAPISchema{Type: schemaType{"string"}, Format: "uuid"},
+				}},
+				Responses: map[string]openAPIResponse{
+					"200": {Description: "The import job", Content: jsonContent(schemaRef("ImportJob"))},
+					"400": errorResponse("The import job ID is invalid"),
+					"404": errorResponse("No import job the client started exists with the ID, or it finished over an hour ago"),
+				},
+			},
+		},
+		"/v1/notes/{id}": {
+			"get": {
+				OperationID: "fetchNoteByID",
+				Summary:     "Fetch a note by ID",
+				Tags:        []string{"notes"},
+				Parameters: []openAPIParameter{
+					noteIDParameter, fieldsParameter(), renderParameter, ifNoneMatchParameter, ifModifiedSinceParameter,
+				},
+				Responses: map[string]openAPIResponse{
+					"200": {
+						Description: "The note, or its description rendered as sanitized HTML if the client prefers text/html",
+						Content: map[string]openAPIMediaType{
+							"application/json": {Schema: schemaRef("Note")},
+							"text/html":        {Schema: &openAPISchema{Type: schemaType{"string"}}},
+						},
+					},
+					"304": {Description: "The client's copy of the note is fresh"},
+					"400": errorResponse("The note ID, fields or render option are invalid"),
+					"404": errorResponse("No note exists with the ID"),
+					"422": errorResponse("The fields are invalid"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+			"patch": {
+				OperationID: "updateNote",
+				Summary:     "Update a note",
+				Tags:        []string{"notes"},
+				Parameters:  []openAPIParameter{noteIDParameter},
+				RequestBody: &openAPIRequestBody{Required: true, Content: jsonContent(schemaRef("UpdateNoteDTO"))},
+				Responses: map[string]openAPIResponse{
+					"200": {Description: "The updated note", Content: jsonContent(schemaRef("Note"))},
+					"400": errorResponse("The note ID or request body is invalid"),
+					"404": errorResponse("No note exists with the ID"),
+					"409": errorResponse("An existing note has the title given"),
+					"413": problemResponse("The request body is too large"),
+					"422": errorResponse("The note breaks a validation rule"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+			"delete": {
+				OperationID: "deleteNote",
+				Summary:     "Delete a note",
+				Tags:        []string{"notes"},
+				Parameters:  []openAPIParameter{noteIDParameter},
+				Responses: map[string]openAPIResponse{
+					"204": {Description: "The note was deleted"},
+					"400": errorResponse("The note ID is invalid"),
+					"404": errorResponse("No note exists with the ID"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+		},
+		"/v1/notes/{id}/ws": {
+			"get": {
+				OperationID: "editNote",
+				Summary:     "Collaboratively edit a note's description over a WebSocket",
+				Tags:        []string{"notes"},
+				Parameters: []openAPIParameter{noteIDParameter, {
+					Name:        "name",
+					In:          "query",
+					Description: "The name shown to the other clients editing the note",
+					Schema:      &openAPISchema{Type: schemaType{"string"}, MaxLength: ptr(100)},
+				}},
+				Responses: map[string]openAPIResponse{
+					"101": {Description: "Switched to the WebSocket protocol"},
+					"400": errorResponse("The note ID is invalid"),
+					"404": errorResponse("No note exists with the ID"),
+					"500": errorResponse("An internal error occurred"),
+				},
+			},
+		},
+		"/graphql": {
+			"post": {
+				OperationID: "graphql",
+				Summary:     "Execute a GraphQL operation",
+				Tags:        []string{"graphql"},
+				RequestBody: &openAPIRequestBody{Required: true, Content: jsonContent(&openAPISchema{
+					Type: schemaType{"object"},
+					Properties: map[string]*openAPISchema{
+						"query":         {Type: schemaType{"string"}, MinLength: ptr(1)},
+						"operationName": {Type: schemaType{"string"}},
+						"variables":     {Type: schemaType{"object"}},
+					},
+					Required: []string{"query"},
+				})},
+				Responses: map[string]openAPIResponse{
+					"200": {Description: "The result of the operation", Content: jsonContent(&openAPISchema{Type: schemaType{"object"}})},
+					"400": {Description: "The request body is invalid", Content: jsonContent(&openAPISchema{Type: schemaType{"object"}})},
+					"413": problemResponse("The request body is too large"),
+				},
+			},
+		},
+		"/openapi.json": {
+			"get": {
+				OperationID: "openAPI",
+				Summary:     "Fetch this document",
+				Tags:        []string{"docs"},
+				Responses: map[string]openAPIResponse{
+					"200": {Description: "The OpenAPI document", Content: jsonContent(&openAPISchema{Type: schemaType{"object"}})},
+				},
+			},
+		},
+		"/docs": {
+			"get": {
+				OperationID: "docs",
+				Summary:     "Browse this document",
+				Tags:        []string{"docs"},
+				Responses: map[string]openAPIResponse{
+					"200": {Description: "The API documentation", Content: map[string]openAPIMediaType{"text/html": {}}},
+				},
+			},
+		},
+		"/docs/assets/{file}": {
+			"get": {
+				OperationID: "docsAsset",
+				Summary:     "Fetch a script or stylesheet of the docs page",
+				Tags:        []string{"docs"},
+				Parameters: []openAPIParameter{{
+					Name:        "file",
+					In:          "path",
+					Description: "swagger-ui-bundle.js or swagger-ui.css",
+					Required:    true,
+					Schema:      &openAPISchema{Type: schemaType{"string"}},
+				}},
+				Responses: map[string]openAPIResponse{
+					"200": {
+						Description: "The file",
+						Content:     map[string]openAPIMediaType{"text/javascript": {}, "text/css": {}},
+					},
+					"404": errorResponse("No such file"),
+				},
+			},
+		},
+	}
+
+	// Any route can be rate limited, depending on how the server is configured
+	for _, operations := range doc.Paths {
+		for _, operation := range operations {
+			operation.Responses["429"] = tooManyRequestsResponse
+		}
+	}
+
+	return doc
+}
+
+// Documents the media types notes can be encoded in on the routes fetching them,
+// besides JSON whose schema is already documented.
+func (doc *openAPIDocument) documentMediaTypes(mediaTypes []string) {
+	for _, operation := range []*openAPIOperation{doc.Paths["/v1/notes"]["get"], doc.Paths["/v1/notes/{id}"]["get"]} {
+		for _, mediaType := range mediaTypes {
+			if _, ok := operation.Responses["200"].Content[mediaType]; !ok {
+				operation.Responses["200"].Content[mediaType] = openAPIMediaType{}
+			}
+		}
+		operation.Responses["406"] = errorResponse("The client accepts none of the media types the response can be encoded in")
+	}
+}
+
+func (s *Server) openAPIHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.openAPI)
+}
+
+func (s *Server) docsHandler(c *gin.Context) {
+	c.Header("Content-Security-Policy", docsContentSecurityPolicy)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+func (s *Server) docsAssetHandler(c *gin.Context) {
+	file := c.Param("file")
+	data, err := docsAssets.ReadFile(path.Join("swagger-ui", file))
+	if err != nil {
+		s.sendNotFound(c, "no such file")
+		return
+	}
+	c.Data(http.StatusOK, mime.TypeByExtension(path.Ext(file)), data)
+}
+
+func fetchNotesParameters() []openAPIParameter {
+	timeParameter := func(name, description string) openAPIParameter {
+		return openAPIParameter{
+			Name:        name,
+			In:          "query",
+			Description: description,
+			Schema:      &openAPISchema{Type: schemaType{"string"}, Format: "date-time"},
+		}
+	}
+
+	// e.g. -updated_at,title
+	field := "-?(" + strings.Join(repository.SortableNoteFields(), "|") + ")"
+
+	return []openAPIParameter{
+		timeParameter("created_after", "Only fetch notes created at or after this time"),
+		timeParameter("created_before", "Only fetch notes created before this time"),
+		timeParameter("updated_after", "Only fetch notes updated at or after this time"),
+		timeParameter("updated_before", "Only fetch notes updated before this time"),
+		{
+			Name:        "title_prefix",
+			In:          "query",
+			Description: "Only fetch notes whose titles start with this, ignoring case",
+			Schema:      &openAPISchema{Type: schemaType{"string"}, MaxLength: ptr(255)},
+		},
+		{
+			Name:        "contains",
+			In:          "query",
+			Description: "Only fetch notes whose titles or descriptions contain this, ignoring case",
+			Schema:      &openAPISchema{Type: schemaType{"string"}, MaxLength: ptr(255)},
+		},
+		{
+			Name:        "sort",
+			In:          "query",
+			Description: "Comma separated fields to sort by, each prefixed with - to sort descending. Defaults to created_at",
+			Schema:      &openAPISchema{Type: schemaType{"string"}, Pattern: "^" + field + "(," + field + ")*$"},
+		},
+	}
+}
+
+var (
+	ifNoneMatchParameter = openAPIParameter{
+		Name:        "If-None-Match",
+		In:          "header",
+		Description: "Reply with a 304 status code if the ETag of the current representation is one of these",
+		Schema:      &openAPISchema{Type: schemaType{"string"}},
+	}
+	ifModifiedSinceParameter = openAPIParameter{
+		Name:        "If-Modified-Since",
+		In:          "header",
+		Description: "Reply with a 304 status code if the note wasn't updated since this time. Ignored if If-None-Match is sent",
+		Schema:      &openAPISchema{Type: schemaType{"string"}},
+	}
+)
+
+func fieldsParameter() openAPIParameter {
+	names := make([]string, len(repository.NoteFields))
+	for i, field := range repository.NoteFields {
+		names[i] = string(field)
+	}
+	field := "(" + strings.Join(names, "|") + ")"
+
+	return openAPIParameter{
+		Name:        "fields",
+		In:          "query",
+		Description: "Comma separated fields to include in each note, e.g. id,title,updated_at. Defaults to every field",
+		Schema:      &openAPISchema{Type: schemaType{"string"}, Pattern: "^" + field + "(," + field + ")*$"},
+	}
+}
+
+func conflictStrategies() []string {
+	names := make([]string, len(repository.ConflictStrategies))
+	for i, strategy := range repository.ConflictStrategies {
+		names[i] = string(strategy)
+	}
+	return names
+}
+
+func importFormats() []string {
+	names := make([]string, len(importer.Formats))
+	for i, format := range importer.Formats {
+		names[i] = string(format)
+	}
+	return names
+}
+
+func noteFormats() []string {
+	names := make([]string, len(repository.NoteFormats))
+	for i, format := range repository.NoteFormats {
+		names[i] = string(format)
+	}
+	return names
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-code-genin/golang_integration_testing/ratelimit"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+// The header clients send their API key in.
+const apiKeyHeader = "X-API-Key"
+
+// Identifies clients sending one of keys by it, rather than by their IP address.
+// Keys that aren't known are ignored, so clients can't dodge their limits by inventing keys.
+func WithAPIKeys(keys ...string) Option {
+	return func(s *Server) {
+		for _, key := range keys {
+			s.apiKeys[hashAPIKey(key)] = true
+		}
+	}
+}
+
+// Trusts the X-Forwarded-For and X-Real-IP headers of requests from these proxies, given as
+// IPs or CIDRs, to name the IP address of the client. Other requests are identified by the
+// address they come from, so clients can't pick their own IP to dodge their limits.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(s *Server) {
+		s.trustedProxies = proxies
+	}
+}
+
+// Limits how often each client can call a route, keeping their token buckets in store.
+// Limits are keyed by method and route, e.g. "POST /v1/notes"; routes without one aren't limited.
+func WithRateLimits(store ratelimit.Store, limits map[string]ratelimit.Limit) Option {
+	return func(s *Server) {
+		s.rateLimitStore = store
+		s.rateLimits.Store(&limits)
+	}
+}
+
+// Replaces the limits given to WithRateLimits while serving, e.g. when the config is reloaded.
+// Clients' buckets keep the tokens they have left, up to the new limits.
+func (s *Server) SetRateLimits(limits map[string]ratelimit.Limit) {
+	s.rateLimits.Store(&limits)
+}
+
+// API keys are only ever kept and used hashed, so they can't leak through logs or the database.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Returns the client's verified certificate if they presented one, their API key if it is
+// a known one, or their IP address otherwise.
+func (s *Server) clientID(c *gin.Context) string {
+	if identity, ok := ClientIdentityFromContext(c.Request.Context()); ok {
+		if identity.CommonName != "" {
+			return "cert:" + identity.CommonName
+		}
+		return "cert:" + identity.Fingerprint
+	}
+	if key := c.GetHeader(apiKeyHeader); key != "" {
+		if hash := hashAPIKey(key); s.apiKeys[hash] {
+			return "key:" + hash
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Exposes the identity in the client's certificate to the handlers, and makes the client
+// the owner of the notes it creates and the session that reads its own writes.
+func (s *Server) identifyClient(c *gin.Context) {
+	ctx := c.Request.Context()
+	if identity, ok := verifiedClientIdentity(c); ok {
+		ctx = context.WithValue(ctx, clientIdentityKey{}, identity)
+		c.Request = c.Request.WithContext(ctx)
+	}
+
+	client := s.clientID(c)
+	ctx = repository.WithSession(service.WithOwner(ctx, client), client)
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+}
+
+// Rejects requests from clients that have run out of tokens for the route, setting the
+// RateLimit headers of the IETF draft on every limited response.
+func (s *Server) rateLimit(c *gin.Context) {
+	limits := s.rateLimits.Load()
+	if limits == nil || s.rateLimitStore == nil {
+		c.Next()
+		return
+	}
+
+	route := c.Request.Method + " " + c.FullPath()
+	limit, ok := (*limits)[route]
+	if !ok {
+		c.Next()
+		return
+	}
+
+	result, err := s.rateLimitStore.Take(c.Request.Context(), route+" "+s.clientID(c), limit)
+	if err != nil {
+		// Rather serve clients than fail every request while the store is down
+		log.Printf("unable to rate limit %s: %v", route, err)
+		c.Next()
+		return
+	}
+
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, seconds(limit.Period)))
+	c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"message": fmt.Sprintf("too many requests, retry in %d seconds", seconds(result.RetryAfter)),
+		})
+		return
+	}
+
+	c.Next()
+}
+
+// Takes a token from the client's bucket for the route, waiting until it holds one. Returns
+// right away if the route isn't limited or the store is down, as rateLimit lets requests through.
+func (s *Server) waitForToken(ctx context.Context, route, client string) error {
+	for {
+		limits := s.rateLimits.Load()
+		if limits == nil || s.rateLimitStore == nil {
+			return nil
+		}
+		limit, ok := (*limits)[route]
+		if !ok {
+			return nil
+		}
+
+		result, err := s.rateLimitStore.Take(ctx, route+" "+client, limit)
+		if err != nil {
+			log.Printf("unable to rate limit %s: %v", route, err)
+			return nil
+		}
+		if result.Allowed {
+			return nil
+		}
+
+		timer := time.NewTimer(result.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Rounds up, so clients never retry too early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"encoding/json"
+
+	"github.com/the-code-genin/golang_integration_testing/render"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+)
+
+// The value of the render query param including the rendered description in a note.
+const renderHTML = "html"
+
+// A note with its description rendered, as fetched with ?render=html.
+type renderedNote struct {
+	repository.Note
+	Rendered render.Rendered
+}
+
+// Serializes the note as usual, with the rendered description under "rendered".
+func (n renderedNote) MarshalJSON() ([]byte, error) {
+	note, err := json.Marshal(n.Note)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := json.Marshal(n.Rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	// Splice the key into the note's object, which may be projected onto any fields
+	body := note[:len(note)-1]
+	if len(body) > 1 {
+		body = append(body, ',')
+	}
+	body = append(body, `"rendered":`...)
+	body = append(append(body, rendered...), '}')
+	return body, nil
+}
+
+// Renders the description of a note according to its format.
+func renderDescription(note repository.Note) (render.Rendered, error) {
+	if note.Format == repository.NoteFormatMarkdown {
+		return render.Markdown(note.Description)
+	}
+	return render.Plain(note.Description), nil
+}
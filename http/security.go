@@ -0,0 +1,97 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The largest request body accepted by default.
+const defaultMaxBodySize = 1 << 20
+
+// Locks down what browsers do with the API's responses. The API only serves data,
+// so its responses can't load anything or be framed.
+func defaultSecurityHeaders() map[string]string {
+	return map[string]string{
+		"Strict-Transport-Security": "max-age=63072000; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+		"Referrer-Policy":           "no-referrer",
+	}
+}
+
+// Sets a security header on every response, or stops setting it if value is empty,
+// e.g. WithSecurityHeader("Strict-Transport-Security", "") when not served over HTTPS.
+func WithSecurityHeader(name, value string) Option {
+	return func(s *Server) {
+		if value == "" {
+			delete(s.securityHeaders, name)
+			return
+		}
+		s.securityHeaders[name] = value
+	}
+}
+
+// Rejects request bodies larger than size bytes with a 413 status code.
+func WithMaxBodySize(size int64) Option {
+	return func(s *Server) {
+		s.maxBodySize = size
+	}
+}
+
+// The docs page loads the Swagger UI embedded in the server and runs a single inline script, allowed by its hash.
+var docsContentSecurityPolicy = func() string {
+	script := regexp.MustCompile(`(?s)<script>(.*?)</script>`).FindSubmatch(docsPage)
+	sum := sha256.Sum256(script[1])
+
+	return fmt.Sprintf(
+		"default-src 'none'; script-src 'self' 'sha256-%s'; style-src 'self' 'unsafe-inline'; "+
+			"img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'",
+		base64.StdEncoding.EncodeToString(sum[:]),
+	)
+}()
+
+func (s *Server) setSecurityHeaders(c *gin.Context) {
+	for name, value := range s.securityHeaders {
+		c.Header(name, value)
+	}
+	c.Next()
+}
+
+// Rejects bodies declared too large up front, and stops reading bodies that turn out to be.
+func (s *Server) limitBodySize(c *gin.Context) {
+	if c.Request.ContentLength > s.maxBodySize {
+		s.sendContentTooLarge(c)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.maxBodySize)
+	c.Next()
+}
+
+// An RFC 9457 problem details response.
+type problem struct {
+	Type   string `json:"type" binding:"required"`
+	Title  string `json:"title" binding:"required"`
+	Status int    `json:"status" binding:"required"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (s *Server) sendProblem(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
+
+func (s *Server) sendContentTooLarge(c *gin.Context) {
+	s.sendProblem(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not exceed %d bytes", s.maxBodySize))
+}
@@ -1,15 +1,57 @@
 package http
 
 import (
+	"errors"
+	"log"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"github.com/the-code-genin/golang_integration_testing/graphql"
+	"github.com/the-code-genin/golang_integration_testing/ratelimit"
 	"github.com/the-code-genin/golang_integration_testing/service"
 )
 
 type Server struct {
 	service service.Service
 	router  *gin.Engine
+	events  *eventHub
+	collab  *collabHub
+	imports *importJobs
+	openAPI *openAPIDocument
+
+	validateResponses bool
+	// Cache-Control policies, keyed by method and route
+	cacheControl map[string]string
+	// Hashes of the API keys clients can identify themselves with
+	apiKeys map[string]bool
+	// The proxies whose forwarding headers name the client's IP, none by default
+	trustedProxies []string
+	// Limits keyed by method and route, taken from rateLimitStore. Swapped while serving by SetRateLimits.
+	rateLimits     atomic.Pointer[map[string]ratelimit.Limit]
+	rateLimitStore ratelimit.Store
+	// Nil unless cross-origin requests are allowed
+	corsConfig      *CORSConfig
+	securityHeaders map[string]string
+	maxBodySize     int64
+	// Nil unless serving HTTPS
+	tlsConfig *TLSConfig
+	// The media types notes can be encoded in, in order of preference
+	mediaTypes []string
+	encoders   map[string]Encoder
+}
+
+// Configures optional behaviour of a Server.
+type Option func(*Server)
+
+// Validates every JSON response against the OpenAPI document, replacing
+// responses that don't match it with a 500 error. Meant for tests.
+func WithResponseValidation() Option {
+	return func(s *Server) {
+		s.validateResponses = true
+	}
 }
 
 func (s *Server) sendNotFound(c *gin.Context, message string) {
@@ -24,8 +66,47 @@ func (s *Server) sendBadRequest(c *gin.Context, message string) {
 	})
 }
 
-func (s *Server) sendConflict(c *gin.Context, message string) {
-	c.JSON(http.StatusConflict, gin.H{
+func (s *Server) sendForbidden(c *gin.Context, message string) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"message": message,
+	})
+}
+
+func (s *Server) sendConflict(c *gin.Context, err error) {
+	body := gin.H{
+		"message": err.Error(),
+	}
+
+	// Point clients at the note holding the title
+	var titleTaken *service.TitleTakenError
+	if errors.As(err, &titleTaken) {
+		body["note_id"] = titleTaken.NoteID
+	}
+
+	c.JSON(http.StatusConflict, body)
+}
+
+// Reports the violations as errors of the request's body or query params, as given by location.
+func (s *Server) sendUnprocessableEntity(c *gin.Context, err *service.ValidationError, location string) {
+	errs := make([]fieldError, len(err.Violations))
+	for i, violation := range err.Violations {
+		errs[i] = fieldError{Field: violation.Field, Location: location, Message: violation.Message}
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"message": err.Error(),
+		"errors":  errs,
+	})
+}
+
+func (s *Server) sendTooManyRequests(c *gin.Context, message string) {
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"message": message,
+	})
+}
+
+func (s *Server) sendServiceUnavailable(c *gin.Context, message string) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{
 		"message": message,
 	})
 }
@@ -40,31 +121,103 @@ func (s *Server) sendCreated(c *gin.Context, data any) {
 	c.JSON(http.StatusCreated, data)
 }
 
+// Replies that the request is being processed, pointing at where to check on it.
+func (s *Server) sendAccepted(c *gin.Context, location string, data any) {
+	c.Header("Location", location)
+	c.JSON(http.StatusAccepted, data)
+}
+
 func (s *Server) sendOk(c *gin.Context, data any) {
 	c.JSON(http.StatusOK, data)
 }
 
+func (s *Server) sendHTML(c *gin.Context, html string) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+}
+
 func (s *Server) sendNoContent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func NewServer(svc service.Service) *Server {
-	router := gin.Default()
+// Replies with a 500 error to requests whose handler panicked, as gin's recovery does, except
+// for http.ErrAbortHandler, which is passed on for net/http to abort the response with.
+func recovery(c *gin.Context) {
+	defer func() {
+		err := recover()
+		if err == nil {
+			return
+		}
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+
+		log.Printf("panic serving %s %s: %v\n%s", c.Request.Method, c.Request.URL.Path, err, debug.Stack())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "an internal error occurred"})
+	}()
+	c.Next()
+}
 
+func NewServer(svc service.Service, opts ...Option) *Server {
+	router := gin.New()
+	router.Use(gin.Logger(), recovery)
+
+	mediaTypes, encoders := defaultEncoders()
 	server := &Server{
 		service: svc,
 		router:  router,
+		events:  newEventHub(svc),
+		collab:  newCollabHub(svc),
+		imports: newImportJobs(),
+		openAPI: newOpenAPIDocument(),
+
+		cacheControl: defaultCacheControls(),
+		apiKeys:      map[string]bool{},
+
+		securityHeaders: defaultSecurityHeaders(),
+		maxBodySize:     defaultMaxBodySize,
+		mediaTypes:      mediaTypes,
+		encoders:        encoders,
+	}
+	for _, opt := range opts {
+		opt(server)
 	}
+	server.openAPI.documentMediaTypes(server.mediaTypes)
+
+	if err := router.SetTrustedProxies(server.trustedProxies); err != nil {
+		log.Printf("invalid trusted proxies, trusting none: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+
+	// Let the service read the client's identity from the request context
+	router.ContextWithFallback = true
+
+	// Set headers first, so they are sent with every response, even errors
+	router.Use(server.cors, server.setSecurityHeaders)
+	if server.validateResponses {
+		router.Use(server.validateResponse)
+	}
+	router.Use(server.identifyClient, server.rateLimit, server.limitBodySize, server.validateRequest)
 
 	g := router.Group("/v1/notes")
 	{
 		g.POST("", server.createNoteHandler)
 		g.GET("", server.fetchNotesHandler)
+		g.GET("/events", server.noteEventsHandler)
+		g.GET("/export", server.exportNotesHandler)
+		g.POST("/import", server.importNotesHandler)
+		g.GET("/import/:id", server.fetchImportJobHandler)
 		g.GET("/:id", server.fetchNoteByIDHandler)
 		g.PATCH("/:id", server.updateNoteHandler)
 		g.DELETE("/:id", server.deleteNoteHandler)
+		g.GET("/:id/ws", server.collabHandler)
 	}
 
+	router.POST("/graphql", gin.WrapH(graphql.NewHandler(svc)))
+
+	router.GET("/openapi.json", server.openAPIHandler)
+	router.GET("/docs", server.docsHandler)
+	router.GET("/docs/assets/:file", server.docsAssetHandler)
+
 	router.NoRoute(func(c *gin.Context) {
 		server.sendNotFound(c, "The route you requested for was not found on this server")
 	})
@@ -73,7 +226,27 @@ func NewServer(svc service.Service) *Server {
 }
 
 func (s *Server) Start(addr string) error {
-	return s.router.Run(addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serves HTTPS on the listener if WithTLS was given, or plain HTTP otherwise.
+func (s *Server) Serve(listener net.Listener) error {
+	server := &http.Server{Handler: s.Handler()}
+	if s.tlsConfig == nil {
+		return server.Serve(listener)
+	}
+
+	tlsConfig, err := s.tlsConfig.build()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	server.TLSConfig = tlsConfig
+	return server.ServeTLS(listener, "", "")
 }
 
 func (s *Server) Handler() http.Handler {
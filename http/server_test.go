@@ -1,20 +1,32 @@
 package http_test
 
 import (
+	"archive/zip"
+	"bufio"
 	"context"
 	"database/sql"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/gavv/httpexpect/v2"
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	h "github.com/the-code-genin/golang_integration_testing/http"
+	"github.com/the-code-genin/golang_integration_testing/ratelimit"
 	"github.com/the-code-genin/golang_integration_testing/repository"
 	"github.com/the-code-genin/golang_integration_testing/service"
 	"github.com/the-code-genin/golang_integration_testing/tests"
+	"go.uber.org/mock/gomock"
 )
 
 func TestServer(t *testing.T) {
@@ -34,7 +46,7 @@ func TestServer(t *testing.T) {
 	svc := service.NewService(repo)
 
 	// Setup and start the server
-	server := httptest.NewServer(h.NewServer(svc).Handler())
+	server := httptest.NewServer(h.NewServer(svc, h.WithResponseValidation()).Handler())
 	defer server.Close()
 
 	// Setup HTTP client
@@ -98,15 +110,16 @@ func TestServer(t *testing.T) {
 			t.Parallel()
 
 			title := gofakeit.Sentence(3)
-			_, _ = repo.CreateNote(ctx, repository.CreateNoteDTO{Title: title, Description: gofakeit.Sentence(10)})
+			existing, _ := repo.CreateNote(ctx, repository.CreateNoteDTO{Title: title, Description: gofakeit.Sentence(10)})
 
-			httpClient.POST("/v1/notes").
+			resp := httpClient.POST("/v1/notes").
 				WithHeader("Content-Type", "application/json").
-				WithJSON(map[string]string{"title": title, "description": gofakeit.Sentence(10)}).
+				WithJSON(map[string]string{"title": strings.ToUpper(title), "description": gofakeit.Sentence(10)}).
 				Expect().
 				Status(http.StatusConflict).
-				JSON().Object().
-				ContainsSubset(map[string]any{"message": service.ErrNoteTitleTaken.Error()})
+				JSON().Object()
+			resp.Value("message").String().HasPrefix(service.ErrNoteTitleTaken.Error())
+			resp.Value("note_id").String().IsEqual(existing.ID.String())
 		})
 	})
 
@@ -214,7 +227,8 @@ func TestServer(t *testing.T) {
 				Status(http.StatusConflict).
 				JSON().Object().
 				ContainsSubset(map[string]any{
-					"message": service.ErrNoteTitleTaken.Error(),
+					"message": (&service.TitleTakenError{NoteID: noteB.ID}).Error(),
+					"note_id": noteB.ID.String(),
 				})
 		})
 	})
@@ -273,4 +287,1720 @@ func TestServer(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("NoteEvents", func(t *testing.T) {
+		t.Run("should stream note events after the Last-Event-ID given", func(t *testing.T) {
+			t.Parallel()
+
+			noteA, err := repo.CreateNote(ctx, repository.CreateNoteDTO{
+				Title:       gofakeit.Sentence(3),
+				Description: gofakeit.Sentence(10),
+			})
+			assert.NoError(t, err)
+
+			// Find the event recorded for note A
+			events, err := repo.FetchNoteEventsSince(ctx, 0, math.MaxInt32)
+			assert.NoError(t, err)
+
+			var lastEventID int64
+			for _, event := range events {
+				if event.NoteID == noteA.ID {
+					lastEventID = event.ID
+				}
+			}
+			assert.NotZero(t, lastEventID)
+
+			noteB, err := repo.CreateNote(ctx, repository.CreateNoteDTO{
+				Title:       gofakeit.Sentence(3),
+				Description: gofakeit.Sentence(10),
+			})
+			assert.NoError(t, err)
+
+			streamCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, server.URL+"/v1/notes/events", nil)
+			assert.NoError(t, err)
+			req.Header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+			// Note A's event must not be replayed, but note B's must be
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				assert.NotContains(t, line, noteA.ID.String())
+				if strings.HasPrefix(line, "data:") && strings.Contains(line, noteB.ID.String()) {
+					return
+				}
+			}
+			t.Fatal("note event stream ended before the expected event was received")
+		})
+
+		t.Run("should return a 400 status code if the Last-Event-ID is invalid", func(t *testing.T) {
+			t.Parallel()
+
+			httpClient.GET("/v1/notes/events").
+				WithHeader("Last-Event-ID", "not-a-number").
+				Expect().
+				Status(http.StatusBadRequest).
+				JSON().Object().
+				ContainsSubset(map[string]any{"message": "invalid Last-Event-ID"})
+		})
+	})
+
+	t.Run("CollaborativeEditing", func(t *testing.T) {
+		dial := func(t *testing.T, noteID uuid.UUID, name string) *websocket.Conn {
+			url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/notes/" + noteID.String() + "/ws?name=" + name
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			assert.NoError(t, err)
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			return conn
+		}
+
+		// Reads messages until one of the given type is received
+		readUntil := func(t *testing.T, conn *websocket.Conn, messageType string) map[string]any {
+			for {
+				var message map[string]any
+				if err := conn.ReadJSON(&message); err != nil {
+					t.Fatalf("unable to read %s message: %v", messageType, err)
+				}
+				if message["type"] == messageType {
+					return message
+				}
+			}
+		}
+
+		t.Run("should broadcast edits and presence, then persist the edits", func(t *testing.T) {
+			t.Parallel()
+
+			note, err := repo.CreateNote(ctx, repository.CreateNoteDTO{
+				Title:       gofakeit.Sentence(3),
+				Description: "hello world",
+			})
+			assert.NoError(t, err)
+
+			alice := dial(t, note.ID, "alice")
+			init := readUntil(t, alice, "init")
+			assert.Equal(t, "hello world", init["description"])
+
+			bob := dial(t, note.ID, "bob")
+			readUntil(t, bob, "init")
+
+			presence := readUntil(t, alice, "presence")
+			for len(presence["viewers"].([]any)) != 2 {
+				presence = readUntil(t, alice, "presence")
+			}
+
+			// Concurrent edits, both based on version 0
+			err = alice.WriteJSON(map[string]any{
+				"type": "op", "version": 0, "op": map[string]any{"position": 5, "insert": ","},
+			})
+			assert.NoError(t, err)
+			readUntil(t, alice, "ack")
+
+			err = bob.WriteJSON(map[string]any{
+				"type": "op", "version": 0, "op": map[string]any{"position": 11, "insert": "!"},
+			})
+			assert.NoError(t, err)
+
+			op := readUntil(t, bob, "op")
+			assert.Equal(t, ",", op["op"].(map[string]any)["insert"])
+			readUntil(t, bob, "ack")
+
+			op = readUntil(t, alice, "op")
+			assert.Equal(t, float64(12), op["op"].(map[string]any)["position"])
+
+			// Leaving the room persists the edits
+			alice.Close()
+			bob.Close()
+
+			assert.Eventually(t, func() bool {
+				updatedNote, err := repo.FetchNoteByID(ctx, note.ID)
+				return err == nil && updatedNote.Description == "hello, world!"
+			}, 10*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("should return a 404 status code if a non-existent ID is provided", func(t *testing.T) {
+			t.Parallel()
+
+			httpClient.GET("/v1/notes/{id}/ws", uuid.New().String()).
+				Expect().
+				Status(http.StatusNotFound).
+				JSON().Object().
+				ContainsSubset(map[string]any{"message": service.ErrNoteNotFound.Error()})
+		})
+	})
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	router := h.NewServer(service.NewMockService(ctrl)).Handler().(*gin.Engine)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	spec := httpClient.GET("/openapi.json").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	spec.Value("openapi").String().HasPrefix("3.1")
+
+	t.Run("should describe every registered route", func(t *testing.T) {
+		paths := spec.Value("paths").Object()
+
+		for _, route := range router.Routes() {
+			// gin's :param segments are {param} in OpenAPI
+			segments := strings.Split(route.Path, "/")
+			for i, segment := range segments {
+				if strings.HasPrefix(segment, ":") {
+					segments[i] = "{" + strings.TrimPrefix(segment, ":") + "}"
+				}
+			}
+			path := strings.Join(segments, "/")
+
+			if !assert.Contains(t, paths.Keys().Raw(), path, "route %s %s is missing from the spec", route.Method, route.Path) {
+				continue
+			}
+			assert.Contains(
+				t, paths.Value(path).Object().Keys().Raw(), strings.ToLower(route.Method),
+				"route %s %s is missing from the spec", route.Method, route.Path,
+			)
+		}
+	})
+
+	t.Run("should generate the schemas from the DTOs", func(t *testing.T) {
+		schemas := spec.Value("components").Object().Value("schemas").Object()
+
+		createNote := schemas.Value("CreateNoteDTO").Object()
+		createNote.Value("required").Array().ContainsOnly("title", "description")
+		createNote.Value("properties").Object().ContainsKey("title").ContainsKey("description")
+
+		schemas.Value("UpdateNoteDTO").Object().NotContainsKey("required")
+		schemas.Value("Note").Object().Value("properties").Object().
+			ContainsKey("id").ContainsKey("title").ContainsKey("description").
+			ContainsKey("created_at").ContainsKey("updated_at")
+	})
+
+	t.Run("should serve the docs UI", func(t *testing.T) {
+		httpClient.GET("/docs").
+			Expect().
+			Status(http.StatusOK).
+			ContentType("text/html").
+			Body().Contains("/openapi.json")
+	})
+}
+
+func TestRequestValidation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(mockService, h.WithResponseValidation()).Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	t.Run("should reject a whitespace-only title", func(t *testing.T) {
+		httpClient.POST("/v1/notes").
+			WithJSON(map[string]any{"title": "   ", "description": gofakeit.Sentence(10)}).
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().
+			ContainsSubset(map[string]any{
+				"message": "bad request",
+				"errors":  []any{map[string]any{"field": "title", "location": "body", "message": `must match the pattern \S`}},
+			})
+	})
+
+	t.Run("should reject empty strings and unknown fields", func(t *testing.T) {
+		errs := httpClient.POST("/v1/notes").
+			WithJSON(map[string]any{"title": gofakeit.Sentence(3), "description": "", "tags": []string{"a"}}).
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array()
+
+		errs.Length().IsEqual(2)
+		errs.Value(0).Object().ContainsSubset(map[string]any{"field": "description", "message": "must be at least 1 characters long"})
+		errs.Value(1).Object().ContainsSubset(map[string]any{"field": "tags", "message": "is not allowed"})
+	})
+
+	t.Run("should reject fields of the wrong type", func(t *testing.T) {
+		httpClient.PATCH("/v1/notes/{id}", uuid.New()).
+			WithJSON(map[string]any{"title": 42}).
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			ContainsSubset(map[string]any{"field": "title", "message": "must be of type string or null"})
+	})
+
+	t.Run("should reject titles that are too long", func(t *testing.T) {
+		httpClient.POST("/v1/notes").
+			WithJSON(map[string]any{"title": strings.Repeat("a", 256), "description": gofakeit.Sentence(10)}).
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			ContainsSubset(map[string]any{"field": "title", "message": "must be at most 255 characters long"})
+	})
+
+	t.Run("should reject payloads that are too large", func(t *testing.T) {
+		httpClient.POST("/v1/notes").
+			WithBytes([]byte(`{"title":"`+strings.Repeat("a", 2<<20)+`"}`)).
+			WithHeader("Content-Type", "application/json").
+			Expect().
+			Status(http.StatusRequestEntityTooLarge).
+			HasContentType("application/problem+json")
+	})
+
+	t.Run("should reject invalid path params", func(t *testing.T) {
+		httpClient.GET("/v1/notes/not-a-uuid").
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			ContainsSubset(map[string]any{"field": "id", "location": "path", "message": "must be a UUID"})
+	})
+
+	t.Run("should reject invalid query params", func(t *testing.T) {
+		httpClient.GET("/v1/notes/{id}/ws", uuid.New()).
+			WithQuery("name", strings.Repeat("a", 101)).
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			ContainsSubset(map[string]any{"field": "name", "location": "query"})
+	})
+
+	t.Run("should reject unknown conflict strategies", func(t *testing.T) {
+		httpClient.POST("/v1/notes").
+			WithQuery("on_conflict", "ignore").
+			WithJSON(map[string]any{"title": gofakeit.Sentence(3), "description": gofakeit.Sentence(10)}).
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			IsEqual(map[string]any{"field": "on_conflict", "location": "query", "message": "must be one of error, rename, upsert"})
+	})
+
+	t.Run("should pass the conflict strategy to the service", func(t *testing.T) {
+		note := repository.Note{
+			ID: uuid.New(), Title: gofakeit.Sentence(3) + " (2)", Description: gofakeit.Sentence(10),
+			Format: repository.NoteFormatPlain, CreatedAt: time.Now(),
+		}
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), repository.CreateNoteDTO{
+				Title:       strings.TrimSuffix(note.Title, " (2)"),
+				Description: note.Description,
+				OnConflict:  repository.ConflictRename,
+			}).
+			Return(&note, nil)
+
+		httpClient.POST("/v1/notes").
+			WithQuery("on_conflict", "rename").
+			WithJSON(map[string]any{"title": strings.TrimSuffix(note.Title, " (2)"), "description": note.Description}).
+			Expect().
+			Status(http.StatusCreated).
+			JSON().Object().ContainsSubset(map[string]any{"title": note.Title})
+	})
+
+	t.Run("should reply with a 200 status code when upserting updates a note", func(t *testing.T) {
+		note := repository.Note{
+			ID: uuid.New(), Title: gofakeit.Sentence(3), Description: gofakeit.Sentence(10),
+			Format: repository.NoteFormatPlain, CreatedAt: time.Now(), Updated: true,
+		}
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), repository.CreateNoteDTO{
+				Title:       note.Title,
+				Description: note.Description,
+				OnConflict:  repository.ConflictUpsert,
+			}).
+			Return(&note, nil)
+
+		httpClient.POST("/v1/notes").
+			WithQuery("on_conflict", "upsert").
+			WithJSON(map[string]any{"title": note.Title, "description": note.Description}).
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object().ContainsSubset(map[string]any{"id": note.ID.String()}).NotContainsKey("updated")
+	})
+
+	t.Run("should reject sort fields that aren't whitelisted", func(t *testing.T) {
+		httpClient.GET("/v1/notes").
+			WithQuery("sort", "-updated_at,description").
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			ContainsSubset(map[string]any{"field": "sort", "location": "query"})
+	})
+
+	t.Run("should pass filters to the service", func(t *testing.T) {
+		createdAfter := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		filter := repository.NoteFilter{
+			CreatedAfter: &createdAfter,
+			TitlePrefix:  "groceries",
+			Sort:         []repository.NoteSort{{Field: "updated_at", Descending: true}, {Field: "title"}},
+		}
+		mockService.EXPECT().FetchNotesVersion(gomock.Any(), filter).Return(&repository.NotesVersion{}, nil)
+		mockService.EXPECT().FetchNotes(gomock.Any(), filter).Return([]repository.Note{}, nil)
+
+		httpClient.GET("/v1/notes").
+			WithQuery("created_after", createdAfter.Format(time.RFC3339)).
+			WithQuery("title_prefix", "groceries").
+			WithQuery("sort", "-updated_at,title").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Array().IsEmpty()
+	})
+
+	t.Run("should only return the fields requested", func(t *testing.T) {
+		note := repository.Note{ID: uuid.New(), Title: gofakeit.Sentence(3)}.Project(repository.NoteFieldID, repository.NoteFieldTitle)
+		mockService.EXPECT().FetchNotesVersion(gomock.Any(), repository.NoteFilter{}).Return(&repository.NotesVersion{Count: 1}, nil)
+		mockService.EXPECT().
+			FetchNotes(gomock.Any(), repository.NoteFilter{}, repository.NoteFieldID, repository.NoteFieldTitle).
+			Return([]repository.Note{note}, nil)
+
+		httpClient.GET("/v1/notes").
+			WithQuery("fields", "id,title").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Array().Value(0).Object().
+			IsEqual(map[string]any{"id": note.ID.String(), "title": note.Title})
+	})
+
+	t.Run("should reject unknown fields", func(t *testing.T) {
+		httpClient.GET("/v1/notes/{id}", uuid.New()).
+			WithQuery("fields", "id,body").
+			Expect().
+			Status(http.StatusBadRequest).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			ContainsSubset(map[string]any{"field": "fields", "location": "query"})
+	})
+
+	t.Run("should pass valid requests through to the handlers", func(t *testing.T) {
+		createdAt := time.Now()
+		note := repository.Note{
+			ID: uuid.New(), Title: gofakeit.Sentence(3), Description: gofakeit.Sentence(10),
+			Format: repository.NoteFormatPlain, CreatedAt: createdAt,
+		}
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), repository.CreateNoteDTO{Title: note.Title, Description: note.Description}).
+			Return(&note, nil)
+
+		httpClient.POST("/v1/notes").
+			WithJSON(map[string]any{"title": note.Title, "description": note.Description}).
+			Expect().
+			Status(http.StatusCreated).
+			JSON().Object().ContainsSubset(map[string]any{"id": note.ID.String()})
+	})
+
+	t.Run("should return a 422 status code if the note breaks a validation rule", func(t *testing.T) {
+		mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(nil, &service.ValidationError{
+			Violations: []service.Violation{{Field: "title", Message: "must not contain control characters"}},
+		})
+
+		httpClient.POST("/v1/notes").
+			WithJSON(map[string]any{"title": "Bell\a", "description": gofakeit.Sentence(10)}).
+			Expect().
+			Status(http.StatusUnprocessableEntity).
+			JSON().Object().Value("errors").Array().Value(0).Object().
+			IsEqual(map[string]any{"field": "title", "location": "body", "message": "must not contain control characters"})
+	})
+
+	t.Run("should pass documented error responses through", func(t *testing.T) {
+		mockService.EXPECT().DeleteNote(gomock.Any(), gomock.Any()).Return(service.ErrNoteNotFound)
+
+		httpClient.DELETE("/v1/notes/{id}", uuid.New()).
+			Expect().
+			Status(http.StatusNotFound).
+			JSON().Object().ContainsSubset(map[string]any{"message": service.ErrNoteNotFound.Error()})
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(
+		mockService,
+		h.WithResponseValidation(),
+		h.WithCacheControl(http.MethodGet, "/v1/notes/:id", "private, max-age=60"),
+	).Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	note := repository.Note{
+		ID:          uuid.New(),
+		Title:       gofakeit.Sentence(3),
+		Description: gofakeit.Sentence(10),
+		Format:      repository.NoteFormatPlain,
+		CreatedAt:   updatedAt,
+		UpdatedAt:   &updatedAt,
+	}
+
+	t.Run("FetchNoteByID", func(t *testing.T) {
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(&note, nil).AnyTimes()
+
+		resp := httpClient.GET("/v1/notes/{id}", note.ID).Expect().Status(http.StatusOK)
+		resp.Header("Cache-Control").IsEqual("private, max-age=60")
+		resp.Header("Last-Modified").IsEqual("Tue, 02 Jan 2024 03:04:05 GMT")
+		etag := resp.Header("ETag").NotEmpty().Raw()
+
+		t.Run("should return a 304 status code if the ETag matches", func(t *testing.T) {
+			httpClient.GET("/v1/notes/{id}", note.ID).
+				WithHeader("If-None-Match", `W/"other", `+etag).
+				Expect().
+				Status(http.StatusNotModified).
+				Body().IsEmpty()
+		})
+
+		t.Run("should return the note if the ETag doesn't match", func(t *testing.T) {
+			httpClient.GET("/v1/notes/{id}", note.ID).
+				WithHeader("If-None-Match", `W/"other"`).
+				Expect().
+				Status(http.StatusOK)
+		})
+
+		t.Run("should return a 304 status code if the note wasn't modified since", func(t *testing.T) {
+			httpClient.GET("/v1/notes/{id}", note.ID).
+				WithHeader("If-Modified-Since", "Tue, 02 Jan 2024 03:04:05 GMT").
+				Expect().
+				Status(http.StatusNotModified)
+
+			httpClient.GET("/v1/notes/{id}", note.ID).
+				WithHeader("If-Modified-Since", "Tue, 02 Jan 2024 03:04:04 GMT").
+				Expect().
+				Status(http.StatusOK)
+		})
+
+		t.Run("should give projections their own ETag", func(t *testing.T) {
+			projected := note.Project(repository.NoteFieldTitle, repository.NoteFieldUpdatedAt)
+			mockService.EXPECT().
+				FetchNoteByID(gomock.Any(), note.ID, repository.NoteFieldTitle, repository.NoteFieldUpdatedAt).
+				Return(&projected, nil)
+
+			resp := httpClient.GET("/v1/notes/{id}", note.ID).
+				WithQuery("fields", "title").
+				WithHeader("If-None-Match", etag).
+				Expect().
+				Status(http.StatusOK)
+			resp.Header("ETag").NotEqual(etag)
+			resp.JSON().Object().IsEqual(map[string]any{"title": note.Title})
+		})
+	})
+
+	t.Run("FetchNotes", func(t *testing.T) {
+		version := &repository.NotesVersion{Count: 1, LastUpdatedAt: &updatedAt}
+		mockService.EXPECT().FetchNotesVersion(gomock.Any(), repository.NoteFilter{}).Return(version, nil).Times(3)
+		mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{}).Return([]repository.Note{note}, nil).Times(2)
+
+		resp := httpClient.GET("/v1/notes").Expect().Status(http.StatusOK)
+		resp.Header("Cache-Control").IsEqual("no-cache")
+		etag := resp.Header("ETag").NotEmpty().Raw()
+
+		t.Run("should return a 304 status code without fetching the notes if the ETag matches", func(t *testing.T) {
+			httpClient.GET("/v1/notes").
+				WithHeader("If-None-Match", etag).
+				Expect().
+				Status(http.StatusNotModified)
+		})
+
+		t.Run("should change the ETag once a note is deleted", func(t *testing.T) {
+			version.Count = 0
+
+			httpClient.GET("/v1/notes").
+				WithHeader("If-None-Match", etag).
+				Expect().
+				Status(http.StatusOK).
+				Header("ETag").NotEqual(etag)
+		})
+	})
+
+	t.Run("should not cache error responses", func(t *testing.T) {
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), gomock.Not(note.ID)).Return(nil, service.ErrNoteNotFound)
+
+		httpClient.GET("/v1/notes/{id}", uuid.New()).
+			Expect().
+			Status(http.StatusNotFound).
+			Header("Cache-Control").IsEmpty()
+	})
+
+	t.Run("should not cache notes that fail to be fetched once their version is", func(t *testing.T) {
+		mockService.EXPECT().FetchNotesVersion(gomock.Any(), repository.NoteFilter{}).
+			Return(&repository.NotesVersion{Count: 1, LastUpdatedAt: &updatedAt}, nil)
+		mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{}).Return(nil, assert.AnError)
+
+		resp := httpClient.GET("/v1/notes").Expect().Status(http.StatusInternalServerError)
+		resp.Header("Cache-Control").IsEmpty()
+		resp.Header("ETag").IsEmpty()
+		resp.Header("Last-Modified").IsEmpty()
+	})
+}
+
+func TestRenderNote(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(mockService, h.WithResponseValidation()).Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	note := repository.Note{
+		ID:          uuid.New(),
+		Title:       gofakeit.Sentence(3),
+		Description: "# Plans\n\n- [x] Read [the docs](https://go.dev/doc)\n\n<script>alert(1)</script>",
+		Format:      repository.NoteFormatMarkdown,
+		CreatedAt:   time.Now(),
+	}
+
+	t.Run("should include the rendered description given render=html", func(t *testing.T) {
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(&note, nil)
+
+		body := httpClient.GET("/v1/notes/{id}", note.ID).
+			WithQuery("render", "html").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object()
+		body.Value("format").IsEqual("markdown")
+
+		rendered := body.Value("rendered").Object()
+		rendered.Value("html").String().Contains(`<h1 id="plans">Plans</h1>`).NotContains("<script")
+		rendered.Value("headings").IsEqual([]map[string]any{{"level": 1, "text": "Plans", "id": "plans"}})
+		rendered.Value("links").IsEqual([]map[string]any{{"url": "https://go.dev/doc", "text": "the docs"}})
+		rendered.Value("tasks").IsEqual([]map[string]any{{"text": "Read the docs", "done": true}})
+	})
+
+	t.Run("should fetch the description and format to render projected notes", func(t *testing.T) {
+		projected := note.Project(repository.NoteFieldID, repository.NoteFieldDescription, repository.NoteFieldFormat)
+		mockService.EXPECT().
+			FetchNoteByID(gomock.Any(), note.ID,
+				repository.NoteFieldID, repository.NoteFieldUpdatedAt, repository.NoteFieldDescription, repository.NoteFieldFormat).
+			Return(&projected, nil)
+
+		body := httpClient.GET("/v1/notes/{id}", note.ID).
+			WithQuery("fields", "id").
+			WithQuery("render", "html").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Object()
+		body.Keys().ContainsOnly("id", "rendered")
+		body.Path("$.rendered.headings").Array().Length().IsEqual(1)
+	})
+
+	t.Run("should return the sanitized HTML to clients preferring it", func(t *testing.T) {
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(&note, nil)
+
+		resp := httpClient.GET("/v1/notes/{id}", note.ID).
+			WithHeader("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8").
+			Expect().
+			Status(http.StatusOK)
+		resp.ContentType("text/html", "utf-8")
+		resp.Header("Vary").Contains("Accept")
+		resp.Body().Contains(`<a href="https://go.dev/doc" rel="nofollow">the docs</a>`).NotContains("<script")
+	})
+
+	t.Run("should escape plain descriptions", func(t *testing.T) {
+		plain := note
+		plain.Format = repository.NoteFormatPlain
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(&plain, nil)
+
+		httpClient.GET("/v1/notes/{id}", note.ID).
+			WithHeader("Accept", "text/html").
+			Expect().
+			Status(http.StatusOK).
+			Body().HasPrefix("<p># Plans</p>").Contains("&lt;script&gt;")
+	})
+
+	t.Run("should return a 400 status code for unknown render options", func(t *testing.T) {
+		httpClient.GET("/v1/notes/{id}", note.ID).
+			WithQuery("render", "pdf").
+			Expect().
+			Status(http.StatusBadRequest)
+	})
+}
+
+func TestRateLimiting(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	rateLimitedServer := h.NewServer(
+		mockService,
+		h.WithResponseValidation(),
+		h.WithAPIKeys("secret"),
+		h.WithRateLimits(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+			"POST /v1/notes": {Requests: 2, Period: time.Minute},
+		}),
+	)
+	server := httptest.NewServer(rateLimitedServer.Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	createNote := func(apiKey string) *httpexpect.Response {
+		req := httpClient.POST("/v1/notes").WithJSON(map[string]any{
+			"title":       gofakeit.Sentence(3),
+			"description": gofakeit.Sentence(10),
+		})
+		if apiKey != "" {
+			req = req.WithHeader("X-API-Key", apiKey)
+		}
+		return req.Expect()
+	}
+
+	var owners []string
+	mockService.EXPECT().
+		CreateNote(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, dto repository.CreateNoteDTO) (*repository.Note, error) {
+			owners = append(owners, service.OwnerFromContext(ctx))
+			return &repository.Note{ID: uuid.New(), Title: dto.Title, Description: dto.Description, Format: repository.NoteFormatPlain}, nil
+		}).
+		AnyTimes()
+
+	t.Run("should limit the requests of each client", func(t *testing.T) {
+		resp := createNote("").Status(http.StatusCreated)
+		resp.Header("RateLimit-Limit").IsEqual("2")
+		resp.Header("RateLimit-Remaining").IsEqual("1")
+		resp.Header("RateLimit-Policy").IsEqual("2;w=60")
+
+		createNote("").Status(http.StatusCreated).Header("RateLimit-Remaining").IsEqual("0")
+
+		resp = createNote("").Status(http.StatusTooManyRequests)
+		resp.Header("Retry-After").IsEqual("30")
+		resp.JSON().Object().Value("message").String().Contains("too many requests")
+
+		assert.Equal(t, []string{"ip:127.0.0.1", "ip:127.0.0.1"}, owners)
+	})
+
+	t.Run("should identify clients by their API key if it is known", func(t *testing.T) {
+		owners = nil
+
+		createNote("secret").Status(http.StatusCreated)
+		if assert.Len(t, owners, 1) {
+			assert.True(t, strings.HasPrefix(owners[0], "key:"))
+			assert.NotContains(t, owners[0], "secret")
+		}
+	})
+
+	t.Run("should identify clients by their IP if their API key isn't known", func(t *testing.T) {
+		createNote("invented").Status(http.StatusTooManyRequests)
+	})
+
+	t.Run("should not let clients forge X-Forwarded-For to get a fresh bucket", func(t *testing.T) {
+		httpClient.POST("/v1/notes").
+			WithHeader("X-Forwarded-For", "203.0.113.7").
+			WithJSON(map[string]any{"title": gofakeit.Sentence(3), "description": gofakeit.Sentence(10)}).
+			Expect().
+			Status(http.StatusTooManyRequests)
+	})
+
+	t.Run("should identify clients by X-Forwarded-For if it comes from a trusted proxy", func(t *testing.T) {
+		owners = nil
+
+		proxiedServer := httptest.NewServer(h.NewServer(
+			mockService,
+			h.WithTrustedProxies("127.0.0.1"),
+			h.WithRateLimits(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{
+				"POST /v1/notes": {Requests: 1, Period: time.Minute},
+			}),
+		).Handler())
+		defer proxiedServer.Close()
+
+		proxiedClient := httpexpect.Default(t, proxiedServer.URL)
+		createNote := func(forwardedFor string) *httpexpect.Response {
+			return proxiedClient.POST("/v1/notes").
+				WithHeader("X-Forwarded-For", forwardedFor).
+				WithJSON(map[string]any{"title": gofakeit.Sentence(3), "description": gofakeit.Sentence(10)}).
+				Expect()
+		}
+
+		createNote("203.0.113.7").Status(http.StatusCreated)
+		createNote("203.0.113.7").Status(http.StatusTooManyRequests)
+		createNote("203.0.113.8").Status(http.StatusCreated)
+		assert.Equal(t, []string{"ip:203.0.113.7", "ip:203.0.113.8"}, owners)
+	})
+
+	t.Run("should not limit routes without a limit", func(t *testing.T) {
+		mockService.EXPECT().FetchNotesVersion(gomock.Any(), gomock.Any()).Return(&repository.NotesVersion{}, nil)
+		mockService.EXPECT().FetchNotes(gomock.Any(), gomock.Any()).Return([]repository.Note{}, nil)
+
+		httpClient.GET("/v1/notes").Expect().Status(http.StatusOK).Header("RateLimit-Limit").IsEmpty()
+	})
+
+	t.Run("should apply new limits while serving", func(t *testing.T) {
+		mockService.EXPECT().FetchNotesVersion(gomock.Any(), gomock.Any()).Return(&repository.NotesVersion{}, nil)
+		mockService.EXPECT().FetchNotes(gomock.Any(), gomock.Any()).Return([]repository.Note{}, nil)
+
+		rateLimitedServer.SetRateLimits(map[string]ratelimit.Limit{
+			"GET /v1/notes": {Requests: 1, Period: time.Minute},
+		})
+
+		httpClient.GET("/v1/notes").Expect().Status(http.StatusOK).Header("RateLimit-Limit").IsEqual("1")
+		httpClient.GET("/v1/notes").Expect().Status(http.StatusTooManyRequests)
+		createNote("").Status(http.StatusCreated).Header("RateLimit-Limit").IsEmpty()
+	})
+}
+
+func TestContentNegotiation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(
+		mockService,
+		h.WithResponseValidation(),
+		h.WithEncoder("text/plain", titleEncoder{}),
+	).Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	notes := []repository.Note{
+		{
+			ID: uuid.New(), Title: "Groceries", Description: "Milk, \"oat\" if possible",
+			Format: repository.NoteFormatPlain, CreatedAt: createdAt,
+		},
+		{
+			ID: uuid.New(), Title: "Plans", Description: "- [ ] Read",
+			Format: repository.NoteFormatMarkdown, CreatedAt: createdAt, UpdatedAt: &createdAt,
+		},
+	}
+	version := &repository.NotesVersion{Count: len(notes), LastUpdatedAt: &createdAt}
+	mockService.EXPECT().FetchNotesVersion(gomock.Any(), gomock.Any()).Return(version, nil).AnyTimes()
+
+	fetchNotes := func(accept string, fields ...repository.NoteField) *httpexpect.Response {
+		projected := make([]repository.Note, len(notes))
+		for i, note := range notes {
+			projected[i] = note.Project(fields...)
+		}
+		expectedFields := make([]any, len(fields))
+		for i, field := range fields {
+			expectedFields[i] = field
+		}
+		mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{}, expectedFields...).Return(projected, nil)
+
+		req := httpClient.GET("/v1/notes").WithHeader("Accept", accept)
+		if len(fields) > 0 {
+			names := make([]string, len(fields))
+			for i, field := range fields {
+				names[i] = string(field)
+			}
+			req = req.WithQuery("fields", strings.Join(names, ","))
+		}
+		return req.Expect().Status(http.StatusOK)
+	}
+
+	t.Run("should default to JSON", func(t *testing.T) {
+		resp := fetchNotes("*/*")
+		resp.ContentType("application/json", "utf-8")
+		resp.Header("Vary").Contains("Accept")
+		resp.JSON().Array().Length().IsEqual(len(notes))
+	})
+
+	t.Run("should encode notes as CSV with a column per field", func(t *testing.T) {
+		resp := fetchNotes("text/csv", repository.NoteFieldTitle, repository.NoteFieldDescription, repository.NoteFieldUpdatedAt)
+		resp.ContentType("text/csv", "utf-8")
+		resp.Body().IsEqual("title,description,updated_at\n" +
+			"Groceries,\"Milk, \"\"oat\"\" if possible\",\n" +
+			"Plans,'- [ ] Read,2024-01-02T03:04:05Z\n")
+	})
+
+	t.Run("should prefix CSV cells spreadsheets would run as formulas with a quote", func(t *testing.T) {
+		for title, cell := range map[string]string{
+			`=HYPERLINK("https://example.com")`: `"'=HYPERLINK(""https://example.com"")"`,
+			"+1":                                "'+1",
+			"-1":                                "'-1",
+			"@SUM(A1)":                          "'@SUM(A1)",
+			"1+1":                               "1+1",
+		} {
+			mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{}, repository.NoteFieldTitle).
+				Return([]repository.Note{{Title: title}}, nil)
+
+			httpClient.GET("/v1/notes").
+				WithHeader("Accept", "text/csv").
+				WithQuery("fields", "title").
+				Expect().
+				Status(http.StatusOK).
+				Body().IsEqual("title\n" + cell + "\n")
+		}
+	})
+
+	t.Run("should stream notes as newline delimited JSON", func(t *testing.T) {
+		mockService.EXPECT().StreamNotes(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, fn func(repository.Note) error) error {
+				for _, note := range notes {
+					if err := fn(note); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		)
+
+		resp := httpClient.GET("/v1/notes").
+			WithHeader("Accept", "application/x-ndjson").
+			WithQuery("fields", "id").
+			Expect().
+			Status(http.StatusOK)
+		resp.ContentType("application/x-ndjson", "utf-8")
+		resp.Header("ETag").NotEmpty()
+		resp.Body().IsEqual(`{"id":"` + notes[0].ID.String() + `"}` + "\n" + `{"id":"` + notes[1].ID.String() + `"}` + "\n")
+	})
+
+	t.Run("should fetch filtered notes at once as newline delimited JSON", func(t *testing.T) {
+		mockService.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{TitlePrefix: "Gro"}).Return(notes[:1], nil)
+
+		httpClient.GET("/v1/notes").
+			WithHeader("Accept", "application/x-ndjson").
+			WithQuery("title_prefix", "Gro").
+			Expect().
+			Status(http.StatusOK).
+			Body().IsEqual(`{"id":"` + notes[0].ID.String() + `","title":"Groceries","description":"Milk, \"oat\" if possible",` +
+			`"format":"plain","created_at":"2024-01-02T03:04:05Z","updated_at":null}` + "\n")
+	})
+
+	t.Run("should abort the stream if it fails past the first note", func(t *testing.T) {
+		mockService.EXPECT().StreamNotes(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, fn func(repository.Note) error) error {
+				if err := fn(notes[0]); err != nil {
+					return err
+				}
+				return assert.AnError
+			},
+		)
+
+		// Without validating responses, which buffers them until the handler returns
+		server := httptest.NewServer(h.NewServer(mockService).Handler())
+		defer server.Close()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/notes", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept", "application/x-ndjson")
+		resp, err := http.DefaultClient.Do(req)
+		if assert.NoError(t, err) {
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			assert.Error(t, err)
+			assert.Contains(t, string(body), notes[0].ID.String())
+		}
+	})
+
+	t.Run("should encode notes as YAML with their fields in order", func(t *testing.T) {
+		resp := fetchNotes("application/yaml", repository.NoteFieldTitle, repository.NoteFieldFormat)
+		resp.ContentType("application/yaml", "utf-8")
+		resp.Body().IsEqual("- title: Groceries\n  format: plain\n- title: Plans\n  format: markdown\n")
+	})
+
+	t.Run("should encode notes as Markdown documents", func(t *testing.T) {
+		resp := fetchNotes("text/markdown", repository.NoteFieldTitle, repository.NoteFieldFormat, repository.NoteFieldDescription)
+		resp.ContentType("text/markdown", "utf-8")
+		resp.Body().IsEqual("# Groceries\n\n- format: plain\n\nMilk, \"oat\" if possible\n" +
+			"\n---\n\n" +
+			"# Plans\n\n- format: markdown\n\n- [ ] Read\n")
+	})
+
+	t.Run("should pick the media type the client prefers most", func(t *testing.T) {
+		fetchNotes("text/csv;q=0.5, application/yaml, application/json;q=0.9").ContentType("application/yaml")
+		fetchNotes("text/*;q=0.8, text/markdown;q=0.1").ContentType("text/csv")
+	})
+
+	t.Run("should encode notes with encoders registered with WithEncoder", func(t *testing.T) {
+		fetchNotes("text/plain", repository.NoteFieldTitle).
+			ContentType("text/plain").
+			Body().IsEqual("Groceries\nPlans\n")
+	})
+
+	t.Run("should encode a single note", func(t *testing.T) {
+		mockService.EXPECT().FetchNoteByID(gomock.Any(), notes[1].ID).Return(&notes[1], nil)
+
+		resp := httpClient.GET("/v1/notes/{id}", notes[1].ID).
+			WithHeader("Accept", "application/yaml").
+			Expect().
+			Status(http.StatusOK)
+		resp.ContentType("application/yaml", "utf-8")
+		resp.Body().IsEqual("id: " + notes[1].ID.String() + "\n" +
+			"title: Plans\n" +
+			"description: '- [ ] Read'\n" +
+			"format: markdown\n" +
+			"created_at: 2024-01-02T03:04:05Z\n" +
+			"updated_at: 2024-01-02T03:04:05Z\n")
+	})
+
+	t.Run("should return a 406 status code if no media type is acceptable", func(t *testing.T) {
+		httpClient.GET("/v1/notes").
+			WithHeader("Accept", "image/png, application/json;q=0").
+			Expect().
+			Status(http.StatusNotAcceptable).
+			JSON().Object().Value("message").String().Contains("text/csv")
+
+		httpClient.GET("/v1/notes/{id}", notes[0].ID).
+			WithQuery("render", "html").
+			WithHeader("Accept", "text/csv").
+			Expect().
+			Status(http.StatusNotAcceptable)
+	})
+
+	t.Run("should document the media types in the OpenAPI document", func(t *testing.T) {
+		content := httpClient.GET("/openapi.json").
+			Expect().
+			Status(http.StatusOK).
+			JSON().Path(`$.paths["/v1/notes"].get.responses["200"].content`).Object()
+		content.Keys().ContainsAll("application/json", "application/x-ndjson", "text/csv", "application/yaml", "text/markdown", "text/plain")
+	})
+}
+
+// Encodes notes as their titles, one per line.
+type titleEncoder struct{}
+
+func (e titleEncoder) EncodeNote(w io.Writer, note repository.Note, fields []repository.NoteField) error {
+	return e.EncodeNotes(w, []repository.Note{note}, fields)
+}
+
+func (titleEncoder) EncodeNotes(w io.Writer, notes []repository.Note, _ []repository.NoteField) error {
+	for _, note := range notes {
+		if _, err := fmt.Fprintln(w, note.Title); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportNotes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(mockService, h.WithResponseValidation()).Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	notes := []repository.Note{
+		{
+			ID: uuid.New(), Title: "Groceries for the week", Description: "Milk",
+			Format: repository.NoteFormatPlain, CreatedAt: createdAt,
+		},
+		{
+			ID: uuid.New(), Title: "Café ☕", Description: "- [ ] Espresso\n",
+			Format: repository.NoteFormatMarkdown, CreatedAt: createdAt, UpdatedAt: &createdAt,
+		},
+	}
+	streamNotes := func(notes []repository.Note, err error) {
+		mockService.EXPECT().
+			StreamNotes(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, fn func(repository.Note) error) error {
+				for _, note := range notes {
+					if err := fn(note); err != nil {
+						return err
+					}
+				}
+				return err
+			})
+	}
+
+	t.Run("should stream notes as newline delimited JSON by default", func(t *testing.T) {
+		streamNotes(notes, nil)
+
+		resp := httpClient.GET("/v1/notes/export").Expect().Status(http.StatusOK)
+		resp.ContentType("application/x-ndjson")
+		resp.Header("Content-Disposition").IsEqual(`attachment; filename="notes.ndjson"`)
+
+		lines := strings.Split(strings.TrimSuffix(resp.Body().Raw(), "\n"), "\n")
+		assert.Len(t, lines, len(notes))
+		assert.Contains(t, lines[1], `"title":"Café ☕"`)
+	})
+
+	t.Run("should stream notes as CSV", func(t *testing.T) {
+		streamNotes(notes, nil)
+
+		httpClient.GET("/v1/notes/export").
+			WithQuery("format", "csv").
+			Expect().
+			Status(http.StatusOK).
+			Body().IsEqual("id,title,description,format,created_at,updated_at\n" +
+			notes[0].ID.String() + ",Groceries for the week,Milk,plain,2024-01-02T03:04:05Z,\n" +
+			notes[1].ID.String() + ",Café ☕,\"'- [ ] Espresso\n\",markdown,2024-01-02T03:04:05Z,2024-01-02T03:04:05Z\n")
+	})
+
+	t.Run("should stream a ZIP of Markdown files with front matter", func(t *testing.T) {
+		streamNotes(notes, nil)
+
+		body := httpClient.GET("/v1/notes/export").
+			WithQuery("format", "zip").
+			Expect().
+			Status(http.StatusOK).
+			ContentType("application/zip").
+			Body().Raw()
+
+		archive, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		names := make([]string, len(archive.File))
+		for i, file := range archive.File {
+			names[i] = file.Name
+		}
+		assert.Equal(t, []string{
+			"groceries-for-the-week-" + notes[0].ID.String()[:8] + ".md",
+			"café-" + notes[1].ID.String()[:8] + ".md",
+		}, names)
+
+		file, err := archive.File[1].Open()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer file.Close()
+		document, err := io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "---\n"+
+			"id: "+notes[1].ID.String()+"\n"+
+			"title: Café ☕\n"+
+			"format: markdown\n"+
+			"created_at: 2024-01-02T03:04:05Z\n"+
+			"updated_at: 2024-01-02T03:04:05Z\n"+
+			"---\n\n"+
+			"- [ ] Espresso\n", string(document))
+	})
+
+	t.Run("should export an empty ZIP if there are no notes", func(t *testing.T) {
+		streamNotes(nil, nil)
+
+		body := httpClient.GET("/v1/notes/export").
+			WithQuery("format", "zip").
+			Expect().
+			Status(http.StatusOK).
+			Body().Raw()
+
+		archive, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+		assert.NoError(t, err)
+		assert.Empty(t, archive.File)
+	})
+
+	t.Run("should return a 500 status code if the notes can't be streamed", func(t *testing.T) {
+		streamNotes(nil, service.ErrInternal)
+
+		httpClient.GET("/v1/notes/export").Expect().Status(http.StatusInternalServerError)
+	})
+
+	t.Run("should abort the response if the notes can't be streamed once the export has started", func(t *testing.T) {
+		streamNotes(notes[:1], service.ErrInternal)
+
+		// Responses aren't validated, so the first note is sent before the export fails
+		unvalidatedServer := httptest.NewServer(h.NewServer(mockService).Handler())
+		defer unvalidatedServer.Close()
+
+		resp, err := http.Get(unvalidatedServer.URL + "/v1/notes/export")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Contains(t, string(body), notes[0].ID.String())
+	})
+
+	t.Run("should return a 400 status code for unknown formats", func(t *testing.T) {
+		httpClient.GET("/v1/notes/export").
+			WithQuery("format", "xml").
+			Expect().
+			Status(http.StatusBadRequest)
+	})
+}
+
+func TestNoteEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(mockService).Handler())
+	defer server.Close()
+
+	// The events the server fetches at a time when a client resumes
+	const pageSize = 100
+
+	events := func(from, to int64) []repository.NoteEvent {
+		events := []repository.NoteEvent{}
+		for id := from; id <= to; id++ {
+			events = append(events, repository.NoteEvent{ID: id, Type: repository.NoteEventCreated, NoteID: uuid.New()})
+		}
+		return events
+	}
+
+	// Expects the listener to be started, passing on the function it broadcasts events with,
+	// and closing the channel returned once it is stopped
+	expectListen := func(broadcast chan<- func(repository.NoteEvent) error) <-chan struct{} {
+		stopped := make(chan struct{})
+		mockService.EXPECT().
+			ListenNoteEvents(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, listening func(), fn func(repository.NoteEvent) error) error {
+				listening()
+				broadcast <- fn
+				<-ctx.Done()
+				close(stopped)
+				return nil
+			})
+		return stopped
+	}
+
+	resume := func(t *testing.T, lastEventID int64) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/notes/events", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	waitFor := func(t *testing.T, ch <-chan struct{}, what string) {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	t.Run("should listen before replaying missed events a page at a time, sending each event once", func(t *testing.T) {
+		broadcast := make(chan func(repository.NoteEvent) error, 1)
+		stopped := expectListen(broadcast)
+
+		firstPage := mockService.EXPECT().
+			FetchNoteEventsSince(gomock.Any(), int64(1), pageSize).
+			DoAndReturn(func(context.Context, int64, int) ([]repository.NoteEvent, error) {
+				// The listener is listening, and hears of events committed while replaying
+				fn := <-broadcast
+				assert.NoError(t, fn(events(50, 50)[0]))
+				assert.NoError(t, fn(events(103, 103)[0]))
+				return events(2, pageSize+1), nil
+			})
+		mockService.EXPECT().
+			FetchNoteEventsSince(gomock.Any(), int64(pageSize+1), pageSize).
+			Return(events(pageSize+2, pageSize+2), nil).
+			After(firstPage)
+
+		resp := resume(t, 1)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var ids []string
+		scanner := bufio.NewScanner(resp.Body)
+		for len(ids) < 102 && scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id:"); ok {
+				ids = append(ids, id)
+			}
+		}
+		resp.Body.Close()
+
+		expected := make([]string, 102)
+		for i := range expected {
+			expected[i] = strconv.Itoa(i + 2)
+		}
+		assert.Equal(t, expected, ids)
+
+		waitFor(t, stopped, "the listener to stop once the client left")
+	})
+
+	t.Run("should send live events recorded by later transactions, whatever their IDs", func(t *testing.T) {
+		broadcast := make(chan func(repository.NoteEvent) error, 1)
+		stopped := expectListen(broadcast)
+
+		replayed := repository.NoteEvent{ID: 3, TransactionID: 10, Type: repository.NoteEventCreated, NoteID: uuid.New()}
+		mockService.EXPECT().
+			FetchNoteEventsSince(gomock.Any(), int64(1), pageSize).
+			DoAndReturn(func(context.Context, int64, int) ([]repository.NoteEvent, error) {
+				fn := <-broadcast
+				assert.NoError(t, fn(replayed))
+				// Took its ID first, but was recorded by a transaction that committed later
+				assert.NoError(t, fn(repository.NoteEvent{ID: 2, TransactionID: 11, Type: repository.NoteEventCreated, NoteID: uuid.New()}))
+				return []repository.NoteEvent{replayed}, nil
+			})
+
+		resp := resume(t, 1)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var ids []string
+		scanner := bufio.NewScanner(resp.Body)
+		for len(ids) < 2 && scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id:"); ok {
+				ids = append(ids, id)
+			}
+		}
+		resp.Body.Close()
+
+		assert.Equal(t, []string{"3", "2"}, ids)
+		waitFor(t, stopped, "the listener to stop once the client left")
+	})
+
+	t.Run("should stop listening once the last subscriber is dropped for falling behind", func(t *testing.T) {
+		broadcast := make(chan func(repository.NoteEvent) error, 1)
+		stopped := expectListen(broadcast)
+
+		mockService.EXPECT().
+			FetchNoteEventsSince(gomock.Any(), int64(1), pageSize).
+			DoAndReturn(func(context.Context, int64, int) ([]repository.NoteEvent, error) {
+				// More events are committed while replaying than the subscriber can hold
+				fn := <-broadcast
+				for _, event := range events(2, 1000) {
+					assert.NoError(t, fn(event))
+				}
+				waitFor(t, stopped, "the listener to stop")
+				return nil, nil
+			})
+
+		resp := resume(t, 1)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// The stream ends, so the client resumes with Last-Event-ID
+		_, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return a 500 status code if the listener fails to listen", func(t *testing.T) {
+		mockService.EXPECT().
+			ListenNoteEvents(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(service.ErrInternal)
+
+		resp := resume(t, 1)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestImportNotes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(mockService, h.WithResponseValidation()).Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	// Polls the job at the location until it has finished
+	awaitJob := func(t *testing.T, location string) *httpexpect.Object {
+		var job *httpexpect.Object
+		assert.Eventually(t, func() bool {
+			job = httpClient.GET(location).Expect().Status(http.StatusOK).JSON().Object()
+			return job.Value("status").String().Raw() != "running"
+		}, 5*time.Second, 10*time.Millisecond)
+		return job
+	}
+
+	t.Run("should import notes in the background, reporting the result of each", func(t *testing.T) {
+		note := repository.Note{ID: uuid.New(), Title: "Groceries (2)", Description: "Milk", Format: repository.NoteFormatPlain}
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), repository.CreateNoteDTO{Title: "Groceries", Description: "Milk", OnConflict: repository.ConflictRename}).
+			Return(&note, nil)
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), repository.CreateNoteDTO{Description: "Eggs", OnConflict: repository.ConflictRename}).
+			Return(nil, &service.ValidationError{Violations: []service.Violation{{Field: "title", Message: "must not be empty"}}})
+
+		resp := httpClient.POST("/v1/notes/import").
+			WithQuery("format", "csv").
+			WithQuery("on_conflict", "rename").
+			WithHeader("Content-Type", "text/csv").
+			WithText("title,description\nGroceries,Milk\n,Eggs\n").
+			Expect().
+			Status(http.StatusAccepted)
+
+		started := resp.JSON().Object()
+		started.HasValue("format", "csv")
+		started.HasValue("on_conflict", "rename")
+		location := resp.Header("Location").Raw()
+		assert.Equal(t, "/v1/notes/import/"+started.Value("id").String().Raw(), location)
+
+		job := awaitJob(t, location)
+		job.HasValue("status", "succeeded")
+		job.HasValue("imported", 1)
+		job.HasValue("failed", 1)
+		job.Value("finished_at").NotNull()
+
+		results := job.Value("results").Array()
+		results.Length().IsEqual(2)
+		results.Value(0).Object().
+			HasValue("row", 1).
+			HasValue("status", "imported").
+			HasValue("note_id", note.ID.String()).
+			HasValue("title", "Groceries (2)")
+		results.Value(1).Object().
+			HasValue("row", 2).
+			HasValue("status", "failed").
+			HasValue("violations", []any{map[string]any{"field": "title", "message": "must not be empty"}})
+	})
+
+	t.Run("should fail the job if the file can't be read", func(t *testing.T) {
+		resp := httpClient.POST("/v1/notes/import").
+			WithQuery("format", "zip").
+			WithHeader("Content-Type", "application/zip").
+			WithText("not a zip").
+			Expect().
+			Status(http.StatusAccepted)
+
+		job := awaitJob(t, resp.Header("Location").Raw())
+		job.HasValue("status", "failed")
+		job.Value("error").String().Contains("invalid ZIP archive")
+		job.Value("results").Array().IsEmpty()
+	})
+
+	t.Run("should return a 400 status code for unknown formats and conflict strategies", func(t *testing.T) {
+		httpClient.POST("/v1/notes/import").
+			WithQuery("format", "xml").
+			WithText("<notes/>").
+			Expect().
+			Status(http.StatusBadRequest)
+
+		httpClient.POST("/v1/notes/import").
+			WithQuery("on_conflict", "skip").
+			WithText(`{"title":"Groceries","description":"Milk"}`).
+			Expect().
+			Status(http.StatusBadRequest)
+	})
+
+	t.Run("should return a 400 status code if the request body is empty", func(t *testing.T) {
+		httpClient.POST("/v1/notes/import").Expect().Status(http.StatusBadRequest)
+	})
+
+	t.Run("should return a 404 status code for unknown import jobs", func(t *testing.T) {
+		httpClient.GET("/v1/notes/import/" + uuid.NewString()).Expect().Status(http.StatusNotFound)
+	})
+
+	// Starts a server limiting clients to creating notes at the rate given, identifying them by API key
+	newLimitedClient := func(t *testing.T, limit ratelimit.Limit, apiKeys ...string) *httpexpect.Expect {
+		server := httptest.NewServer(h.NewServer(
+			mockService,
+			h.WithResponseValidation(),
+			h.WithAPIKeys(apiKeys...),
+			h.WithRateLimits(ratelimit.NewMemoryStore(), map[string]ratelimit.Limit{"POST /v1/notes": limit}),
+		).Handler())
+		t.Cleanup(server.Close)
+
+		return httpexpect.WithConfig(httpexpect.Config{
+			BaseURL:  server.URL,
+			Reporter: httpexpect.NewRequireReporter(t),
+			Client:   http.DefaultClient,
+		})
+	}
+	twoNotes := "title\nGroceries\nChores\n"
+
+	t.Run("should only show import jobs to the client that started them", func(t *testing.T) {
+		mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(&repository.Note{ID: uuid.New()}, nil).Times(2)
+		alice, bob := uuid.NewString(), uuid.NewString()
+		httpClient := newLimitedClient(t, ratelimit.Limit{Requests: 10, Period: time.Hour}, alice, bob)
+
+		location := httpClient.POST("/v1/notes/import").
+			WithHeader("X-API-Key", alice).
+			WithQuery("format", "csv").
+			WithText(twoNotes).
+			Expect().
+			Status(http.StatusAccepted).
+			Header("Location").Raw()
+
+		assert.Eventually(t, func() bool {
+			job := httpClient.GET(location).WithHeader("X-API-Key", alice).Expect().Status(http.StatusOK).JSON().Object()
+			return job.Value("status").String().Raw() != "running"
+		}, 5*time.Second, 10*time.Millisecond)
+		httpClient.GET(location).WithHeader("X-API-Key", bob).Expect().Status(http.StatusNotFound)
+		httpClient.GET(location).Expect().Status(http.StatusNotFound)
+	})
+
+	t.Run("should count imported notes against the client's limit for creating notes", func(t *testing.T) {
+		mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(&repository.Note{ID: uuid.New()}, nil).Times(2)
+		httpClient := newLimitedClient(t, ratelimit.Limit{Requests: 2, Period: time.Hour})
+
+		resp := httpClient.POST("/v1/notes/import").
+			WithQuery("format", "csv").
+			WithText(twoNotes).
+			Expect().
+			Status(http.StatusAccepted)
+
+		var job *httpexpect.Object
+		assert.Eventually(t, func() bool {
+			job = httpClient.GET(resp.Header("Location").Raw()).Expect().Status(http.StatusOK).JSON().Object()
+			return job.Value("status").String().Raw() != "running"
+		}, 5*time.Second, 10*time.Millisecond)
+		job.HasValue("imported", 2)
+
+		httpClient.POST("/v1/notes").
+			WithJSON(map[string]any{"title": "Groceries", "description": "Milk"}).
+			Expect().
+			Status(http.StatusTooManyRequests)
+	})
+
+	t.Run("should wait for the client's tokens to import more notes, running an import per client", func(t *testing.T) {
+		mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(&repository.Note{ID: uuid.New()}, nil)
+		httpClient := newLimitedClient(t, ratelimit.Limit{Requests: 1, Period: time.Hour})
+
+		location := httpClient.POST("/v1/notes/import").
+			WithQuery("format", "csv").
+			WithText(twoNotes).
+			Expect().
+			Status(http.StatusAccepted).
+			Header("Location").Raw()
+
+		assert.Eventually(t, func() bool {
+			job := httpClient.GET(location).Expect().Status(http.StatusOK).JSON().Object()
+			return job.Value("imported").Number().Raw() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		httpClient.GET(location).Expect().Status(http.StatusOK).JSON().Object().HasValue("status", "running")
+
+		httpClient.POST("/v1/notes/import").
+			WithQuery("format", "csv").
+			WithText(twoNotes).
+			Expect().
+			Status(http.StatusTooManyRequests)
+	})
+
+	t.Run("should return a 503 status code while the server runs as many imports as it can", func(t *testing.T) {
+		apiKeys := make([]string, 9)
+		for i := range apiKeys {
+			apiKeys[i] = uuid.NewString()
+		}
+		mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(&repository.Note{ID: uuid.New()}, nil).AnyTimes()
+		httpClient := newLimitedClient(t, ratelimit.Limit{Requests: 1, Period: time.Hour}, apiKeys...)
+
+		for i, apiKey := range apiKeys {
+			status := http.StatusAccepted
+			if i == len(apiKeys)-1 {
+				status = http.StatusServiceUnavailable
+			}
+			httpClient.POST("/v1/notes/import").
+				WithHeader("X-API-Key", apiKey).
+				WithQuery("format", "csv").
+				WithText(twoNotes).
+				Expect().
+				Status(status)
+		}
+	})
+}
+
+func TestNoteQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	server := httptest.NewServer(h.NewServer(mockService, h.WithResponseValidation()).Handler())
+	defer server.Close()
+
+	httpClient := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewRequireReporter(t),
+		Client:   http.DefaultClient,
+	})
+
+	t.Run("should return a 403 status code once the note quota is reached", func(t *testing.T) {
+		mockService.EXPECT().CreateNote(gomock.Any(), gomock.Any()).Return(nil, service.ErrNoteQuotaExceeded)
+
+		httpClient.POST("/v1/notes").
+			WithJSON(map[string]any{"title": gofakeit.Sentence(3), "description": gofakeit.Sentence(10)}).
+			Expect().
+			Status(http.StatusForbidden).
+			JSON().Object().Value("message").IsEqual(service.ErrNoteQuotaExceeded.Error())
+	})
+}
+
+func TestSecurityMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	newClient := func(t *testing.T, opts ...h.Option) *httpexpect.Expect {
+		server := httptest.NewServer(h.NewServer(mockService, append(opts, h.WithResponseValidation())...).Handler())
+		t.Cleanup(server.Close)
+
+		return httpexpect.WithConfig(httpexpect.Config{
+			BaseURL:  server.URL,
+			Reporter: httpexpect.NewRequireReporter(t),
+			Client:   http.DefaultClient,
+		})
+	}
+
+	t.Run("Security headers", func(t *testing.T) {
+		t.Run("should set the security headers on every response", func(t *testing.T) {
+			httpClient := newClient(t)
+
+			for _, resp := range []*httpexpect.Response{
+				httpClient.GET("/openapi.json").Expect().Status(http.StatusOK),
+				httpClient.GET("/not-a-route").Expect().Status(http.StatusNotFound),
+			} {
+				resp.Header("Strict-Transport-Security").IsEqual("max-age=63072000; includeSubDomains")
+				resp.Header("X-Content-Type-Options").IsEqual("nosniff")
+				resp.Header("X-Frame-Options").IsEqual("DENY")
+				resp.Header("Content-Security-Policy").IsEqual("default-src 'none'; frame-ancestors 'none'")
+			}
+		})
+
+		t.Run("should let the docs page load Swagger UI", func(t *testing.T) {
+			newClient(t).GET("/docs").
+				Expect().
+				Status(http.StatusOK).
+				Header("Content-Security-Policy").
+				Contains("script-src 'self' 'sha256-")
+		})
+
+		t.Run("should serve the Swagger UI the docs page loads from this origin", func(t *testing.T) {
+			httpClient := newClient(t)
+			httpClient.GET("/docs").
+				Expect().
+				Status(http.StatusOK).
+				Body().
+				Contains(`src="/docs/assets/swagger-ui-bundle.js"`).
+				Contains(`href="/docs/assets/swagger-ui.css"`).
+				NotContains("https://")
+
+			httpClient.GET("/docs/assets/swagger-ui-bundle.js").
+				Expect().
+				Status(http.StatusOK).
+				HasContentType("text/javascript")
+			httpClient.GET("/docs/assets/swagger-ui.css").
+				Expect().
+				Status(http.StatusOK).
+				HasContentType("text/css")
+			httpClient.GET("/docs/assets/LICENSE").
+				Expect().
+				Status(http.StatusNotFound)
+		})
+
+		t.Run("should let security headers be overridden", func(t *testing.T) {
+			resp := newClient(t,
+				h.WithSecurityHeader("Strict-Transport-Security", ""),
+				h.WithSecurityHeader("X-Frame-Options", "SAMEORIGIN"),
+			).GET("/openapi.json").Expect()
+
+			resp.Header("Strict-Transport-Security").IsEmpty()
+			resp.Header("X-Frame-Options").IsEqual("SAMEORIGIN")
+		})
+	})
+
+	t.Run("CORS", func(t *testing.T) {
+		httpClient := newClient(t, h.WithCORS(h.CORSConfig{
+			AllowedOrigins:   []string{"https://app.example.com"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		}))
+
+		t.Run("should answer preflight requests from allowed origins", func(t *testing.T) {
+			resp := httpClient.OPTIONS("/v1/notes/{id}", uuid.New()).
+				WithHeader("Origin", "https://app.example.com").
+				WithHeader("Access-Control-Request-Method", http.MethodPatch).
+				WithHeader("Access-Control-Request-Headers", "content-type").
+				Expect().
+				Status(http.StatusNoContent)
+
+			resp.Header("Access-Control-Allow-Origin").IsEqual("https://app.example.com")
+			resp.Header("Access-Control-Allow-Credentials").IsEqual("true")
+			resp.Header("Access-Control-Allow-Methods").IsEqual("GET, POST, PATCH, DELETE")
+			resp.Header("Access-Control-Allow-Headers").Contains("Content-Type")
+			resp.Header("Access-Control-Max-Age").IsEqual("600")
+		})
+
+		t.Run("should reject preflight requests from other origins or for other methods", func(t *testing.T) {
+			httpClient.OPTIONS("/v1/notes").
+				WithHeader("Origin", "https://evil.example.com").
+				WithHeader("Access-Control-Request-Method", http.MethodPost).
+				Expect().
+				Status(http.StatusForbidden).
+				Header("Access-Control-Allow-Origin").IsEmpty()
+
+			httpClient.OPTIONS("/v1/notes").
+				WithHeader("Origin", "https://app.example.com").
+				WithHeader("Access-Control-Request-Method", http.MethodPut).
+				Expect().
+				Status(http.StatusForbidden)
+		})
+
+		t.Run("should share responses with allowed origins", func(t *testing.T) {
+			mockService.EXPECT().DeleteNote(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+			resp := httpClient.DELETE("/v1/notes/{id}", uuid.New()).
+				WithHeader("Origin", "https://app.example.com").
+				Expect().
+				Status(http.StatusNoContent)
+			resp.Header("Access-Control-Allow-Origin").IsEqual("https://app.example.com")
+			resp.Header("Access-Control-Expose-Headers").Contains("ETag")
+			resp.Header("Vary").Contains("Origin")
+
+			httpClient.DELETE("/v1/notes/{id}", uuid.New()).
+				WithHeader("Origin", "https://evil.example.com").
+				Expect().
+				Status(http.StatusNoContent).
+				Header("Access-Control-Allow-Origin").IsEmpty()
+		})
+
+		t.Run("should never allow credentials for origins only matched by a wildcard", func(t *testing.T) {
+			resp := newClient(t, h.WithCORS(h.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})).
+				GET("/openapi.json").
+				WithHeader("Origin", "https://evil.example.com").
+				Expect()
+
+			resp.Header("Access-Control-Allow-Origin").IsEqual("*")
+			resp.Header("Access-Control-Allow-Credentials").IsEmpty()
+		})
+	})
+
+	t.Run("Body size", func(t *testing.T) {
+		httpClient := newClient(t, h.WithMaxBodySize(100))
+		body := `{"title":"` + strings.Repeat("a", 200) + `","description":"a"}`
+
+		t.Run("should reject bodies declared too large", func(t *testing.T) {
+			httpClient.POST("/v1/notes").
+				WithBytes([]byte(body)).
+				WithHeader("Content-Type", "application/json").
+				Expect().
+				Status(http.StatusRequestEntityTooLarge).
+				HasContentType("application/problem+json").
+				JSON(httpexpect.ContentOpts{MediaType: "application/problem+json"}).Object().
+				IsEqual(map[string]any{
+					"type":   "about:blank",
+					"title":  "Request Entity Too Large",
+					"status": 413,
+					"detail": "request body must not exceed 100 bytes",
+				})
+		})
+
+		t.Run("should reject streamed bodies once they grow too large", func(t *testing.T) {
+			httpClient.POST("/v1/notes").
+				WithChunked(strings.NewReader(body)).
+				WithHeader("Content-Type", "application/json").
+				Expect().
+				Status(http.StatusRequestEntityTooLarge).
+				HasContentType("application/problem+json")
+		})
+	})
 }
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Configures serving HTTPS. The files are reloaded within a second of changing, so
+// certificates can be renewed without restarting the server.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	// Requires clients to present a certificate signed by one of these CAs, if set
+	ClientCAFile string
+	// Lets clients connect without a certificate, verifying the ones they present
+	ClientCertOptional bool
+}
+
+// Serves HTTPS instead of HTTP.
+func WithTLS(config TLSConfig) Option {
+	return func(s *Server) {
+		s.tlsConfig = &config
+	}
+}
+
+// The identity of a client that presented a verified certificate.
+type ClientIdentity struct {
+	CommonName string
+	DNSNames   []string
+	// Hex encoded SHA-256 of the certificate
+	Fingerprint string
+}
+
+type clientIdentityKey struct{}
+
+// Returns the identity of the client making the request, if they presented a verified certificate.
+func ClientIdentityFromContext(ctx context.Context) (*ClientIdentity, bool) {
+	identity, ok := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	return identity, ok
+}
+
+// Returns the identity in the client's verified certificate, if they presented one.
+func verifiedClientIdentity(c *gin.Context) (*ClientIdentity, bool) {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	cert := state.VerifiedChains[0][0]
+	sum := sha256.Sum256(cert.Raw)
+	return &ClientIdentity{
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}, true
+}
+
+// A file's version, which changes whenever the file is written.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(name string) (fileVersion, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{info.ModTime(), info.Size()}, nil
+}
+
+// How long handshakes go without checking whether the files changed, so busy servers
+// don't stat them on every handshake.
+const tlsReloadInterval = time.Second
+
+// Keeps the config built from the files of a TLSConfig, reloading them on the first
+// handshake after they change, at most once every tlsReloadInterval.
+type certReloader struct {
+	config TLSConfig
+
+	// The config handshakes are served with
+	current atomic.Pointer[tls.Config]
+	// When the files were last checked, in Unix nanoseconds
+	checkedAt atomic.Int64
+
+	// Held while checking the files
+	mu       sync.Mutex
+	versions [3]fileVersion
+}
+
+func newCertReloader(config TLSConfig) (*certReloader, error) {
+	r := &certReloader{config: config}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	r.checkedAt.Store(time.Now().UnixNano())
+	return r, nil
+}
+
+// Loads the files again if any of them changed since they were last loaded.
+func (r *certReloader) reload() error {
+	files := [3]string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile}
+
+	var versions [3]fileVersion
+	for i, file := range files {
+		if file == "" {
+			continue
+		}
+		version, err := statFile(file)
+		if err != nil {
+			return err
+		}
+		versions[i] = version
+	}
+	if r.current.Load() != nil && versions == r.versions {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("unable to read client CAs: %w", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("unable to load client CAs: no certificates found")
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if r.config.ClientCertOptional {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	r.versions = versions
+	r.current.Store(config)
+	return nil
+}
+
+// Returns the config for a handshake. If the files can't be reloaded, e.g. as they are only
+// partly written, the last files loaded keep being served.
+func (r *certReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	// Only one handshake checks the files once they are due, the rest are served the
+	// current config rather than waiting for it
+	due := func() bool { return time.Since(time.Unix(0, r.checkedAt.Load())) >= tlsReloadInterval }
+	if due() && r.mu.TryLock() {
+		if due() {
+			if err := r.reload(); err != nil {
+				log.Printf("unable to reload TLS files, serving the ones last loaded: %v", err)
+			}
+			r.checkedAt.Store(time.Now().UnixNano())
+		}
+		r.mu.Unlock()
+	}
+
+	return r.current.Load(), nil
+}
+
+// Builds the server's TLS config, failing if the files can't be loaded.
+func (c *TLSConfig) build() (*tls.Config, error) {
+	reloader, err := newCertReloader(*c)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: reloader.configForClient,
+	}, nil
+}
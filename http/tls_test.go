@@ -0,0 +1,262 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	h "github.com/the-code-genin/golang_integration_testing/http"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"go.uber.org/mock/gomock"
+)
+
+// A self-signed CA issuing certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert, key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// Issues a certificate for localhost, returning it and its key PEM encoded.
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestTLS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	serverCA, clientCA := newTestCA(t), newTestCA(t)
+
+	// Writes the server's certificate, dated into the future so it always looks changed
+	dir := t.TempDir()
+	certFile, keyFile, clientCAFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	writeServerCert := func(t *testing.T, serial int64) {
+		certPEM, keyPEM := serverCA.issue(t, "localhost", serial, x509.ExtKeyUsageServerAuth)
+		modTime := time.Now().Add(time.Duration(serial) * time.Second)
+		for file, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+			require.NoError(t, os.WriteFile(file, data, 0o600))
+			require.NoError(t, os.Chtimes(file, modTime, modTime))
+		}
+	}
+	writeServerCert(t, 1)
+	require.NoError(t, os.WriteFile(clientCAFile, clientCA.pem(), 0o600))
+
+	serve := func(t *testing.T, config h.TLSConfig) string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { listener.Close() })
+
+		go h.NewServer(mockService, h.WithTLS(config)).Serve(listener)
+		return "https://" + listener.Addr().String()
+	}
+
+	// Connects afresh for every request, so each request makes a handshake
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: serverCA.pool(), Certificates: certs},
+			DisableKeepAlives: true,
+		}}
+	}
+
+	clientCert := func(t *testing.T, commonName string) tls.Certificate {
+		cert, err := tls.X509KeyPair(clientCA.issue(t, commonName, 1, x509.ExtKeyUsageClientAuth))
+		require.NoError(t, err)
+		return cert
+	}
+
+	// Handshakes check whether the files changed at most once a second
+	waitForReload := func() { time.Sleep(1100 * time.Millisecond) }
+
+	serialServed := func(t *testing.T, url string) *big.Int {
+		resp, err := newClient().Get(url + "/openapi.json")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.TLS.PeerCertificates[0].SerialNumber
+	}
+
+	t.Run("should serve HTTPS", func(t *testing.T) {
+		url := serve(t, h.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		assert.Equal(t, big.NewInt(1), serialServed(t, url))
+	})
+
+	t.Run("should serve renewed certificates without restarting", func(t *testing.T) {
+		url := serve(t, h.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		assert.Equal(t, big.NewInt(1), serialServed(t, url))
+
+		writeServerCert(t, 2)
+		waitForReload()
+		assert.Equal(t, big.NewInt(2), serialServed(t, url))
+	})
+
+	t.Run("should not check the files on every handshake", func(t *testing.T) {
+		url := serve(t, h.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		assert.Equal(t, big.NewInt(2), serialServed(t, url))
+
+		writeServerCert(t, 3)
+		assert.Equal(t, big.NewInt(2), serialServed(t, url))
+
+		waitForReload()
+		assert.Equal(t, big.NewInt(3), serialServed(t, url))
+	})
+
+	t.Run("should keep serving the last certificate if the new one is invalid", func(t *testing.T) {
+		url := serve(t, h.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		assert.Equal(t, big.NewInt(3), serialServed(t, url))
+
+		modTime := time.Now().Add(time.Minute)
+		require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+		t.Cleanup(func() { writeServerCert(t, 4) })
+
+		waitForReload()
+		assert.Equal(t, big.NewInt(3), serialServed(t, url))
+	})
+
+	t.Run("should fail to serve if the certificate can't be loaded", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		err = h.NewServer(mockService, h.WithTLS(h.TLSConfig{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile})).Serve(listener)
+		assert.Error(t, err)
+	})
+
+	t.Run("Mutual TLS", func(t *testing.T) {
+		url := serve(t, h.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile})
+
+		t.Run("should reject clients without a certificate", func(t *testing.T) {
+			_, err := newClient().Get(url + "/openapi.json")
+			assert.Error(t, err)
+		})
+
+		t.Run("should reject certificates from other CAs", func(t *testing.T) {
+			cert, err := tls.X509KeyPair(serverCA.issue(t, "mallory", 1, x509.ExtKeyUsageClientAuth))
+			require.NoError(t, err)
+
+			_, err = newClient(cert).Get(url + "/openapi.json")
+			assert.Error(t, err)
+		})
+
+		t.Run("should identify clients by their certificate", func(t *testing.T) {
+			mockService.EXPECT().
+				CreateNote(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, dto repository.CreateNoteDTO) (*repository.Note, error) {
+					identity, ok := h.ClientIdentityFromContext(ctx)
+					if assert.True(t, ok) {
+						assert.Equal(t, "alice", identity.CommonName)
+						assert.Len(t, identity.Fingerprint, 64)
+					}
+					assert.Equal(t, "cert:alice", service.OwnerFromContext(ctx))
+					return &repository.Note{ID: uuid.New(), Title: dto.Title, Description: dto.Description}, nil
+				})
+
+			resp, err := newClient(clientCert(t, "alice")).Post(
+				url+"/v1/notes", "application/json",
+				jsonBody(t, map[string]any{"title": gofakeit.Sentence(3), "description": gofakeit.Sentence(10)}),
+			)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		})
+	})
+
+	t.Run("should let clients without a certificate connect if they are optional", func(t *testing.T) {
+		url := serve(t, h.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile, ClientCertOptional: true})
+
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, dto repository.CreateNoteDTO) (*repository.Note, error) {
+				_, ok := h.ClientIdentityFromContext(ctx)
+				assert.False(t, ok)
+				assert.Equal(t, "ip:127.0.0.1", service.OwnerFromContext(ctx))
+				return &repository.Note{ID: uuid.New(), Title: dto.Title, Description: dto.Description}, nil
+			})
+
+		resp, err := newClient().Post(
+			url+"/v1/notes", "application/json",
+			jsonBody(t, map[string]any{"title": gofakeit.Sentence(3), "description": gofakeit.Sentence(10)}),
+		)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+}
+
+func jsonBody(t *testing.T, value any) *bytes.Reader {
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	return bytes.NewReader(data)
+}
@@ -0,0 +1,443 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// A single violation of the OpenAPI document by a request or response.
+type fieldError struct {
+	Field    string `json:"field" binding:"required"`
+	Location string `json:"location" binding:"required"`
+	Message  string `json:"message" binding:"required"`
+}
+
+var patterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// Resolves a $ref to one of the document's component schemas.
+func (d *openAPIDocument) resolve(schema *openAPISchema) *openAPISchema {
+	if schema == nil || schema.Ref == "" {
+		return schema
+	}
+	return d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+}
+
+// Validates a decoded JSON value against a schema.
+// The path of each invalid value is reported in the errors returned.
+func (d *openAPIDocument) validate(value any, schema *openAPISchema, path, location string) []fieldError {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	fail := func(format string, args ...any) []fieldError {
+		return []fieldError{{Field: path, Location: location, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if len(schema.Type) > 0 && !slices.Contains([]string(schema.Type), jsonType(value)) {
+		// Every integer is also a number
+		if !(jsonType(value) == "integer" && slices.Contains([]string(schema.Type), "number")) {
+			return fail("must be of type %s", strings.Join(schema.Type, " or "))
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(value)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return fail("must be at least %d characters long", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return fail("must be at most %d characters long", *schema.MaxLength)
+		}
+		if schema.Pattern != "" {
+			re, err := compilePattern(schema.Pattern)
+			if err != nil {
+				log.Printf("invalid pattern %q in openapi document: %v", schema.Pattern, err)
+			} else if !re.MatchString(value) {
+				return fail("must match the pattern %s", schema.Pattern)
+			}
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+			return fail("must be one of %s", strings.Join(schema.Enum, ", "))
+		}
+		switch schema.Format {
+		case "uuid":
+			if _, err := uuid.Parse(value); err != nil {
+				return fail("must be a UUID")
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+				return fail("must be an RFC 3339 date-time")
+			}
+		}
+	case json.Number:
+		if schema.Minimum != nil {
+			if n, err := value.Float64(); err == nil && n < *schema.Minimum {
+				return fail("must be at least %v", *schema.Minimum)
+			}
+		}
+	case []any:
+		var errs []fieldError
+		for i, item := range value {
+			errs = append(errs, d.validate(item, schema.Items, fmt.Sprintf("%s[%d]", path, i), location)...)
+		}
+		return errs
+	case map[string]any:
+		var errs []fieldError
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, fieldError{Field: joinPath(path, name), Location: location, Message: "is required"})
+			}
+		}
+
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				errs = append(errs, d.validate(value[name], property, joinPath(path, name), location)...)
+				continue
+			}
+
+			switch additional := schema.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fieldError{Field: joinPath(path, name), Location: location, Message: "is not allowed"})
+				}
+			case *openAPISchema:
+				errs = append(errs, d.validate(value[name], additional, joinPath(path, name), location)...)
+			}
+		}
+		return errs
+	}
+
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return ""
+	}
+}
+
+// Converts a raw path or query parameter to the JSON value its schema describes.
+func parameterValue(raw string, schema *openAPISchema) any {
+	if schema != nil && (slices.Contains([]string(schema.Type), "integer") || slices.Contains([]string(schema.Type), "number")) {
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	}
+	if schema != nil && slices.Contains([]string(schema.Type), "boolean") {
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+func decodeJSON(body []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// Returns the operation the request was routed to, if it is documented.
+func (s *Server) operation(c *gin.Context) *openAPIOperation {
+	route := c.FullPath()
+	if route == "" {
+		return nil
+	}
+
+	// Convert gin's :param segments to OpenAPI's {param} segments
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return s.openAPI.Paths[strings.Join(segments, "/")][strings.ToLower(c.Request.Method)]
+}
+
+// Rejects requests that don't match the OpenAPI document before they reach the handlers.
+func (s *Server) validateRequest(c *gin.Context) {
+	operation := s.operation(c)
+	if operation == nil {
+		c.Next()
+		return
+	}
+
+	var errs []fieldError
+	for _, parameter := range operation.Parameters {
+		var (
+			raw     string
+			present bool
+		)
+		switch parameter.In {
+		case "path":
+			raw = c.Param(parameter.Name)
+			present = raw != ""
+		case "query":
+			raw, present = c.GetQuery(parameter.Name)
+		default:
+			// Headers are left to the handlers
+			continue
+		}
+
+		if !present {
+			if parameter.Required {
+				errs = append(errs, fieldError{Field: parameter.Name, Location: parameter.In, Message: "is required"})
+			}
+			continue
+		}
+		errs = append(errs, s.openAPI.validate(parameterValue(raw, parameter.Schema), parameter.Schema, parameter.Name, parameter.In)...)
+	}
+
+	if operation.RequestBody != nil {
+		bodyErrs, ok := s.validateRequestBody(c, operation.RequestBody)
+		if !ok {
+			return
+		}
+		errs = append(errs, bodyErrs...)
+	}
+
+	if len(errs) > 0 {
+		s.sendValidationErrors(c, errs)
+		return
+	}
+
+	c.Next()
+}
+
+// Validates the request body, restoring it for the handler.
+// Returns false if a response has already been sent.
+func (s *Server) validateRequestBody(c *gin.Context, requestBody *openAPIRequestBody) ([]fieldError, bool) {
+	// Only JSON bodies are validated, so uploads of the other types documented are left for
+	// the handler to read as it goes rather than held in memory
+	media, ok := requestBody.Content["application/json"]
+	if _, documented := requestBody.Content[c.ContentType()]; !ok || (documented && c.ContentType() != "application/json") {
+		if requestBody.Required && c.Request.ContentLength == 0 {
+			return []fieldError{{Field: "", Location: "body", Message: "is required"}}, true
+		}
+		return nil, true
+	}
+
+	// The body is limited by limitBodySize
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			s.sendContentTooLarge(c)
+			return nil, false
+		}
+
+		log.Printf("unable to read request body: %v", err)
+		s.sendBadRequest(c, "bad request")
+		c.Abort()
+		return nil, false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if requestBody.Required {
+			return []fieldError{{Field: "", Location: "body", Message: "is required"}}, true
+		}
+		return nil, true
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return []fieldError{{Field: "", Location: "body", Message: "must be valid JSON"}}, true
+	}
+
+	return s.openAPI.validate(value, media.Schema, "", "body"), true
+}
+
+func (s *Server) sendValidationErrors(c *gin.Context, errs []fieldError) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		"message": "bad request",
+		"errors":  errs,
+	})
+}
+
+// Buffers a response so it can be validated before it is sent.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// Holds on to the body until it is validated.
+func (w *bufferedResponseWriter) Flush() {}
+
+var jsonMediaTypes = []string{"application/json", "application/problem+json"}
+
+var streamMediaTypes = []string{"text/event-stream"}
+
+// Streams and protocol upgrades are passed through, other responses are buffered.
+func validatesResponses(operation *openAPIOperation) bool {
+	for status, response := range operation.Responses {
+		if status == "101" {
+			return false
+		}
+		for contentType := range response.Content {
+			if slices.Contains(streamMediaTypes, contentType) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Replaces responses that don't match the OpenAPI document with a 500 error,
+// so a handler drifting from the document fails loudly in tests.
+func (s *Server) validateResponse(c *gin.Context) {
+	operation := s.operation(c)
+	if operation == nil || !validatesResponses(operation) {
+		c.Next()
+		return
+	}
+
+	original := c.Writer
+	writer := &bufferedResponseWriter{ResponseWriter: original, status: http.StatusOK}
+	c.Writer = writer
+	c.Next()
+	c.Writer = original
+
+	errs := s.responseErrors(operation, writer)
+	if len(errs) > 0 {
+		log.Printf("response to %s %s does not match the openapi document: %+v", c.Request.Method, c.FullPath(), errs)
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusInternalServerError)
+		body, _ := json.Marshal(gin.H{"message": "response does not match the OpenAPI document", "errors": errs})
+		_, _ = original.Write(body)
+		return
+	}
+
+	original.WriteHeader(writer.status)
+	if writer.body.Len() > 0 {
+		_, _ = original.Write(writer.body.Bytes())
+	} else {
+		original.WriteHeaderNow()
+	}
+}
+
+func (s *Server) responseErrors(operation *openAPIOperation, writer *bufferedResponseWriter) []fieldError {
+	response, ok := operation.Responses[strconv.Itoa(writer.status)]
+	if !ok {
+		return []fieldError{{Location: "response", Message: fmt.Sprintf("status %d is not documented", writer.status)}}
+	}
+
+	if len(response.Content) == 0 {
+		if writer.body.Len() > 0 {
+			return []fieldError{{Location: "response", Message: "body is not documented"}}
+		}
+		return nil
+	}
+
+	contentType, _, _ := mime.ParseMediaType(writer.Header().Get("Content-Type"))
+	media, ok := response.Content[contentType]
+	if !ok {
+		return []fieldError{{Location: "response", Message: fmt.Sprintf("content type %q is not documented", contentType)}}
+	}
+	// Only JSON bodies are checked against their schema
+	if !slices.Contains(jsonMediaTypes, contentType) {
+		return nil
+	}
+
+	value, err := decodeJSON(writer.body.Bytes())
+	if err != nil {
+		return []fieldError{{Location: "response", Message: "body must be valid JSON"}}
+	}
+	return s.openAPI.validate(value, media.Schema, "", "response")
+}
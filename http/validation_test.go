@@ -0,0 +1,69 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"go.uber.org/mock/gomock"
+)
+
+// A request body that fails the test if it is read.
+type unreadBody struct{ t *testing.T }
+
+func (b unreadBody) Read([]byte) (int, error) {
+	b.t.Error("the request body was read")
+	return 0, errors.New("unexpected read")
+}
+
+func (b unreadBody) Close() error { return nil }
+
+func TestValidateRequestBody(t *testing.T) {
+	s := NewServer(service.NewMockService(gomock.NewController(t)))
+	importBody := s.openAPI.Paths["/v1/notes/import"]["post"].RequestBody
+	createBody := s.openAPI.Paths["/v1/notes"]["post"].RequestBody
+
+	newContext := func(body *http.Request) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = body
+		return c
+	}
+
+	t.Run("should leave uploads for the handler to read", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/notes/import", nil)
+		req.Body, req.ContentLength = unreadBody{t}, -1
+		req.Header.Set("Content-Type", mediaTypeCSV)
+
+		errs, ok := s.validateRequestBody(newContext(req), importBody)
+		assert.True(t, ok)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("should require uploads to have a body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/notes/import", http.NoBody)
+		req.Header.Set("Content-Type", mediaTypeCSV)
+
+		errs, ok := s.validateRequestBody(newContext(req), importBody)
+		assert.True(t, ok)
+		assert.Equal(t, []fieldError{{Field: "", Location: "body", Message: "is required"}}, errs)
+	})
+
+	t.Run("should validate JSON bodies, restoring them for the handler", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/notes", strings.NewReader(`{"title": "Groceries"}`))
+		req.Header.Set("Content-Type", "application/json")
+		c := newContext(req)
+
+		errs, ok := s.validateRequestBody(c, createBody)
+		assert.True(t, ok)
+		assert.NotEmpty(t, errs)
+
+		var body map[string]any
+		assert.NoError(t, c.ShouldBindJSON(&body))
+		assert.Equal(t, "Groceries", body["title"])
+	})
+}
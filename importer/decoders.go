@@ -0,0 +1,207 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// Reads a note per line, ignoring the fields a note can't be created with, such as
+// its ID, so exports can be imported as they are. Blank lines are skipped.
+func decodeNDJSON(r io.Reader, fn func(record) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec record
+			if jsonErr := json.Unmarshal(line, &rec.dto); jsonErr != nil {
+				rec.err = fmt.Errorf("invalid JSON: %w", jsonErr)
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// Reads a note per row, taking its fields from the columns the header row names.
+// Columns other than title, description and format are ignored. The quote exports
+// prefix cells spreadsheets would run as formulas with is dropped.
+func decodeCSV(r io.Reader, fn func(record) error) error {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid header row: %w", err)
+	}
+
+	columns := map[repository.NoteField]int{}
+	for i, name := range header {
+		columns[repository.NoteField(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns[repository.NoteFieldTitle]; !ok {
+		return errors.New("the header row has no title column")
+	}
+	column := func(row []string, field repository.NoteField) string {
+		if i, ok := columns[field]; ok && i < len(row) {
+			return unescapeCell(row[i])
+		}
+		return ""
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var rec record
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			rec.err = fmt.Errorf("invalid row: %w", err)
+		case err != nil:
+			return err
+		default:
+			rec.dto = repository.CreateNoteDTO{
+				Title:       column(row, repository.NoteFieldTitle),
+				Description: column(row, repository.NoteFieldDescription),
+				Format:      repository.NoteFormat(column(row, repository.NoteFieldFormat)),
+			}
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// Drops the quote a cell starting with =, +, -, @, a tab or a carriage return was
+// prefixed with when exported.
+func unescapeCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// The largest Markdown file read from a ZIP archive, so small archives can't
+// decompress to more than the notes they could hold.
+const maxMarkdownFileSize = 1 << 20
+
+// Reads a note per Markdown file, in the order they were archived. Other files are skipped.
+// ZIP archives are read into memory, as their directory is at their end.
+func decodeZIP(r io.Reader, fn func(record) error) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid ZIP archive: %w", err)
+	}
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(path.Ext(file.Name), ".md") {
+			continue
+		}
+
+		rec := record{file: file.Name}
+		document, err := readZIPFile(file)
+		if err == nil {
+			rec.dto, err = parseMarkdownFile(file.Name, document)
+		}
+		rec.err = err
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZIPFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %w", err)
+	}
+	defer reader.Close()
+
+	document, err := io.ReadAll(io.LimitReader(reader, maxMarkdownFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+	if len(document) > maxMarkdownFileSize {
+		return nil, fmt.Errorf("file must not exceed %d bytes", maxMarkdownFileSize)
+	}
+	return document, nil
+}
+
+// The fields of a note given as the front matter of its Markdown file.
+type frontMatter struct {
+	Title  string                `yaml:"title"`
+	Format repository.NoteFormat `yaml:"format"`
+}
+
+// Parses a Markdown file as exported, its description following its front matter, e.g.
+//
+//	---
+//	title: Groceries
+//	---
+//
+//	Milk and eggs
+//
+// Files without a title in their front matter are titled after their name.
+// Descriptions are taken to be Markdown unless the front matter says otherwise.
+func parseMarkdownFile(name string, document []byte) (repository.CreateNoteDTO, error) {
+	content := strings.ReplaceAll(string(document), "\r\n", "\n")
+	dto := repository.CreateNoteDTO{
+		Title:       strings.TrimSuffix(path.Base(name), path.Ext(name)),
+		Description: strings.TrimRight(content, "\n"),
+		Format:      repository.NoteFormatMarkdown,
+	}
+
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		return dto, nil
+	}
+	// The front matter may be empty, closing right after it opens
+	header, body, ok := strings.Cut("\n"+rest, "\n---\n")
+	if !ok {
+		if header, ok = strings.CutSuffix("\n"+rest, "\n---"); !ok {
+			return dto, errors.New("the front matter is never closed")
+		}
+	}
+
+	var fields frontMatter
+	if err := yaml.Unmarshal([]byte(header), &fields); err != nil {
+		return dto, fmt.Errorf("invalid front matter: %w", err)
+	}
+	if fields.Title != "" {
+		dto.Title = fields.Title
+	}
+	if fields.Format != "" {
+		dto.Format = fields.Format
+	}
+	dto.Description = strings.TrimRight(strings.TrimPrefix(body, "\n"), "\n")
+	return dto, nil
+}
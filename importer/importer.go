@@ -0,0 +1,122 @@
+// Package importer creates notes from the files they are exported as: NDJSON, CSV,
+// or ZIP archives of Markdown files with front matter.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+)
+
+type Format string
+
+const (
+	// A JSON object per line, as exported with ?format=ndjson
+	FormatNDJSON Format = "ndjson"
+	// A header row naming the columns, then a row per note
+	FormatCSV Format = "csv"
+	// A Markdown file per note, its other fields given as YAML front matter
+	FormatZIP Format = "zip"
+)
+
+var Formats = []Format{FormatNDJSON, FormatCSV, FormatZIP}
+
+// A note read from an import, or the reason it couldn't be read.
+type record struct {
+	file string
+	dto  repository.CreateNoteDTO
+	err  error
+}
+
+var decoders = map[Format]func(r io.Reader, fn func(record) error) error{
+	FormatNDJSON: decodeNDJSON,
+	FormatCSV:    decodeCSV,
+	FormatZIP:    decodeZIP,
+}
+
+type Status string
+
+const (
+	StatusImported Status = "imported"
+	StatusFailed   Status = "failed"
+)
+
+// The outcome of importing a note.
+type Result struct {
+	// The position of the note in the import, starting at 1
+	Row int `json:"row" binding:"required"`
+	// The file the note was read from, for ZIP archives
+	File   string `json:"file,omitempty"`
+	Status Status `json:"status" binding:"required"`
+	// The note created, or updated if upserted, and the title it was given
+	NoteID *uuid.UUID `json:"note_id,omitempty"`
+	Title  string     `json:"title,omitempty"`
+	// Why the note wasn't imported
+	Error      string              `json:"error,omitempty"`
+	Violations []service.Violation `json:"violations,omitempty"`
+	// The note holding the title, if the note wasn't imported because it was taken
+	ConflictingNoteID *uuid.UUID `json:"conflicting_note_id,omitempty"`
+}
+
+// Creates a note for every record read from r, resolving titles taken by other notes
+// with onConflict, and passes the result of each to fn as it is imported. Notes are
+// validated and created one at a time, so notes that can't be imported don't stop the rest.
+//
+// Returns an error if the import as a whole can't be read, e.g. a CSV file without a
+// title column, after passing fn the results of the notes imported until then.
+func Import(
+	ctx context.Context, svc service.Service, format Format, r io.Reader,
+	onConflict repository.ConflictStrategy, fn func(Result),
+) error {
+	decode, ok := decoders[format]
+	if !ok {
+		return fmt.Errorf("unknown import format %q", format)
+	}
+	if onConflict != "" && !slices.Contains(repository.ConflictStrategies, onConflict) {
+		return fmt.Errorf("unknown conflict strategy %q", onConflict)
+	}
+
+	row := 0
+	return decode(r, func(rec record) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		row++
+		result := Result{Row: row, File: rec.file}
+		if rec.err != nil {
+			result.Status, result.Error = StatusFailed, rec.err.Error()
+			fn(result)
+			return nil
+		}
+
+		rec.dto.OnConflict = onConflict
+		note, err := svc.CreateNote(ctx, rec.dto)
+		if err != nil {
+			result.Status, result.Error = StatusFailed, err.Error()
+
+			var (
+				validationErr *service.ValidationError
+				titleTaken    *service.TitleTakenError
+			)
+			if errors.As(err, &validationErr) {
+				result.Violations = validationErr.Violations
+			}
+			if errors.As(err, &titleTaken) {
+				result.ConflictingNoteID = &titleTaken.NoteID
+			}
+			fn(result)
+			return nil
+		}
+
+		result.Status, result.NoteID, result.Title = StatusImported, &note.ID, note.Title
+		fn(result)
+		return nil
+	})
+}
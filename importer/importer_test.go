@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"go.uber.org/mock/gomock"
+)
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+
+	// Setup mocked service
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	// Expects a note to be created from the DTO, returning it with a new ID
+	expectCreate := func(dto repository.CreateNoteDTO) uuid.UUID {
+		id := uuid.New()
+		mockService.EXPECT().CreateNote(gomock.Any(), dto).
+			Return(&repository.Note{ID: id, Title: dto.Title, Description: dto.Description, Format: dto.Format}, nil)
+		return id
+	}
+
+	importNotes := func(t *testing.T, format Format, data []byte, onConflict repository.ConflictStrategy) ([]Result, error) {
+		var results []Result
+		err := Import(ctx, mockService, format, bytes.NewReader(data), onConflict, func(result Result) {
+			results = append(results, result)
+		})
+		return results, err
+	}
+
+	t.Run("should import a note per line of NDJSON, ignoring blank lines and exported fields", func(t *testing.T) {
+		first := expectCreate(repository.CreateNoteDTO{Title: "Groceries", Description: "Milk", OnConflict: repository.ConflictRename})
+		second := expectCreate(repository.CreateNoteDTO{
+			Title: "Chores", Description: "- [ ] Dishes", Format: repository.NoteFormatMarkdown, OnConflict: repository.ConflictRename,
+		})
+
+		results, err := importNotes(t, FormatNDJSON, []byte(
+			`{"id":"`+uuid.NewString()+`","title":"Groceries","description":"Milk","created_at":"2024-01-02T03:04:05Z"}`+"\n\n"+
+				`{"title":"Chores","description":"- [ ] Dishes","format":"markdown"}`,
+		), repository.ConflictRename)
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{
+			{Row: 1, Status: StatusImported, NoteID: &first, Title: "Groceries"},
+			{Row: 2, Status: StatusImported, NoteID: &second, Title: "Chores"},
+		}, results)
+	})
+
+	t.Run("should report invalid lines and go on with the rest", func(t *testing.T) {
+		id := expectCreate(repository.CreateNoteDTO{Title: "Groceries", Description: "Milk"})
+
+		results, err := importNotes(t, FormatNDJSON, []byte("{\"title\":\n"+`{"title":"Groceries","description":"Milk"}`+"\n"), "")
+		assert.NoError(t, err)
+		if assert.Len(t, results, 2) {
+			assert.Equal(t, StatusFailed, results[0].Status)
+			assert.Contains(t, results[0].Error, "invalid JSON")
+			assert.Equal(t, Result{Row: 2, Status: StatusImported, NoteID: &id, Title: "Groceries"}, results[1])
+		}
+	})
+
+	t.Run("should report the violations and conflicts of notes the service rejects", func(t *testing.T) {
+		conflictingID := uuid.New()
+		violations := []service.Violation{{Field: "title", Message: "must not be empty"}}
+		mockService.EXPECT().CreateNote(gomock.Any(), repository.CreateNoteDTO{Description: "Milk"}).
+			Return(nil, &service.ValidationError{Violations: violations})
+		mockService.EXPECT().CreateNote(gomock.Any(), repository.CreateNoteDTO{Title: "Groceries", Description: "Eggs"}).
+			Return(nil, &service.TitleTakenError{NoteID: conflictingID})
+
+		results, err := importNotes(t, FormatCSV, []byte("title,description\n,Milk\nGroceries,Eggs\n"), "")
+		assert.NoError(t, err)
+		if assert.Len(t, results, 2) {
+			assert.Equal(t, StatusFailed, results[0].Status)
+			assert.Equal(t, violations, results[0].Violations)
+			assert.Equal(t, StatusFailed, results[1].Status)
+			assert.Equal(t, &conflictingID, results[1].ConflictingNoteID)
+		}
+	})
+
+	t.Run("should import a note per CSV row, taking the fields from the columns named", func(t *testing.T) {
+		id := expectCreate(repository.CreateNoteDTO{
+			Title: "Groceries", Description: "Milk,\nand eggs", Format: repository.NoteFormatPlain,
+		})
+
+		results, err := importNotes(t, FormatCSV, []byte(
+			"id,format,description,title\n"+uuid.NewString()+",plain,\"Milk,\nand eggs\",Groceries\n",
+		), "")
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{{Row: 1, Status: StatusImported, NoteID: &id, Title: "Groceries"}}, results)
+	})
+
+	t.Run("should drop the quote exports prefix CSV cells spreadsheets would run as formulas with", func(t *testing.T) {
+		id := expectCreate(repository.CreateNoteDTO{Title: "=1+1", Description: "- [ ] Dishes"})
+		second := expectCreate(repository.CreateNoteDTO{Title: "'Quoted'", Description: "'"})
+
+		results, err := importNotes(t, FormatCSV, []byte("title,description\n'=1+1,\"'- [ ] Dishes\"\n'Quoted','\n"), "")
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{
+			{Row: 1, Status: StatusImported, NoteID: &id, Title: "=1+1"},
+			{Row: 2, Status: StatusImported, NoteID: &second, Title: "'Quoted'"},
+		}, results)
+	})
+
+	t.Run("should fail CSV files without a title column", func(t *testing.T) {
+		results, err := importNotes(t, FormatCSV, []byte("description\nMilk\n"), "")
+		assert.EqualError(t, err, "the header row has no title column")
+		assert.Empty(t, results)
+	})
+
+	t.Run("should import a note per Markdown file of a ZIP archive", func(t *testing.T) {
+		var archive bytes.Buffer
+		writer := zip.NewWriter(&archive)
+		for _, file := range []struct{ name, content string }{
+			{"groceries-0b6f2a4c.md", "---\nid: 0b6f2a4c-0000-0000-0000-000000000000\ntitle: 'Groceries: weekly'\nformat: plain\n---\n\nMilk\n"},
+			{"notes/", ""},
+			{"notes/Chores.md", "- [ ] Dishes\r\n"},
+			{"README.txt", "Not a note"},
+		} {
+			w, err := writer.Create(file.name)
+			assert.NoError(t, err)
+			_, err = w.Write([]byte(file.content))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, writer.Close())
+
+		first := expectCreate(repository.CreateNoteDTO{
+			Title: "Groceries: weekly", Description: "Milk", Format: repository.NoteFormatPlain,
+		})
+		second := expectCreate(repository.CreateNoteDTO{
+			Title: "Chores", Description: "- [ ] Dishes", Format: repository.NoteFormatMarkdown,
+		})
+
+		results, err := importNotes(t, FormatZIP, archive.Bytes(), "")
+		assert.NoError(t, err)
+		assert.Equal(t, []Result{
+			{Row: 1, File: "groceries-0b6f2a4c.md", Status: StatusImported, NoteID: &first, Title: "Groceries: weekly"},
+			{Row: 2, File: "notes/Chores.md", Status: StatusImported, NoteID: &second, Title: "Chores"},
+		}, results)
+	})
+
+	t.Run("should fail archives that aren't ZIP archives", func(t *testing.T) {
+		_, err := importNotes(t, FormatZIP, []byte("not a zip"), "")
+		assert.ErrorContains(t, err, "invalid ZIP archive")
+	})
+
+	t.Run("should reject unknown formats and conflict strategies", func(t *testing.T) {
+		_, err := importNotes(t, "xml", nil, "")
+		assert.EqualError(t, err, `unknown import format "xml"`)
+
+		_, err = importNotes(t, FormatNDJSON, nil, "skip")
+		assert.EqualError(t, err, `unknown conflict strategy "skip"`)
+	})
+
+	t.Run("should stop once the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := Import(ctx, mockService, FormatCSV, strings.NewReader("title\nGroceries\n"), "", func(Result) {})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestParseMarkdownFile(t *testing.T) {
+	t.Run("should parse an empty front matter", func(t *testing.T) {
+		dto, err := parseMarkdownFile("notes/Groceries.md", []byte("---\n---\nMilk"))
+		assert.NoError(t, err)
+		assert.Equal(t, repository.CreateNoteDTO{
+			Title: "Groceries", Description: "Milk", Format: repository.NoteFormatMarkdown,
+		}, dto)
+	})
+
+	t.Run("should fail front matter that is never closed", func(t *testing.T) {
+		_, err := parseMarkdownFile("Groceries.md", []byte("---\ntitle: Groceries\nMilk"))
+		assert.EqualError(t, err, "the front matter is never closed")
+	})
+}
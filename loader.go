@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Loads the config, each source overriding the ones before it: the defaults, the config file,
+// environment variables, then command line flags. The config file is named by the --config flag
+// or CONFIG_FILE, and a NAME_FILE variable can name a file to read the NAME setting from, so
+// secrets needn't be kept in the environment.
+//
+// Returns the arguments left after the flags, and every setting that couldn't be loaded or is invalid.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (Config, []string, error) {
+	var cfg Config
+	fields := configFields(&cfg)
+
+	flags := flag.NewFlagSet("golang_integration_testing", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", "", "the YAML or TOML file to load settings from")
+	for _, field := range fields {
+		flags.String(field.flag(), "", "sets "+field.name)
+	}
+	if err := flags.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	sources := configSources{flags: map[string]string{}, lookupEnv: lookupEnv, file: map[string]string{}}
+	flags.Visit(func(f *flag.Flag) { sources.flags[f.Name] = f.Value.String() })
+
+	var errs []error
+
+	sources.fileName = *configFile
+	if _, ok := sources.flags["config"]; !ok {
+		sources.fileName, _ = lookupEnv("CONFIG_FILE")
+	}
+	if sources.fileName != "" {
+		file, err := readConfigFile(sources.fileName)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		sources.file = file
+	}
+
+	for _, field := range fields {
+		value, source, err := sources.lookup(field)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Settings left empty keep their zero value
+		if value == "" {
+			continue
+		}
+		if err := decode(field.value, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (from %s): %w", field.name, source, err))
+			// Fall back to the default, so the setting isn't reported as invalid again below
+			field.value.SetZero()
+			if field.fallback != "" {
+				decode(field.value, field.fallback)
+			}
+		}
+	}
+
+	// Typos in the file would otherwise go unnoticed
+	var unknown []string
+	for key := range sources.file {
+		if !slices.ContainsFunc(fields, func(field configField) bool { return field.key() == key }) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown setting %q", sources.fileName, key))
+	}
+
+	errs = append(errs, cfg.validate())
+	return cfg, flags.Args(), errors.Join(errs...)
+}
+
+// A setting of the Config.
+type configField struct {
+	name     string
+	fallback string
+	value    reflect.Value
+}
+
+func configFields(cfg *Config) []configField {
+	value, typ := reflect.ValueOf(cfg).Elem(), reflect.TypeOf(*cfg)
+
+	fields := make([]configField, typ.NumField())
+	for i := range typ.NumField() {
+		fields[i] = configField{typ.Field(i).Tag.Get("env"), typ.Field(i).Tag.Get("default"), value.Field(i)}
+	}
+	return fields
+}
+
+// The flag setting the field, e.g. db-max-conns for DB_MAX_CONNS.
+func (f configField) flag() string {
+	return strings.ToLower(strings.ReplaceAll(f.name, "_", "-"))
+}
+
+// The key setting the field in config files, e.g. db_max_conns for DB_MAX_CONNS.
+func (f configField) key() string {
+	return strings.ToLower(f.name)
+}
+
+// The values set by each source of settings.
+type configSources struct {
+	// Keyed by flag name, holding the flags set
+	flags     map[string]string
+	lookupEnv func(string) (string, bool)
+	fileName  string
+	file      map[string]string
+}
+
+// Returns the field's value from the source with the highest precedence setting it, and names the source.
+func (s configSources) lookup(field configField) (value, source string, err error) {
+	if value, ok := s.flags[field.flag()]; ok {
+		return value, "--" + field.flag(), nil
+	}
+
+	value, inEnv := s.lookupEnv(field.name)
+	secretFile, inSecretFile := s.lookupEnv(field.name + "_FILE")
+	switch {
+	case inEnv && inSecretFile:
+		return "", "", fmt.Errorf("%s: set either %s or %s_FILE, not both", field.name, field.name, field.name)
+	case inEnv:
+		return value, "$" + field.name, nil
+	case inSecretFile:
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			return "", "", fmt.Errorf("%s_FILE: %w", field.name, err)
+		}
+		// Editors and echo end files with a newline that isn't part of the secret
+		return strings.TrimRight(string(data), "\r\n"), secretFile, nil
+	}
+
+	if value, ok := s.file[field.key()]; ok {
+		return value, s.fileName, nil
+	}
+	return field.fallback, "default", nil
+}
+
+// Reads the settings of a YAML or TOML file, keyed by the lowercased names of their
+// environment variables, e.g. db_max_conns. Lists are joined the way they're written in the environment.
+func readConfigFile(name string) (map[string]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	var settings map[string]any
+	switch ext := filepath.Ext(name); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &settings)
+	case ".toml":
+		err = toml.Unmarshal(data, &settings)
+	default:
+		return nil, fmt.Errorf("unable to read config file: unknown format %q, must be .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file: %w", err)
+	}
+
+	values := make(map[string]string, len(settings))
+	var errs []error
+	for key, setting := range settings {
+		value, err := settingString(setting)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", name, key, err))
+			continue
+		}
+		values[key] = value
+	}
+	return values, errors.Join(errs...)
+}
+
+func settingString(setting any) (string, error) {
+	switch setting := setting.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		items := make([]string, len(setting))
+		for i, item := range setting {
+			value, err := settingString(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = value
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		// Tables are written as key=value lists, e.g. rate_limits
+		pairs := make([]string, 0, len(setting))
+		for key, item := range setting {
+			value, err := settingString(item)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, key+"="+value)
+		}
+		slices.Sort(pairs)
+		return strings.Join(pairs, ","), nil
+	case time.Time:
+		return setting.Format(time.RFC3339), nil
+	default:
+		return fmt.Sprint(setting), nil
+	}
+}
+
+// Sets the field to the value, decoded according to the field's type.
+func decode(field reflect.Value, value string) error {
+	switch decoder := field.Addr().Interface().(type) {
+	case interface{ Decode(string) error }:
+		return decoder.Decode(value)
+	case encoding.TextUnmarshaler:
+		return decoder.UnmarshalText([]byte(value))
+	}
+
+	if field.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		var items []string
+		for item := range strings.SplitSeq(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// Returns the config with the settings tagged reload taken from next, and the names of
+// the other settings that differ in next, which only take effect on restart.
+func (cfg Config) reload(next Config) (Config, []string) {
+	current, updated := reflect.ValueOf(&cfg).Elem(), reflect.ValueOf(next)
+
+	var restartRequired []string
+	for i := range current.NumField() {
+		field := current.Type().Field(i)
+		if reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			continue
+		}
+		if field.Tag.Get("reload") == "true" {
+			current.Field(i).Set(updated.Field(i))
+			continue
+		}
+		restartRequired = append(restartRequired, field.Tag.Get("env"))
+	}
+	return cfg, restartRequired
+}
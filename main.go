@@ -2,54 +2,331 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/kelseyhightower/envconfig"
+	"github.com/redis/go-redis/v9"
+	"github.com/the-code-genin/golang_integration_testing/grpc"
 	"github.com/the-code-genin/golang_integration_testing/http"
+	"github.com/the-code-genin/golang_integration_testing/importer"
+	"github.com/the-code-genin/golang_integration_testing/ratelimit"
 	"github.com/the-code-genin/golang_integration_testing/repository"
 	"github.com/the-code-genin/golang_integration_testing/service"
 )
 
-// Config holds environment variables
-type Config struct {
-	PostgresUser     string `envconfig:"POSTGRES_USER" default:"postgres"`
-	PostgresPassword string `envconfig:"POSTGRES_PASSWORD" default:"password"`
-	PostgresHost     string `envconfig:"POSTGRES_HOST" default:"localhost"`
-	PostgresPort     string `envconfig:"POSTGRES_PORT" default:"5432"`
-	PostgresDB       string `envconfig:"POSTGRES_DB" default:"postgres"`
-	ServerPort       int    `envconfig:"SERVER_PORT" default:"8080"`
-}
-
 func main() {
-	var cfg Config
-	if err := envconfig.Process("", &cfg); err != nil {
-		log.Fatalf("failed to load environment variables: %v", err)
+	cfg, args, err := loadConfig(os.Args[1:], os.LookupEnv)
+
+	// Report every problem with the config, instead of serving
+	if len(args) > 1 && args[0] == "config" && args[1] == "validate" {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("config is valid")
+		return
+	}
+	if err != nil {
+		fatalf("invalid config:\n%v", err)
 	}
 
-	// Build Postgres connection string
-	connStr := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresHost, cfg.PostgresPort, cfg.PostgresDB,
-	)
+	// Print the config with its secrets redacted, instead of serving
+	if len(args) > 0 && args[0] == "config" {
+		if err := cfg.write(os.Stdout); err != nil {
+			fatalf("failed to write config: %v", err)
+		}
+		return
+	}
+
+	// The log package only logs problems, so is logged at the warn level
+	var logLevel slog.LevelVar
+	logLevel.Set(cfg.LogLevel)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel})))
+	slog.SetLogLoggerLevel(slog.LevelWarn)
 
 	// Connect to Postgres using pgxpool
-	connPool, err := pgxpool.New(context.Background(), connStr)
+	connPool, err := newPool(cfg, cfg.databaseURL())
 	if err != nil {
-		log.Fatalf("failed to connect to postgres: %v", err)
+		fatalf("failed to connect to postgres: %v", err)
 	}
 	defer connPool.Close()
 
+	replicaPools := make([]*pgxpool.Pool, len(cfg.DBReplicaURLs))
+	for i, replicaURL := range cfg.DBReplicaURLs {
+		if replicaPools[i], err = newPool(cfg, replicaURL); err != nil {
+			fatalf("failed to connect to postgres replica: %v", err)
+		}
+		defer replicaPools[i].Close()
+	}
+
 	// Initialize repository and service
-	repo := repository.NewRepository(connPool)
-	svc := service.NewService(repo)
+	repo, err := newRepository(cfg, connPool, replicaPools)
+	if err != nil {
+		fatalf("failed to initialize repository: %v", err)
+	}
+	svc := service.NewService(repo, service.WithNoteQuota(cfg.NoteQuota))
+
+	// Report the notes the title normalization migration would rename, instead of serving
+	if len(args) > 0 && args[0] == "title-conflicts" {
+		if err := reportTitleConflicts(context.Background(), repo, os.Stdout); err != nil {
+			fatalf("failed to report title conflicts: %v", err)
+		}
+		return
+	}
+
+	// Import the notes in a file, instead of serving
+	if len(args) > 0 && args[0] == "import" {
+		if err := importNotes(context.Background(), svc, args[1:], os.Stdout); err != nil {
+			fatalf("failed to import notes: %v", err)
+		}
+		return
+	}
+
+	if cfg.NoteEventRetention > 0 {
+		go pruneNoteEvents(context.Background(), repo, cfg.NoteEventRetention)
+	}
+
+	// Start the gRPC server
+	grpcServer := grpc.NewServer(svc, grpc.WithAPIKeys(cfg.APIKeys...))
+	grpcAddr := fmt.Sprintf(":%d", cfg.GRPCPort)
+	go func() {
+		slog.Info("starting gRPC server", "addr", grpcAddr)
+		if err := grpcServer.Start(grpcAddr); err != nil {
+			fatalf("gRPC server stopped with error: %v", err)
+		}
+	}()
+	defer grpcServer.Stop()
 
 	// Start the HTTP server
-	server := http.NewServer(svc)
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitStore = ratelimit.NewPostgresStore(connPool)
+	default:
+		fatalf("unknown rate limit store %q", cfg.RateLimitStore)
+	}
+
+	opts := []http.Option{
+		http.WithAPIKeys(cfg.APIKeys...),
+		http.WithTrustedProxies(cfg.TrustedProxies...),
+		http.WithRateLimits(rateLimitStore, cfg.RateLimits),
+		http.WithMaxBodySize(cfg.MaxBodySize),
+	}
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		opts = append(opts, http.WithCORS(http.CORSConfig{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   cfg.CORSAllowedMethods,
+			AllowedHeaders:   cfg.CORSAllowedHeaders,
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		}))
+	}
+	if !cfg.HSTS {
+		opts = append(opts, http.WithSecurityHeader("Strict-Transport-Security", ""))
+	}
+	if cfg.TLSCertFile != "" {
+		opts = append(opts, http.WithTLS(http.TLSConfig{
+			CertFile:           cfg.TLSCertFile,
+			KeyFile:            cfg.TLSKeyFile,
+			ClientCAFile:       cfg.TLSClientCAFile,
+			ClientCertOptional: cfg.TLSClientCertOptional,
+		}))
+	}
+
+	server := http.NewServer(svc, opts...)
+	go reloadOnHangup(cfg, &logLevel, server)
+
 	addr := fmt.Sprintf(":%d", cfg.ServerPort)
-	log.Printf("starting server on %s...", addr)
+	slog.Info("starting server", "addr", addr)
 	if err := server.Start(addr); err != nil {
-		log.Fatalf("server stopped with error: %v", err)
+		fatalf("server stopped with error: %v", err)
+	}
+}
+
+// Logs at the error level before exiting, so fatal errors are logged whatever the log level.
+func fatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// How often note events past their retention are deleted.
+const noteEventsPruneInterval = time.Hour
+
+// Deletes the note events recorded more than retention ago, every noteEventsPruneInterval.
+// Clients resuming from a deleted event only get the events kept.
+func pruneNoteEvents(ctx context.Context, repo repository.Repository, retention time.Duration) {
+	ticker := time.NewTicker(noteEventsPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := repo.DeleteNoteEventsBefore(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("unable to prune note events", "error", err)
+		} else if deleted > 0 {
+			slog.Info("pruned note events", "deleted", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reloads the config whenever the process is sent SIGHUP, applying the settings that can
+// change while serving. Invalid configs are logged and ignored.
+func reloadOnHangup(cfg Config, logLevel *slog.LevelVar, server *http.Server) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	for range hangups {
+		next, _, err := loadConfig(os.Args[1:], os.LookupEnv)
+		if err != nil {
+			slog.Error("unable to reload config, keeping the current one", "error", err)
+			continue
+		}
+
+		var restartRequired []string
+		cfg, restartRequired = cfg.reload(next)
+		logLevel.Set(cfg.LogLevel)
+		server.SetRateLimits(cfg.RateLimits)
+
+		slog.Info("reloaded config")
+		for _, name := range restartRequired {
+			slog.Warn("setting changed, restart to apply it", "setting", name)
+		}
+	}
+}
+
+// Pools connections to the database at the URL with the pool settings of the config.
+func newPool(cfg Config, databaseURL string) (*pgxpool.Pool, error) {
+	poolConfig, err := cfg.poolConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.NewWithConfig(context.Background(), poolConfig)
+}
+
+// Reads from the replicas if there are any, and wraps the repository in a cache unless caching is disabled.
+func newRepository(cfg Config, connPool *pgxpool.Pool, replicaPools []*pgxpool.Pool) (repository.Repository, error) {
+	repo := repository.NewRepository(
+		connPool,
+		repository.WithReplicas(replicaPools...),
+		repository.WithReadYourWritesWindow(cfg.DBReadYourWritesWindow),
+	)
+	if cfg.CacheTTL <= 0 {
+		return repo, nil
+	}
+
+	if cfg.RedisURL == "" {
+		return repository.NewCachingRepository(repo, repository.NewLRUCache(cfg.CacheSize), cfg.CacheTTL), nil
+	}
+
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	cache := repository.NewRedisCache(redis.NewClient(opts), "notes:")
+	return repository.NewCachingRepository(repo, cache, cfg.CacheTTL), nil
+}
+
+// Writes every set of notes whose titles are equal once normalized. The oldest
+// note of each set keeps its title, the rest are renamed as the migration would.
+func reportTitleConflicts(ctx context.Context, repo repository.Repository, w io.Writer) error {
+	conflicts, err := repo.FetchTitleConflicts(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) == 0 {
+		_, err := fmt.Fprintln(w, "no conflicting titles found")
+		return err
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Fprintf(w, "%q:\n", conflict.NormalizedTitle)
+		for i, note := range conflict.Notes {
+			if i == 0 {
+				fmt.Fprintf(w, "  keep   %s %q\n", note.ID, note.Title)
+				continue
+			}
+			fmt.Fprintf(w, "  rename %s %q -> %q\n", note.ID, note.Title, fmt.Sprintf("%s (%s)", note.Title, note.ID.String()[:8]))
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "%d conflicting titles found\n", len(conflicts))
+	return err
+}
+
+const importUsage = "usage: import [--format ndjson|csv|zip] [--on-conflict error|rename|upsert] FILE"
+
+// Imports the notes in the file named by args, or in stdin if it is named "-", writing the
+// result of each. The format defaults to the one named by the file's extension, e.g. notes.csv.
+// Returns an error if any note couldn't be imported.
+func importNotes(ctx context.Context, svc service.Service, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "", "ndjson, csv or zip")
+	onConflict := flags.String("on-conflict", string(repository.ConflictError), "error, rename or upsert")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w\n%s", err, importUsage)
+	}
+	if flags.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	name := flags.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+		if *format == "jsonl" {
+			*format = string(importer.FormatNDJSON)
+		}
+	}
+
+	file := os.Stdin
+	if name != "-" {
+		var err error
+		if file, err = os.Open(name); err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+
+	var imported, failed int
+	err := importer.Import(ctx, svc, importer.Format(*format), file, repository.ConflictStrategy(*onConflict), func(result importer.Result) {
+		row := fmt.Sprintf("row %d", result.Row)
+		if result.File != "" {
+			row += " (" + result.File + ")"
+		}
+
+		if result.Status == importer.StatusImported {
+			imported++
+			fmt.Fprintf(w, "%s: imported %s %q\n", row, result.NoteID, result.Title)
+			return
+		}
+		failed++
+		fmt.Fprintf(w, "%s: failed: %s\n", row, result.Error)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d notes imported, %d failed\n", imported, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d notes couldn't be imported", failed)
 	}
+	return nil
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"github.com/the-code-genin/golang_integration_testing/service"
+	"go.uber.org/mock/gomock"
+)
+
+func TestImportNotes(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	mockService := service.NewMockService(ctrl)
+
+	writeFile := func(t *testing.T, name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("should import the file in the format its extension names", func(t *testing.T) {
+		id := uuid.New()
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), repository.CreateNoteDTO{Title: "Groceries", Description: "Milk", OnConflict: repository.ConflictUpsert}).
+			Return(&repository.Note{ID: id, Title: "Groceries"}, nil)
+
+		var out bytes.Buffer
+		err := importNotes(ctx, mockService, []string{"--on-conflict", "upsert", writeFile(t, "notes.jsonl", `{"title":"Groceries","description":"Milk"}`)}, &out)
+		assert.NoError(t, err)
+		assert.Equal(t, "row 1: imported "+id.String()+" \"Groceries\"\n1 notes imported, 0 failed\n", out.String())
+	})
+
+	t.Run("should report the notes that couldn't be imported", func(t *testing.T) {
+		mockService.EXPECT().
+			CreateNote(gomock.Any(), repository.CreateNoteDTO{Title: "Groceries", Description: "Milk", OnConflict: repository.ConflictError}).
+			Return(nil, service.ErrNoteTitleTaken)
+
+		var out bytes.Buffer
+		err := importNotes(ctx, mockService, []string{"--format", "csv", writeFile(t, "notes.txt", "title,description\nGroceries,Milk\n")}, &out)
+		assert.EqualError(t, err, "1 notes couldn't be imported")
+		assert.Equal(t, "row 1: failed: "+service.ErrNoteTitleTaken.Error()+"\n0 notes imported, 1 failed\n", out.String())
+	})
+
+	t.Run("should require a single file", func(t *testing.T) {
+		err := importNotes(ctx, mockService, nil, &bytes.Buffer{})
+		assert.EqualError(t, err, importUsage)
+	})
+}
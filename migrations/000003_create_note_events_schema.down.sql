@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS notes_events_trigger ON core.notes;
+
+DROP FUNCTION IF EXISTS core.record_note_event();
+
+DROP TABLE IF EXISTS core.note_events;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS core.note_events (
+    id BIGSERIAL NOT NULL,
+    event_type VARCHAR NOT NULL,
+    note_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT note_events_pkey PRIMARY KEY (id)
+);
+
+CREATE OR REPLACE FUNCTION core.record_note_event() RETURNS TRIGGER AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO core.note_events (event_type, note_id, payload)
+        VALUES ('deleted', OLD.id, to_jsonb(OLD))
+        RETURNING id INTO event_id;
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO core.note_events (event_type, note_id, payload)
+        VALUES ('updated', NEW.id, to_jsonb(NEW))
+        RETURNING id INTO event_id;
+    ELSE
+        INSERT INTO core.note_events (event_type, note_id, payload)
+        VALUES ('created', NEW.id, to_jsonb(NEW))
+        RETURNING id INTO event_id;
+    END IF;
+
+    -- Only the event ID is sent, as NOTIFY payloads are limited to 8000 bytes
+    PERFORM pg_notify('note_events', event_id::TEXT);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER notes_events_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON core.notes
+    FOR EACH ROW EXECUTE FUNCTION core.record_note_event();
@@ -0,0 +1,6 @@
+-- Titles renamed by the up migration keep their suffixes
+DROP INDEX IF EXISTS core.notes_unique_normalized_title_index;
+
+CREATE UNIQUE INDEX IF NOT EXISTS notes_unique_title_index ON core.notes (title);
+
+DROP FUNCTION IF EXISTS core.normalize_title(TEXT);
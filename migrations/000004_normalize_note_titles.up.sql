@@ -0,0 +1,22 @@
+-- The form titles are compared in for uniqueness
+CREATE OR REPLACE FUNCTION core.normalize_title(title TEXT) RETURNS TEXT
+LANGUAGE SQL IMMUTABLE PARALLEL SAFE STRICT AS $$
+    SELECT lower(normalize(btrim(title), NFKC));
+$$;
+
+-- Keep the oldest of every set of notes with the same normalized title,
+-- and suffix the titles of the rest with the start of their IDs
+WITH duplicates AS (
+    SELECT
+        id,
+        ROW_NUMBER() OVER (PARTITION BY core.normalize_title(title) ORDER BY created_at, id) AS position
+    FROM core.notes
+)
+UPDATE core.notes
+SET title = notes.title || ' (' || left(notes.id::TEXT, 8) || ')', updated_at = NOW()
+FROM duplicates
+WHERE duplicates.id = notes.id AND duplicates.position > 1;
+
+DROP INDEX IF EXISTS core.notes_unique_title_index;
+
+CREATE UNIQUE INDEX IF NOT EXISTS notes_unique_normalized_title_index ON core.notes (core.normalize_title(title));
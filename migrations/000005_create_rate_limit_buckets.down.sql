@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS core.take_rate_limit_token(VARCHAR, DOUBLE PRECISION, DOUBLE PRECISION);
+
+DROP TABLE IF EXISTS core.rate_limit_buckets;
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS core.rate_limit_buckets (
+    key VARCHAR NOT NULL,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    -- Once the bucket is full again it is the same as a missing bucket, so can be dropped
+    full_at TIMESTAMPTZ NOT NULL,
+
+    CONSTRAINT rate_limit_buckets_pkey PRIMARY KEY (key)
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_full_at_index ON core.rate_limit_buckets (full_at);
+
+-- Refills the bucket, creating it full if it doesn't exist, then takes a token from it if there is one.
+-- The bucket is locked throughout, so concurrent requests can't take the same token.
+CREATE OR REPLACE FUNCTION core.take_rate_limit_token(
+    bucket_key VARCHAR,
+    capacity DOUBLE PRECISION,
+    refill_rate DOUBLE PRECISION,
+    OUT allowed BOOLEAN,
+    OUT remaining DOUBLE PRECISION
+) AS $$
+DECLARE
+    bucket core.rate_limit_buckets%ROWTYPE;
+    taken_at TIMESTAMPTZ := clock_timestamp();
+BEGIN
+    INSERT INTO core.rate_limit_buckets (key, tokens, updated_at, full_at)
+    VALUES (bucket_key, capacity, taken_at, taken_at)
+    ON CONFLICT (key) DO NOTHING;
+
+    SELECT * INTO bucket FROM core.rate_limit_buckets WHERE key = bucket_key FOR UPDATE;
+
+    remaining := LEAST(capacity, bucket.tokens + GREATEST(EXTRACT(EPOCH FROM taken_at - bucket.updated_at), 0) * refill_rate);
+    allowed := remaining >= 1;
+    IF allowed THEN
+        remaining := remaining - 1;
+    END IF;
+
+    UPDATE core.rate_limit_buckets
+    SET tokens = remaining,
+        updated_at = taken_at,
+        full_at = taken_at + make_interval(secs => (capacity - remaining) / refill_rate)
+    WHERE key = bucket_key;
+END;
+$$ LANGUAGE plpgsql;
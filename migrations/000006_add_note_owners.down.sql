@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS core.notes_owner_index;
+
+ALTER TABLE core.notes DROP COLUMN IF EXISTS owner;
@@ -0,0 +1,4 @@
+-- The client that created the note, NULL for notes created before owners were tracked
+ALTER TABLE core.notes ADD COLUMN IF NOT EXISTS owner VARCHAR;
+
+CREATE INDEX IF NOT EXISTS notes_owner_index ON core.notes (owner);
@@ -0,0 +1,3 @@
+ALTER TABLE core.notes DROP CONSTRAINT IF EXISTS notes_format_check;
+
+ALTER TABLE core.notes DROP COLUMN IF EXISTS format;
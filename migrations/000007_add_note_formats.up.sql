@@ -0,0 +1,5 @@
+-- How the description is written, plain text for notes created before formats
+ALTER TABLE core.notes ADD COLUMN IF NOT EXISTS format VARCHAR NOT NULL DEFAULT 'plain';
+
+ALTER TABLE core.notes DROP CONSTRAINT IF EXISTS notes_format_check;
+ALTER TABLE core.notes ADD CONSTRAINT notes_format_check CHECK (format IN ('plain', 'markdown'));
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS core.notes_created_at_id_index;
@@ -0,0 +1,2 @@
+-- Lets notes be paged through oldest first, as StreamNotes does, without sorting the table
+CREATE INDEX IF NOT EXISTS notes_created_at_id_index ON core.notes (created_at, id);
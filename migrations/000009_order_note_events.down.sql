@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS core.note_events_created_at_index;
+
+DROP INDEX IF EXISTS core.note_events_transaction_id_index;
+
+ALTER TABLE core.note_events DROP COLUMN IF EXISTS transaction_id;
@@ -0,0 +1,9 @@
+-- Events are delivered in the order of the transactions recording them rather than of their IDs,
+-- which are taken before the transactions commit, so readers resuming after an event can't miss one
+-- that took a lower ID but committed later. Events recorded before are all taken to be of one transaction.
+ALTER TABLE core.note_events ADD COLUMN IF NOT EXISTS transaction_id XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS note_events_transaction_id_index ON core.note_events (transaction_id, id);
+
+-- Lets events past their retention be pruned without scanning the table
+CREATE INDEX IF NOT EXISTS note_events_created_at_index ON core.note_events (created_at);
@@ -5,6 +5,8 @@ import (
 	"embed"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 //go:embed *.up.sql
@@ -30,15 +32,37 @@ func readFileNames(fsys embed.FS) ([]string, error) {
 	return fileNames, nil
 }
 
+// The version a migration file is named after, e.g. 4 for 000004_normalize_note_titles.up.sql
+func migrationVersion(fileName string) (int, error) {
+	prefix, _, _ := strings.Cut(fileName, "_")
+	version, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("migration file %s is not named after its version", fileName)
+	}
+	return version, nil
+}
+
 // Squash the migration files in the directory fsys into a Buffer,
 // with the option to squash the files in ascending or descending order.
-func squashMigrations(fsys embed.FS, asc bool) (*bytes.Buffer, error) {
+// Only the migrations of versions after the version given are squashed.
+func squashMigrations(fsys embed.FS, asc bool, after int) (*bytes.Buffer, error) {
 	// Read the file names
-	fileNames, err := readFileNames(fsys)
+	allFileNames, err := readFileNames(fsys)
 	if err != nil {
 		return nil, fmt.Errorf("could not list migration files: %w", err)
 	}
 
+	fileNames := []string{}
+	for _, fileName := range allFileNames {
+		version, err := migrationVersion(fileName)
+		if err != nil {
+			return nil, err
+		}
+		if version > after {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+
 	// Sort the file names
 	if asc {
 		sort.Strings(fileNames)
@@ -68,15 +92,25 @@ func squashMigrations(fsys embed.FS, asc bool) (*bytes.Buffer, error) {
 
 // Squash the up and down migrations in the migrations folder into Buffers.
 func SquashMigrations() (upMigrations, downMigrations *bytes.Buffer, err error) {
-	upMigrations, err = squashMigrations(upMigrationsFS, true)
+	upMigrations, err = squashMigrations(upMigrationsFS, true, 0)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to squash up migrations: %w", err)
 	}
 
-	downMigrations, err = squashMigrations(downMigrationsFS, false)
+	downMigrations, err = squashMigrations(downMigrationsFS, false, 0)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to squash down migrations: %w", err)
 	}
 
 	return upMigrations, downMigrations, nil
 }
+
+// Squash the down migrations of the versions after version into a Buffer, latest first,
+// so applying them takes a fully migrated database back to that version.
+func SquashDownMigrationsAfter(version int) (*bytes.Buffer, error) {
+	downMigrations, err := squashMigrations(downMigrationsFS, false, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to squash down migrations: %w", err)
+	}
+	return downMigrations, nil
+}
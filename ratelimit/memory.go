@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// When the bucket is full again, after which it is the same as a missing bucket
+	fullAt time.Time
+}
+
+// Keeps buckets in memory, so each instance of the server limits requests on its own.
+type memoryStore struct {
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	lastPruned time.Time
+	now        func() time.Time
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *memoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	tokens, allowed := limit.take(b.tokens, now.Sub(b.updatedAt))
+	result := limit.result(tokens, allowed)
+
+	b.tokens, b.updatedAt, b.fullAt = tokens, now, now.Add(result.Reset)
+	return result, nil
+}
+
+// Drops the buckets that have refilled, so idle clients don't hold on to memory.
+func (s *memoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPruned) < pruneInterval {
+		return
+	}
+	s.lastPruned = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Keeps buckets in Postgres, so every instance of the server sharing the database
+// shares the same limits.
+type postgresStore struct {
+	conn *pgxpool.Pool
+	// Unix nanoseconds
+	lastPruned atomic.Int64
+}
+
+func NewPostgresStore(conn *pgxpool.Pool) Store {
+	return &postgresStore{conn: conn}
+}
+
+func (s *postgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	var (
+		allowed bool
+		tokens  float64
+	)
+	err := s.conn.QueryRow(
+		ctx,
+		`SELECT allowed, remaining FROM core.take_rate_limit_token($1, $2, $3)`,
+		key, float64(limit.Requests), limit.rate(),
+	).Scan(&allowed, &tokens)
+	if err != nil {
+		return Result{}, err
+	}
+
+	s.prune(ctx)
+	return limit.result(tokens, allowed), nil
+}
+
+// Drops the buckets that have refilled, at most once per interval per instance.
+func (s *postgresStore) prune(ctx context.Context) {
+	now := time.Now()
+	last := s.lastPruned.Load()
+	if now.Sub(time.Unix(0, last)) < pruneInterval || !s.lastPruned.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if _, err := s.conn.Exec(ctx, `DELETE FROM core.rate_limit_buckets WHERE full_at <= clock_timestamp()`); err != nil {
+		log.Printf("unable to prune rate limit buckets: %v", err)
+	}
+}
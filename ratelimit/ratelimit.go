@@ -0,0 +1,97 @@
+// Package ratelimit limits how often clients can make requests, using token buckets
+// kept in memory or in Postgres.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How often buckets that have refilled are dropped.
+const pruneInterval = time.Minute
+
+// Allows bursts of up to Requests requests, refilling at Requests per Period.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Parses a limit written as requests/period, e.g. "10/1m".
+func ParseLimit(value string) (Limit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: must be written as requests/period, e.g. 10/1m", value)
+	}
+
+	var (
+		limit Limit
+		err   error
+	)
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", value)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", value)
+	}
+	return limit, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Tokens added to a bucket per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Refills a bucket holding tokens when it was last used elapsed ago,
+// then takes a token from it if there is one.
+func (l Limit) take(tokens float64, elapsed time.Duration) (float64, bool) {
+	tokens = math.Min(float64(l.Requests), tokens+max(elapsed, 0).Seconds()*l.rate())
+	if tokens < 1 {
+		return tokens, false
+	}
+	return tokens - 1, true
+}
+
+// Describes a bucket holding tokens after a request was allowed or not.
+func (l Limit) result(tokens float64, allowed bool) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     l,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     l.until(float64(l.Requests) - tokens),
+	}
+	if !allowed {
+		result.RetryAfter = l.until(1 - tokens)
+	}
+	return result
+}
+
+// How long until the bucket gains the tokens given.
+func (l Limit) until(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.rate() * float64(time.Second)))
+}
+
+// The outcome of a request against a limit.
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Requests that can be made right away
+	Remaining int
+	// How long until the bucket is full again
+	Reset time.Duration
+	// How long until a request is allowed, if this one wasn't
+	RetryAfter time.Duration
+}
+
+// Keeps a token bucket for every key.
+type Store interface {
+	// Takes a token from the key's bucket, which is created full if it doesn't exist.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-code-genin/golang_integration_testing/tests"
+)
+
+func TestParseLimit(t *testing.T) {
+	t.Run("should parse limits", func(t *testing.T) {
+		limit, err := ParseLimit("10/1m")
+		assert.NoError(t, err)
+		assert.Equal(t, Limit{Requests: 10, Period: time.Minute}, limit)
+		assert.Equal(t, "10/1m0s", limit.String())
+	})
+
+	t.Run("should return an error for invalid limits", func(t *testing.T) {
+		for _, value := range []string{"", "10", "ten/1m", "0/1m", "10/minute", "10/-1s"} {
+			_, err := ParseLimit(value)
+			assert.Error(t, err, value)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+
+	newStore := func() (*memoryStore, *time.Time) {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		store := NewMemoryStore().(*memoryStore)
+		store.now = func() time.Time { return now }
+		return store, &now
+	}
+
+	t.Run("should allow bursts up to the limit", func(t *testing.T) {
+		store, _ := newStore()
+
+		result, err := store.Take(ctx, "client", limit)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{Allowed: true, Limit: limit, Remaining: 1, Reset: 5 * time.Second}, result)
+
+		result, err = store.Take(ctx, "client", limit)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{Allowed: true, Limit: limit, Remaining: 0, Reset: 10 * time.Second}, result)
+
+		result, err = store.Take(ctx, "client", limit)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{Allowed: false, Limit: limit, Remaining: 0, Reset: 10 * time.Second, RetryAfter: 5 * time.Second}, result)
+	})
+
+	t.Run("should refill buckets over time", func(t *testing.T) {
+		store, now := newStore()
+
+		for range 2 {
+			_, _ = store.Take(ctx, "client", limit)
+		}
+
+		*now = now.Add(4 * time.Second)
+		result, _ := store.Take(ctx, "client", limit)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Second, result.RetryAfter)
+
+		*now = now.Add(time.Second)
+		result, _ = store.Take(ctx, "client", limit)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("should keep a bucket per key", func(t *testing.T) {
+		store, _ := newStore()
+
+		for range 2 {
+			_, _ = store.Take(ctx, "client", limit)
+		}
+
+		result, _ := store.Take(ctx, "other client", limit)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("should drop buckets once they have refilled", func(t *testing.T) {
+		store, now := newStore()
+
+		_, _ = store.Take(ctx, "idle client", limit)
+		*now = now.Add(pruneInterval)
+		_, _ = store.Take(ctx, "client", limit)
+
+		assert.NotContains(t, store.buckets, "idle client")
+		assert.Contains(t, store.buckets, "client")
+	})
+}
+
+func TestPostgresStore(t *testing.T) {
+	ctx := context.Background()
+
+	// Setup postgres database
+	_, conn, cleanupFunc, err := tests.SetupPostgresDB(ctx)
+	assert.NoError(t, err)
+
+	defer func() {
+		err := cleanupFunc()
+		assert.NoError(t, err)
+	}()
+
+	store := NewPostgresStore(conn)
+
+	t.Run("should allow bursts up to the limit", func(t *testing.T) {
+		limit := Limit{Requests: 2, Period: time.Hour}
+
+		for range 2 {
+			result, err := store.Take(ctx, "client", limit)
+			assert.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+
+		result, err := store.Take(ctx, "client", limit)
+		assert.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.InDelta(t, 30*time.Minute, result.RetryAfter, float64(time.Second))
+	})
+
+	t.Run("should refill buckets over time", func(t *testing.T) {
+		limit := Limit{Requests: 1, Period: 100 * time.Millisecond}
+
+		result, err := store.Take(ctx, "refilled client", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+
+		time.Sleep(150 * time.Millisecond)
+
+		result, err = store.Take(ctx, "refilled client", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("should not allow concurrent requests to take the same token", func(t *testing.T) {
+		limit := Limit{Requests: 5, Period: time.Hour}
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+		for range 20 {
+			wg.Go(func() {
+				result, err := store.Take(ctx, "concurrent client", limit)
+				assert.NoError(t, err)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if result.Allowed {
+					allowed++
+				}
+			})
+		}
+		wg.Wait()
+
+		assert.Equal(t, 5, allowed)
+	})
+}
@@ -0,0 +1,177 @@
+// Package render turns note descriptions into sanitized HTML, along with the
+// headings, links and tasks they contain.
+package render
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	// The id of the heading's element, to link to it with a fragment
+	ID string `json:"id"`
+}
+
+type Link struct {
+	URL  string `json:"url"`
+	Text string `json:"text"`
+}
+
+// An item of a task list, e.g. "- [x] Buy milk".
+type Task struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+// A description rendered to HTML, with its structure extracted in document order.
+type Rendered struct {
+	HTML     string    `json:"html"`
+	Headings []Heading `json:"headings"`
+	Links    []Link    `json:"links"`
+	Tasks    []Task    `json:"tasks"`
+}
+
+// The URL schemes links are kept with, any other link is dropped by the sanitizer.
+var linkSchemes = []string{"http", "https", "mailto"}
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+// Allows what users can write in Markdown, and the checkboxes of task lists.
+// Raw HTML is already left out by goldmark, this guards against anything it lets through.
+var policy = func() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowURLSchemes(linkSchemes...)
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	return policy
+}()
+
+// Renders a plain text description, as paragraphs separated by blank lines.
+func Plain(description string) Rendered {
+	description = strings.ReplaceAll(description, "\r\n", "\n")
+
+	var b strings.Builder
+	for paragraph := range strings.SplitSeq(description, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		lines := strings.Split(html.EscapeString(paragraph), "\n")
+		b.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+
+	return Rendered{HTML: b.String(), Headings: []Heading{}, Links: []Link{}, Tasks: []Task{}}
+}
+
+// Renders a GitHub Flavored Markdown description.
+func Markdown(description string) (Rendered, error) {
+	source := []byte(description)
+	document := markdown.Parser().Parse(text.NewReader(source))
+
+	var b bytes.Buffer
+	if err := markdown.Renderer().Render(&b, source, document); err != nil {
+		return Rendered{}, err
+	}
+
+	rendered := Rendered{
+		HTML:     policy.Sanitize(b.String()),
+		Headings: []Heading{},
+		Links:    []Link{},
+		Tasks:    []Task{},
+	}
+
+	err := ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := node.(type) {
+		case *ast.Heading:
+			heading := Heading{Level: node.Level, Text: plainText(node, source)}
+			if id, ok := node.AttributeString("id"); ok {
+				if id, ok := id.([]byte); ok {
+					heading.ID = string(id)
+				}
+			}
+			rendered.Headings = append(rendered.Headings, heading)
+
+		case *ast.Link:
+			if isKeptLink(string(node.Destination)) {
+				rendered.Links = append(rendered.Links, Link{URL: string(node.Destination), Text: plainText(node, source)})
+			}
+
+		case *ast.AutoLink:
+			if isKeptLink(string(node.URL(source))) {
+				rendered.Links = append(rendered.Links, Link{URL: string(node.URL(source)), Text: string(node.Label(source))})
+			}
+
+		case *extast.TaskCheckBox:
+			// The checkbox starts the text of its list item
+			rendered.Tasks = append(rendered.Tasks, Task{Text: plainText(node.Parent(), source), Done: node.IsChecked})
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	return rendered, nil
+}
+
+// Reports whether the sanitizer keeps a link to the URL, which it does for
+// relative URLs and those with an allowed scheme.
+func isKeptLink(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" {
+		return true
+	}
+	for _, scheme := range linkSchemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the text of the node's descendants, without any markup.
+func plainText(node ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(node, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := node.(type) {
+		case *ast.Text:
+			b.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(node.Value)
+		case *ast.AutoLink:
+			b.Write(node.Label(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,63 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlain(t *testing.T) {
+	t.Run("should escape the text and keep its paragraphs and line breaks", func(t *testing.T) {
+		rendered := Plain("Buy <milk> & eggs\nand bread\r\n\r\n\n# Not a heading")
+
+		assert.Equal(t, "<p>Buy &lt;milk&gt; &amp; eggs<br>\nand bread</p>\n<p># Not a heading</p>\n", rendered.HTML)
+		assert.Equal(t, Rendered{HTML: rendered.HTML, Headings: []Heading{}, Links: []Link{}, Tasks: []Task{}}, rendered)
+	})
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Run("should render Markdown and extract its headings, links and tasks", func(t *testing.T) {
+		rendered, err := Markdown(`# Weekend plans
+
+Read the [*release* notes](https://example.com/notes) and https://go.dev.
+
+## To do
+
+- [x] Buy ` + "`milk`" + `
+- [ ] Call [Sam](mailto:sam@example.com)
+- Not a task
+`)
+		require.NoError(t, err)
+
+		assert.Contains(t, rendered.HTML, `<h1 id="weekend-plans">Weekend plans</h1>`)
+		assert.Contains(t, rendered.HTML, `<a href="https://example.com/notes" rel="nofollow"><em>release</em> notes</a>`)
+		assert.Contains(t, rendered.HTML, `<input checked="" disabled="" type="checkbox"> Buy <code>milk</code>`)
+		assert.Contains(t, rendered.HTML, `<input disabled="" type="checkbox"> Call`)
+
+		assert.Equal(t, []Heading{
+			{Level: 1, Text: "Weekend plans", ID: "weekend-plans"},
+			{Level: 2, Text: "To do", ID: "to-do"},
+		}, rendered.Headings)
+		assert.Equal(t, []Link{
+			{URL: "https://example.com/notes", Text: "release notes"},
+			{URL: "https://go.dev", Text: "https://go.dev"},
+			{URL: "mailto:sam@example.com", Text: "Sam"},
+		}, rendered.Links)
+		assert.Equal(t, []Task{
+			{Text: "Buy milk", Done: true},
+			{Text: "Call Sam", Done: false},
+		}, rendered.Tasks)
+	})
+
+	t.Run("should leave out raw HTML and dangerous links", func(t *testing.T) {
+		rendered, err := Markdown("<script>alert(1)</script>\n\n[click](javascript:alert(1)) <img src=x onerror=alert(1)>")
+		require.NoError(t, err)
+
+		assert.NotContains(t, rendered.HTML, "<script")
+		assert.NotContains(t, rendered.HTML, "javascript:")
+		assert.NotContains(t, rendered.HTML, "onerror")
+		assert.Contains(t, rendered.HTML, "click")
+		assert.Empty(t, rendered.Links)
+	})
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// Serves FetchNoteByID from a cache, falling back to the wrapped repository on
+// misses. Every other read goes straight to the wrapped repository.
+type cachingRepository struct {
+	Repository
+	cache Cache
+	ttl   time.Duration
+
+	// Collapses concurrent misses for the same note into a single query
+	loads singleflight.Group
+	// Bumped by every invalidation, so a load racing a write doesn't cache the note it replaced
+	generation atomic.Uint64
+	// Held for writing while invalidating, and for reading while caching a load
+	invalidating sync.RWMutex
+}
+
+// Wraps repo, caching notes fetched by ID for ttl. Notes are evicted as they are
+// updated or deleted through the returned repository; writes that bypass it, e.g. from
+// another process with its own in-process cache, are only seen once the note expires.
+func NewCachingRepository(repo Repository, cache Cache, ttl time.Duration) Repository {
+	return &cachingRepository{Repository: repo, cache: cache, ttl: ttl}
+}
+
+func noteCacheKey(id uuid.UUID) string {
+	return "note:" + id.String()
+}
+
+func (r *cachingRepository) CreateNote(ctx context.Context, dto CreateNoteDTO) (*Note, error) {
+	note, err := r.Repository.CreateNote(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	// Upserts can update a note that is already cached
+	if dto.OnConflict == ConflictUpsert {
+		r.invalidate(ctx, note.ID)
+	}
+	return note, nil
+}
+
+func (r *cachingRepository) UpdateNote(ctx context.Context, id uuid.UUID, dto UpdateNoteDTO) (*Note, error) {
+	// Invalidate even if the update failed, as it may have been applied anyway
+	defer r.invalidate(ctx, id)
+	return r.Repository.UpdateNote(ctx, id, dto)
+}
+
+func (r *cachingRepository) DeleteNote(ctx context.Context, id uuid.UUID) error {
+	defer r.invalidate(ctx, id)
+	return r.Repository.DeleteNote(ctx, id)
+}
+
+func (r *cachingRepository) FetchNoteByID(ctx context.Context, id uuid.UUID, fields ...NoteField) (*Note, error) {
+	key := noteCacheKey(id)
+
+	data, ok, err := r.cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("unable to read note %s from the cache: %v", id, err)
+	}
+	if ok {
+		var note Note
+		if err := json.Unmarshal(data, &note); err == nil {
+			note = note.Project(fields...)
+			return &note, nil
+		}
+		log.Printf("unable to decode cached note %s: %v", id, err)
+	}
+
+	// Whole notes are loaded, so every projection can be served from the cache.
+	// The load outlives the caller that started it, as other callers may be waiting on it.
+	// It reads from the primary, as a lagging replica could still hold the note a write
+	// just invalidated, which would then be cached and served to every session.
+	loadCtx := onPrimary(context.WithoutCancel(ctx))
+	results := r.loads.DoChan(key, func() (any, error) {
+		return r.load(loadCtx, id)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		note := result.Val.(*Note).Project(fields...)
+		return &note, nil
+	}
+}
+
+// Fetches the whole note from the wrapped repository and caches it.
+func (r *cachingRepository) load(ctx context.Context, id uuid.UUID) (*Note, error) {
+	generation := r.generation.Load()
+
+	note, err := r.Repository.FetchNoteByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(note)
+	if err != nil {
+		log.Printf("unable to encode note %s for the cache: %v", id, err)
+		return note, nil
+	}
+
+	r.invalidating.RLock()
+	defer r.invalidating.RUnlock()
+
+	// The note may be stale if it was invalidated while it was being fetched
+	if r.generation.Load() != generation {
+		return note, nil
+	}
+	if err := r.cache.Set(ctx, noteCacheKey(id), data, r.ttl); err != nil {
+		log.Printf("unable to cache note %s: %v", id, err)
+	}
+	return note, nil
+}
+
+// Evicts the note from the cache. Failures are logged rather than returned, as the write
+// has already been made; the note is then served stale until it expires.
+func (r *cachingRepository) invalidate(ctx context.Context, id uuid.UUID) {
+	key := noteCacheKey(id)
+
+	r.invalidating.Lock()
+	defer r.invalidating.Unlock()
+
+	r.generation.Add(1)
+	// Later misses shouldn't wait on a load that may return the old note
+	r.loads.Forget(key)
+
+	if err := r.cache.Delete(context.WithoutCancel(ctx), key); err != nil {
+		log.Printf("unable to evict note %s from the cache: %v", id, err)
+	}
+}
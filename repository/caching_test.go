@@ -0,0 +1,358 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should return cached values until they expire", func(t *testing.T) {
+		cache := repository.NewLRUCache(10)
+
+		assert.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Hour))
+		assert.NoError(t, cache.Set(ctx, "b", []byte("2"), 10*time.Millisecond))
+
+		value, ok, err := cache.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), value)
+
+		time.Sleep(20 * time.Millisecond)
+
+		_, ok, err = cache.Get(ctx, "b")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("should evict the least recently used entry once full", func(t *testing.T) {
+		cache := repository.NewLRUCache(2)
+
+		assert.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Hour))
+		assert.NoError(t, cache.Set(ctx, "b", []byte("2"), time.Hour))
+		_, _, _ = cache.Get(ctx, "a")
+		assert.NoError(t, cache.Set(ctx, "c", []byte("3"), time.Hour))
+
+		_, ok, _ := cache.Get(ctx, "b")
+		assert.False(t, ok)
+		_, ok, _ = cache.Get(ctx, "a")
+		assert.True(t, ok)
+		_, ok, _ = cache.Get(ctx, "c")
+		assert.True(t, ok)
+	})
+
+	t.Run("should delete entries", func(t *testing.T) {
+		cache := repository.NewLRUCache(10)
+
+		assert.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Hour))
+		assert.NoError(t, cache.Delete(ctx, "a", "missing"))
+
+		_, ok, _ := cache.Get(ctx, "a")
+		assert.False(t, ok)
+	})
+}
+
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	cache := repository.NewRedisCache(client, "notes:")
+
+	t.Run("should return cached values until they expire", func(t *testing.T) {
+		assert.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Minute))
+
+		value, ok, err := cache.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), value)
+
+		server.FastForward(2 * time.Minute)
+
+		_, ok, err = cache.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("should prefix keys", func(t *testing.T) {
+		assert.NoError(t, cache.Set(ctx, "b", []byte("2"), time.Minute))
+
+		value, err := server.Get("notes:b")
+		assert.NoError(t, err)
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("should delete entries", func(t *testing.T) {
+		assert.NoError(t, cache.Set(ctx, "c", []byte("3"), time.Minute))
+		assert.NoError(t, cache.Delete(ctx, "c", "missing"))
+
+		_, ok, err := cache.Get(ctx, "c")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("should return errors if redis is unavailable", func(t *testing.T) {
+		server.SetError("unavailable")
+		defer server.SetError("")
+
+		_, _, err := cache.Get(ctx, "a")
+		assert.Error(t, err)
+	})
+}
+
+func TestCachingRepository(t *testing.T) {
+	ctx := context.Background()
+
+	newNote := func() *repository.Note {
+		updatedAt := time.Now().UTC()
+		return &repository.Note{
+			ID:          uuid.New(),
+			Title:       gofakeit.Sentence(3),
+			Description: gofakeit.Sentence(10),
+			CreatedAt:   updatedAt,
+			UpdatedAt:   &updatedAt,
+		}
+	}
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	caches := map[string]func() repository.Cache{
+		"lru":   func() repository.Cache { return repository.NewLRUCache(100) },
+		"redis": func() repository.Cache { return repository.NewRedisCache(client, uuid.NewString()+":") },
+	}
+
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			setup := func(t *testing.T) (*repository.MockRepository, repository.Repository) {
+				mockRepo := repository.NewMockRepository(gomock.NewController(t))
+				return mockRepo, repository.NewCachingRepository(mockRepo, newCache(), time.Minute)
+			}
+
+			t.Run("should only fetch a note once", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+				mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(note, nil).Times(1)
+
+				for range 3 {
+					fetched, err := repo.FetchNoteByID(ctx, note.ID)
+					assert.NoError(t, err)
+					assert.Equal(t, note.ID, fetched.ID)
+					assert.Equal(t, note.Title, fetched.Title)
+					assert.WithinDuration(t, *note.UpdatedAt, *fetched.UpdatedAt, 0)
+				}
+			})
+
+			t.Run("should serve projections from the cached note", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+				mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(note, nil).Times(1)
+
+				_, err := repo.FetchNoteByID(ctx, note.ID, repository.NoteFieldTitle)
+				assert.NoError(t, err)
+
+				fetched, err := repo.FetchNoteByID(ctx, note.ID, repository.NoteFieldID, repository.NoteFieldDescription)
+				assert.NoError(t, err)
+				assert.Equal(t, note.Project(repository.NoteFieldID, repository.NoteFieldDescription), *fetched)
+			})
+
+			t.Run("should not cache missing notes", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				id := uuid.New()
+				mockRepo.EXPECT().FetchNoteByID(gomock.Any(), id).Return(nil, sql.ErrNoRows).Times(2)
+
+				for range 2 {
+					_, err := repo.FetchNoteByID(ctx, id)
+					assert.ErrorIs(t, err, sql.ErrNoRows)
+				}
+			})
+
+			t.Run("should refetch notes once they are updated", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+				description := gofakeit.Sentence(10)
+				updated := *note
+				updated.Description = description
+
+				gomock.InOrder(
+					mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(note, nil),
+					mockRepo.EXPECT().UpdateNote(gomock.Any(), note.ID, repository.UpdateNoteDTO{Description: &description}).Return(&updated, nil),
+					mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(&updated, nil),
+				)
+
+				_, err := repo.FetchNoteByID(ctx, note.ID)
+				assert.NoError(t, err)
+
+				_, err = repo.UpdateNote(ctx, note.ID, repository.UpdateNoteDTO{Description: &description})
+				assert.NoError(t, err)
+
+				fetched, err := repo.FetchNoteByID(ctx, note.ID)
+				assert.NoError(t, err)
+				assert.Equal(t, description, fetched.Description)
+			})
+
+			t.Run("should stop serving notes once they are deleted", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+
+				gomock.InOrder(
+					mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(note, nil),
+					mockRepo.EXPECT().DeleteNote(gomock.Any(), note.ID).Return(nil),
+					mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(nil, sql.ErrNoRows),
+				)
+
+				_, err := repo.FetchNoteByID(ctx, note.ID)
+				assert.NoError(t, err)
+
+				assert.NoError(t, repo.DeleteNote(ctx, note.ID))
+
+				_, err = repo.FetchNoteByID(ctx, note.ID)
+				assert.ErrorIs(t, err, sql.ErrNoRows)
+			})
+
+			t.Run("should refetch notes once they are upserted", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+				dto := repository.CreateNoteDTO{Title: note.Title, Description: gofakeit.Sentence(10), OnConflict: repository.ConflictUpsert}
+				upserted := *note
+				upserted.Description = dto.Description
+
+				gomock.InOrder(
+					mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(note, nil),
+					mockRepo.EXPECT().CreateNote(gomock.Any(), dto).Return(&upserted, nil),
+					mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(&upserted, nil),
+				)
+
+				_, err := repo.FetchNoteByID(ctx, note.ID)
+				assert.NoError(t, err)
+
+				_, err = repo.CreateNote(ctx, dto)
+				assert.NoError(t, err)
+
+				fetched, err := repo.FetchNoteByID(ctx, note.ID)
+				assert.NoError(t, err)
+				assert.Equal(t, dto.Description, fetched.Description)
+			})
+
+			t.Run("should collapse concurrent misses into a single fetch", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+
+				release := make(chan struct{})
+				mockRepo.EXPECT().
+					FetchNoteByID(gomock.Any(), note.ID).
+					DoAndReturn(func(context.Context, uuid.UUID, ...repository.NoteField) (*repository.Note, error) {
+						<-release
+						return note, nil
+					}).
+					Times(1)
+
+				var wg sync.WaitGroup
+				for range 10 {
+					wg.Go(func() {
+						fetched, err := repo.FetchNoteByID(ctx, note.ID)
+						assert.NoError(t, err)
+						assert.Equal(t, note.ID, fetched.ID)
+					})
+				}
+
+				// Give every fetch time to join the first one
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+				wg.Wait()
+			})
+
+			t.Run("should not cache a note fetched before it was updated", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+				description := gofakeit.Sentence(10)
+				updated := *note
+				updated.Description = description
+
+				release := make(chan struct{})
+				gomock.InOrder(
+					mockRepo.EXPECT().
+						FetchNoteByID(gomock.Any(), note.ID).
+						DoAndReturn(func(context.Context, uuid.UUID, ...repository.NoteField) (*repository.Note, error) {
+							<-release
+							return note, nil
+						}),
+					mockRepo.EXPECT().UpdateNote(gomock.Any(), note.ID, gomock.Any()).Return(&updated, nil),
+					mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(&updated, nil),
+				)
+
+				fetched := make(chan *repository.Note)
+				go func() {
+					note, _ := repo.FetchNoteByID(ctx, note.ID)
+					fetched <- note
+				}()
+
+				time.Sleep(50 * time.Millisecond)
+				_, err := repo.UpdateNote(ctx, note.ID, repository.UpdateNoteDTO{Description: &description})
+				assert.NoError(t, err)
+				close(release)
+				assert.Equal(t, note.Description, (<-fetched).Description)
+
+				refetched, err := repo.FetchNoteByID(ctx, note.ID)
+				assert.NoError(t, err)
+				assert.Equal(t, description, refetched.Description)
+			})
+
+			t.Run("should stop waiting once the context is done", func(t *testing.T) {
+				mockRepo, repo := setup(t)
+				note := newNote()
+
+				release := make(chan struct{})
+				defer close(release)
+				mockRepo.EXPECT().
+					FetchNoteByID(gomock.Any(), note.ID).
+					DoAndReturn(func(context.Context, uuid.UUID, ...repository.NoteField) (*repository.Note, error) {
+						<-release
+						return note, nil
+					})
+
+				ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+				defer cancel()
+
+				_, err := repo.FetchNoteByID(ctx, note.ID)
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			})
+		})
+	}
+
+	t.Run("should fall back to the repository if the cache is unavailable", func(t *testing.T) {
+		broken := miniredis.RunT(t)
+		broken.SetError("unavailable")
+		client := redis.NewClient(&redis.Options{Addr: broken.Addr()})
+		defer client.Close()
+
+		mockRepo := repository.NewMockRepository(gomock.NewController(t))
+		repo := repository.NewCachingRepository(mockRepo, repository.NewRedisCache(client, ""), time.Minute)
+
+		note := newNote()
+		mockRepo.EXPECT().FetchNoteByID(gomock.Any(), note.ID).Return(note, nil).Times(2)
+		mockRepo.EXPECT().DeleteNote(gomock.Any(), note.ID).Return(nil)
+
+		for range 2 {
+			fetched, err := repo.FetchNoteByID(ctx, note.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, note.ID, fetched.ID)
+		}
+		assert.NoError(t, repo.DeleteNote(ctx, note.ID))
+	})
+}
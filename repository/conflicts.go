@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/the-code-genin/golang_integration_testing/repository/internal/sqlc"
+)
+
+func (r *repository) FetchTitleConflicts(ctx context.Context) ([]TitleConflict, error) {
+	rows, err := queries.FetchTitleConflicts(ctx, r.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []TitleConflict{}
+	for _, row := range rows {
+		note := noteOf(sqlc.CoreNote{
+			ID:          row.ID,
+			Title:       row.Title,
+			Description: row.Description,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+		})
+
+		if len(conflicts) == 0 || conflicts[len(conflicts)-1].NormalizedTitle != row.NormalizedTitle {
+			conflicts = append(conflicts, TitleConflict{NormalizedTitle: row.NormalizedTitle})
+		}
+		last := &conflicts[len(conflicts)-1]
+		last.Notes = append(last.Notes, note)
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/the-code-genin/golang_integration_testing/repository/internal/sqlc"
+)
+
+// Returned when a write violates a unique constraint.
+type DuplicateKeyError struct {
+	Constraint string
+
+	// The note already holding the key, or uuid.Nil if it couldn't be found
+	ConflictingID uuid.UUID
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key error: %s", e.Constraint)
+}
+
+// Converts unique constraint violations into a DuplicateKeyError, looking up
+// the note that already has the title. Other errors are returned as is.
+func (r *repository) duplicateKeyError(ctx context.Context, err error, title string, excludeID uuid.UUID) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+
+	return r.titleTakenError(ctx, pgErr.ConstraintName, title, excludeID)
+}
+
+// The unique index titles are taken under.
+const noteTitleConstraint = "notes_unique_normalized_title_index"
+
+// Returns a DuplicateKeyError for the constraint, looking up the note other than excludeID
+// that has the title.
+func (r *repository) titleTakenError(ctx context.Context, constraint, title string, excludeID uuid.UUID) error {
+	dupErr := &DuplicateKeyError{Constraint: constraint}
+
+	id, lookupErr := queries.FetchNoteIDByTitle(ctx, r.conn, sqlc.FetchNoteIDByTitleParams{Title: title, ExcludeID: excludeID})
+	if lookupErr == nil {
+		dupErr.ConflictingID = id
+	} else if !errors.Is(lookupErr, pgx.ErrNoRows) {
+		return fmt.Errorf("%w (unable to find the conflicting note: %v)", dupErr, lookupErr)
+	}
+
+	return dupErr
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/the-code-genin/golang_integration_testing/repository/internal/sqlc"
+)
+
+const (
+	noteEventsChannel = "note_events"
+	// How many events the listener fetches at a time once notified
+	noteEventsPageSize = 100
+	// How often events held back behind running transactions are checked for again, as those
+	// transactions may not record events of their own to be notified of
+	noteEventsRecheckInterval = 100 * time.Millisecond
+)
+
+// Notifications only wake the listener up, which then fetches every event released since the
+// last one it passed on, in the order FetchNoteEventsSince returns them.
+func (r *repository) ListenNoteEvents(ctx context.Context, listening func(), fn func(NoteEvent) error) error {
+	// LISTEN is bound to a session, so take a connection out of the pool for good
+	poolConn, err := r.conn.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+noteEventsChannel); err != nil {
+		return err
+	}
+
+	// Events released before listening are left for callers to fetch
+	lastID, err := queries.FetchLastReleasedNoteEventID(ctx, r.conn)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	listening()
+
+	for {
+		for {
+			events, err := r.FetchNoteEventsSince(ctx, lastID, noteEventsPageSize)
+			if err != nil {
+				return r.listenError(ctx, err)
+			}
+			for _, event := range events {
+				if err := fn(event); err != nil {
+					return err
+				}
+				lastID = event.ID
+			}
+			if len(events) < noteEventsPageSize {
+				break
+			}
+		}
+
+		heldBack, err := queries.HasHeldBackNoteEvents(ctx, r.conn)
+		if err != nil {
+			return r.listenError(ctx, err)
+		}
+
+		waitCtx, cancel := ctx, context.CancelFunc(func() {})
+		if heldBack {
+			waitCtx, cancel = context.WithTimeout(ctx, noteEventsRecheckInterval)
+		}
+		_, err = conn.WaitForNotification(waitCtx)
+		cancel()
+		if err != nil && !(heldBack && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded)) {
+			return r.listenError(ctx, err)
+		}
+	}
+}
+
+// Returns nil if the listener stopped as ctx is done, or err otherwise.
+func (r *repository) listenError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (r *repository) FetchNoteEventsSince(ctx context.Context, afterID int64, limit int) ([]NoteEvent, error) {
+	rows, err := queries.FetchNoteEventsSince(ctx, r.conn, sqlc.FetchNoteEventsSinceParams{AfterID: afterID, PageSize: int32(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	var events []NoteEvent
+	for _, row := range rows {
+		event, err := eventOf(row)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (r *repository) DeleteNoteEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	return queries.DeleteNoteEventsBefore(ctx, r.conn, before)
+}
+
+// Converts a row of core.note_events into the event it records.
+func eventOf(row sqlc.CoreNoteEvent) (NoteEvent, error) {
+	event := NoteEvent{
+		ID: row.ID, Type: row.EventType, NoteID: row.NoteID, CreatedAt: row.CreatedAt, TransactionID: row.TransactionID,
+	}
+
+	// The payload is a JSON snapshot of the row, taken by the trigger
+	if err := json.Unmarshal(row.Payload, &event.Note); err != nil {
+		return NoteEvent{}, fmt.Errorf("invalid note event snapshot: %w", err)
+	}
+
+	return event, nil
+}
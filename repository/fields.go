@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/the-code-genin/golang_integration_testing/repository/internal/sqlc"
+)
+
+// A field of a note, named after its column and JSON key.
+type NoteField string
+
+const (
+	NoteFieldID          NoteField = "id"
+	NoteFieldTitle       NoteField = "title"
+	NoteFieldDescription NoteField = "description"
+	NoteFieldFormat      NoteField = "format"
+	NoteFieldCreatedAt   NoteField = "created_at"
+	NoteFieldUpdatedAt   NoteField = "updated_at"
+)
+
+var NoteFields = []NoteField{
+	NoteFieldID, NoteFieldTitle, NoteFieldDescription, NoteFieldFormat, NoteFieldCreatedAt, NoteFieldUpdatedAt,
+}
+
+// Parses a comma separated list of note fields, e.g. "id,title,updated_at".
+func ParseNoteFields(value string) ([]NoteField, error) {
+	fields := []NoteField{}
+	for name := range strings.SplitSeq(value, ",") {
+		field := NoteField(strings.TrimSpace(name))
+		if !slices.Contains(NoteFields, field) {
+			return nil, fmt.Errorf("unknown note field %q", field)
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// Returns the columns to select for the fields given, all of them if none are.
+func noteColumns(fields []NoteField) ([]string, error) {
+	if len(fields) == 0 {
+		fields = NoteFields
+	}
+
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		// Only whitelisted field names are ever interpolated
+		if !slices.Contains(NoteFields, field) {
+			return nil, fmt.Errorf("unknown note field %q", field)
+		}
+		columns[i] = string(field)
+	}
+	return columns, nil
+}
+
+// Converts a row of core.notes into a note, with the fields the row was selected with.
+func noteOf(row sqlc.CoreNote) Note {
+	return Note{
+		ID:          row.ID,
+		Title:       row.Title,
+		Description: row.Description,
+		Format:      NoteFormat(row.Format),
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}
+}
+
+// Returns a copy of the note with only the fields given, which are the only
+// ones serialized. The note is returned whole if no fields are given.
+func (n Note) Project(fields ...NoteField) Note {
+	if len(fields) == 0 {
+		return n
+	}
+
+	projected := Note{fields: fields}
+	for _, field := range fields {
+		switch field {
+		case NoteFieldID:
+			projected.ID = n.ID
+		case NoteFieldTitle:
+			projected.Title = n.Title
+		case NoteFieldDescription:
+			projected.Description = n.Description
+		case NoteFieldFormat:
+			projected.Format = n.Format
+		case NoteFieldCreatedAt:
+			projected.CreatedAt = n.CreatedAt
+		case NoteFieldUpdatedAt:
+			projected.UpdatedAt = n.UpdatedAt
+		}
+	}
+	return projected
+}
+
+// Returns the value of a field of the note, as it is serialized.
+func (n Note) Value(field NoteField) any {
+	switch field {
+	case NoteFieldID:
+		return n.ID
+	case NoteFieldTitle:
+		return n.Title
+	case NoteFieldDescription:
+		return n.Description
+	case NoteFieldFormat:
+		return n.Format
+	case NoteFieldCreatedAt:
+		return n.CreatedAt
+	case NoteFieldUpdatedAt:
+		return n.UpdatedAt
+	}
+	return nil
+}
+
+// Only serializes the fields the note was projected onto, if it was.
+func (n Note) MarshalJSON() ([]byte, error) {
+	// Has Note's fields and tags, without its methods
+	type note Note
+	if n.fields == nil {
+		return json.Marshal(note(n))
+	}
+
+	projection := make(map[NoteField]any, len(n.fields))
+	for _, field := range n.fields {
+		projection[field] = n.Value(field)
+	}
+	return json.Marshal(projection)
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The fields notes can be sorted by, mapped to the columns they sort on.
+var sortableNoteFields = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"title":      "core.normalize_title(title)",
+}
+
+// Sorts notes by a field, ascending unless Descending is set.
+type NoteSort struct {
+	Field      string
+	Descending bool
+}
+
+// Narrows down and orders the notes fetched. The zero value fetches every note, oldest first.
+type NoteFilter struct {
+	// Time ranges, each inclusive of its start and exclusive of its end
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	// Matched case-insensitively against the start of the title
+	TitlePrefix string
+	// Matched case-insensitively anywhere in the title or description
+	Contains string
+
+	// Applied in order, with ties broken by creation time and then ID
+	Sort []NoteSort
+
+	// Only fetches the notes sorted after the note at this position, so pages of notes
+	// can be fetched without skipping or repeating any, even if the note was deleted since
+	After *NoteCursor
+	// The most notes to fetch, every note if 0
+	Limit int
+}
+
+// Reports whether the filter is the zero value, fetching every note oldest first, as
+// StreamNotes streams them.
+func (f NoteFilter) IsZero() bool {
+	return f.CreatedAfter == nil && f.CreatedBefore == nil && f.UpdatedAfter == nil && f.UpdatedBefore == nil &&
+		f.TitlePrefix == "" && f.Contains == "" && len(f.Sort) == 0 && f.After == nil && f.Limit == 0
+}
+
+// The position of a note in the order notes are sorted in, whatever they are sorted by.
+type NoteCursor struct {
+	ID        uuid.UUID  `json:"id"`
+	Title     string     `json:"title"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Returns the position of the note, which must have been fetched with every field.
+func CursorOf(note Note) NoteCursor {
+	return NoteCursor{ID: note.ID, Title: note.Title, CreatedAt: note.CreatedAt, UpdatedAt: note.UpdatedAt}
+}
+
+// Parses a comma separated list of fields, each prefixed with - to sort descending,
+// e.g. "-updated_at,title".
+func ParseNoteSort(value string) ([]NoteSort, error) {
+	sorts := []NoteSort{}
+	for field := range strings.SplitSeq(value, ",") {
+		field = strings.TrimSpace(field)
+		descending := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+
+		if _, ok := sortableNoteFields[field]; !ok {
+			return nil, fmt.Errorf("unknown sort field %q", field)
+		}
+		sorts = append(sorts, NoteSort{Field: field, Descending: descending})
+	}
+	return sorts, nil
+}
+
+// Returns the names of the fields notes can be sorted by.
+func SortableNoteFields() []string {
+	return slices.Sorted(maps.Keys(sortableNoteFields))
+}
+
+// Escapes the LIKE wildcards in s, so it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// A column notes are sorted by, and the value of the cursor's note for it.
+type sortKey struct {
+	column     string
+	descending bool
+	// The expression the cursor's value is compared with, given its parameter
+	value string
+	// nil if the cursor's note has no value for a nullable column
+	cursor   any
+	nullable bool
+}
+
+// Returns the columns notes are sorted by, tie breakers included, with the cursor's values.
+func (f NoteFilter) sortKeys() ([]sortKey, error) {
+	var cursor NoteCursor
+	if f.After != nil {
+		cursor = *f.After
+	}
+
+	keys := make([]sortKey, 0, len(f.Sort)+2)
+	for _, sort := range f.Sort {
+		column, ok := sortableNoteFields[sort.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q", sort.Field)
+		}
+
+		key := sortKey{column: column, descending: sort.Descending, value: "$%d"}
+		switch sort.Field {
+		case "created_at":
+			key.cursor = cursor.CreatedAt
+		case "updated_at":
+			key.nullable = true
+			if cursor.UpdatedAt != nil {
+				key.cursor = *cursor.UpdatedAt
+			}
+		case "title":
+			key.cursor, key.value = cursor.Title, "core.normalize_title($%d::TEXT)"
+		}
+		keys = append(keys, key)
+	}
+
+	return append(keys,
+		sortKey{column: "created_at", value: "$%d", cursor: cursor.CreatedAt},
+		sortKey{column: "id", value: "$%d", cursor: cursor.ID},
+	), nil
+}
+
+// Compiles the filter into WHERE and ORDER BY clauses, the latter followed by LIMIT if
+// the filter has one, appending their parameters to args. Only whitelisted column names
+// are ever interpolated.
+func (f NoteFilter) compile(args []any) (where, orderBy string, _ []any, err error) {
+	conditions := []string{}
+	addCondition := func(format string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if f.CreatedAfter != nil {
+		addCondition("created_at >= $%d", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		addCondition("created_at < $%d", *f.CreatedBefore)
+	}
+	if f.UpdatedAfter != nil {
+		addCondition("updated_at >= $%d", *f.UpdatedAfter)
+	}
+	if f.UpdatedBefore != nil {
+		addCondition("updated_at < $%d", *f.UpdatedBefore)
+	}
+	if f.TitlePrefix != "" {
+		addCondition("title ILIKE $%d || '%%'", escapeLike(f.TitlePrefix))
+	}
+	if f.Contains != "" {
+		args = append(args, escapeLike(f.Contains))
+		conditions = append(conditions, fmt.Sprintf(
+			"(title ILIKE '%%' || $%[1]d || '%%' OR description ILIKE '%%' || $%[1]d || '%%')", len(args),
+		))
+	}
+
+	keys, err := f.sortKeys()
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if f.After != nil {
+		var condition string
+		condition, args = keysetCondition(keys, args)
+		conditions = append(conditions, condition)
+	}
+
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	orderings := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if key.descending {
+			direction = "DESC"
+		}
+		orderings[i] = key.column + " " + direction
+	}
+	orderBy = "ORDER BY " + strings.Join(orderings, ", ")
+
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		orderBy += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return where, orderBy, args, nil
+}
+
+// Matches the notes sorted after the cursor: those equal to it on every key before one
+// they are sorted after it on. NULLs sort last ascending and first descending, as in Postgres.
+func keysetCondition(keys []sortKey, args []any) (string, []any) {
+	equal := make([]string, len(keys))
+	after := make([]string, len(keys))
+	for i, key := range keys {
+		if key.nullable && key.cursor == nil {
+			equal[i] = key.column + " IS NULL"
+			if key.descending {
+				after[i] = key.column + " IS NOT NULL"
+			} else {
+				after[i] = "FALSE"
+			}
+			continue
+		}
+
+		args = append(args, key.cursor)
+		value := fmt.Sprintf(key.value, len(args))
+		equal[i] = key.column + " = " + value
+
+		switch {
+		case key.descending:
+			after[i] = key.column + " < " + value
+		case key.nullable:
+			after[i] = "(" + key.column + " > " + value + " OR " + key.column + " IS NULL)"
+		default:
+			after[i] = key.column + " > " + value
+		}
+	}
+
+	alternatives := make([]string, len(keys))
+	for i := range keys {
+		alternatives[i] = "(" + strings.Join(append(slices.Clone(equal[:i]), after[i]), " AND ") + ")"
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
@@ -4,6 +4,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,16 +14,45 @@ type Repository interface {
 	UpdateNote(ctx context.Context, id uuid.UUID, dto UpdateNoteDTO) (*Note, error)
 	DeleteNote(ctx context.Context, id uuid.UUID) error
 
-	FetchNotes(ctx context.Context) ([]Note, error)
-	FetchNoteByID(ctx context.Context, id uuid.UUID) (*Note, error)
+	// Only select the fields given, or every field if none are
+	FetchNotes(ctx context.Context, filter NoteFilter, fields ...NoteField) ([]Note, error)
+	FetchNoteByID(ctx context.Context, id uuid.UUID, fields ...NoteField) (*Note, error)
+	// Cheaply summarizes the notes matching the filter, to tell if they changed
+	FetchNotesVersion(ctx context.Context, filter NoteFilter) (*NotesVersion, error)
+	FetchNotesByIDs(ctx context.Context, ids []uuid.UUID) ([]Note, error)
+	// Calls fn for every note, oldest first, fetching them a page at a time.
+	// Stops at the first error fn returns, and returns it.
+	StreamNotes(ctx context.Context, fn func(Note) error) error
+	CountNotesByOwner(ctx context.Context, owner string) (int, error)
+
+	// Blocks until ctx is done, calling fn for every note event committed in the meantime.
+	// Calls listening once events are listened for, so they can be replayed without a gap.
+	ListenNoteEvents(ctx context.Context, listening func(), fn func(NoteEvent) error) error
+	// Fetches at most limit events after the ID given. IDs are taken in the order
+	// events commit, so no event committed later can have a lower ID.
+	FetchNoteEventsSince(ctx context.Context, afterID int64, limit int) ([]NoteEvent, error)
+	// Deletes the events recorded before the time given, returning how many were deleted.
+	DeleteNoteEventsBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// Finds notes whose titles are equal once normalized, which can exist in
+	// databases populated before titles were compared normalized.
+	FetchTitleConflicts(ctx context.Context) ([]TitleConflict, error)
 }
 
 type CreateNoteDTO struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description" binding:"required"`
+	Title       string `json:"title" binding:"required" openapi:"minLength=1,maxLength=255,pattern=\\S"`
+	Description string `json:"description" binding:"required" openapi:"minLength=1,maxLength=65535"`
+	// How the description is written, NoteFormatPlain if empty
+	Format NoteFormat `json:"format"`
+
+	// How to resolve a title held by another note, ConflictError if empty
+	OnConflict ConflictStrategy `json:"-"`
+	// The client creating the note, if known. Upserts keep the owner of the note updated.
+	Owner string `json:"-"`
 }
 
 type UpdateNoteDTO struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
+	Title       *string     `json:"title" openapi:"minLength=1,maxLength=255,pattern=\\S"`
+	Description *string     `json:"description" openapi:"maxLength=65535"`
+	Format      *NoteFormat `json:"format"`
 }
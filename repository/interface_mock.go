@@ -12,6 +12,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	uuid "github.com/google/uuid"
 	gomock "go.uber.org/mock/gomock"
@@ -40,6 +41,21 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CountNotesByOwner mocks base method.
+func (m *MockRepository) CountNotesByOwner(ctx context.Context, owner string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountNotesByOwner", ctx, owner)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountNotesByOwner indicates an expected call of CountNotesByOwner.
+func (mr *MockRepositoryMockRecorder) CountNotesByOwner(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountNotesByOwner", reflect.TypeOf((*MockRepository)(nil).CountNotesByOwner), ctx, owner)
+}
+
 // CreateNote mocks base method.
 func (m *MockRepository) CreateNote(ctx context.Context, dto CreateNoteDTO) (*Note, error) {
 	m.ctrl.T.Helper()
@@ -69,34 +85,147 @@ func (mr *MockRepositoryMockRecorder) DeleteNote(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNote", reflect.TypeOf((*MockRepository)(nil).DeleteNote), ctx, id)
 }
 
+// DeleteNoteEventsBefore mocks base method.
+func (m *MockRepository) DeleteNoteEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteNoteEventsBefore", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteNoteEventsBefore indicates an expected call of DeleteNoteEventsBefore.
+func (mr *MockRepositoryMockRecorder) DeleteNoteEventsBefore(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNoteEventsBefore", reflect.TypeOf((*MockRepository)(nil).DeleteNoteEventsBefore), ctx, before)
+}
+
 // FetchNoteByID mocks base method.
-func (m *MockRepository) FetchNoteByID(ctx context.Context, id uuid.UUID) (*Note, error) {
+func (m *MockRepository) FetchNoteByID(ctx context.Context, id uuid.UUID, fields ...NoteField) (*Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FetchNoteByID", ctx, id)
+	varargs := []any{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FetchNoteByID", varargs...)
 	ret0, _ := ret[0].(*Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FetchNoteByID indicates an expected call of FetchNoteByID.
-func (mr *MockRepositoryMockRecorder) FetchNoteByID(ctx, id any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FetchNoteByID(ctx, id any, fields ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNoteByID", reflect.TypeOf((*MockRepository)(nil).FetchNoteByID), ctx, id)
+	varargs := append([]any{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNoteByID", reflect.TypeOf((*MockRepository)(nil).FetchNoteByID), varargs...)
+}
+
+// FetchNoteEventsSince mocks base method.
+func (m *MockRepository) FetchNoteEventsSince(ctx context.Context, afterID int64, limit int) ([]NoteEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchNoteEventsSince", ctx, afterID, limit)
+	ret0, _ := ret[0].([]NoteEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchNoteEventsSince indicates an expected call of FetchNoteEventsSince.
+func (mr *MockRepositoryMockRecorder) FetchNoteEventsSince(ctx, afterID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNoteEventsSince", reflect.TypeOf((*MockRepository)(nil).FetchNoteEventsSince), ctx, afterID, limit)
 }
 
 // FetchNotes mocks base method.
-func (m *MockRepository) FetchNotes(ctx context.Context) ([]Note, error) {
+func (m *MockRepository) FetchNotes(ctx context.Context, filter NoteFilter, fields ...NoteField) ([]Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FetchNotes", ctx)
+	varargs := []any{ctx, filter}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FetchNotes", varargs...)
 	ret0, _ := ret[0].([]Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FetchNotes indicates an expected call of FetchNotes.
-func (mr *MockRepositoryMockRecorder) FetchNotes(ctx any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FetchNotes(ctx, filter any, fields ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, filter}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotes", reflect.TypeOf((*MockRepository)(nil).FetchNotes), varargs...)
+}
+
+// FetchNotesByIDs mocks base method.
+func (m *MockRepository) FetchNotesByIDs(ctx context.Context, ids []uuid.UUID) ([]Note, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchNotesByIDs", ctx, ids)
+	ret0, _ := ret[0].([]Note)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchNotesByIDs indicates an expected call of FetchNotesByIDs.
+func (mr *MockRepositoryMockRecorder) FetchNotesByIDs(ctx, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotesByIDs", reflect.TypeOf((*MockRepository)(nil).FetchNotesByIDs), ctx, ids)
+}
+
+// FetchNotesVersion mocks base method.
+func (m *MockRepository) FetchNotesVersion(ctx context.Context, filter NoteFilter) (*NotesVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchNotesVersion", ctx, filter)
+	ret0, _ := ret[0].(*NotesVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchNotesVersion indicates an expected call of FetchNotesVersion.
+func (mr *MockRepositoryMockRecorder) FetchNotesVersion(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotesVersion", reflect.TypeOf((*MockRepository)(nil).FetchNotesVersion), ctx, filter)
+}
+
+// FetchTitleConflicts mocks base method.
+func (m *MockRepository) FetchTitleConflicts(ctx context.Context) ([]TitleConflict, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchTitleConflicts", ctx)
+	ret0, _ := ret[0].([]TitleConflict)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchTitleConflicts indicates an expected call of FetchTitleConflicts.
+func (mr *MockRepositoryMockRecorder) FetchTitleConflicts(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchTitleConflicts", reflect.TypeOf((*MockRepository)(nil).FetchTitleConflicts), ctx)
+}
+
+// ListenNoteEvents mocks base method.
+func (m *MockRepository) ListenNoteEvents(ctx context.Context, listening func(), fn func(NoteEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenNoteEvents", ctx, listening, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListenNoteEvents indicates an expected call of ListenNoteEvents.
+func (mr *MockRepositoryMockRecorder) ListenNoteEvents(ctx, listening, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenNoteEvents", reflect.TypeOf((*MockRepository)(nil).ListenNoteEvents), ctx, listening, fn)
+}
+
+// StreamNotes mocks base method.
+func (m *MockRepository) StreamNotes(ctx context.Context, fn func(Note) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamNotes", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamNotes indicates an expected call of StreamNotes.
+func (mr *MockRepositoryMockRecorder) StreamNotes(ctx, fn any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotes", reflect.TypeOf((*MockRepository)(nil).FetchNotes), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamNotes", reflect.TypeOf((*MockRepository)(nil).StreamNotes), ctx, fn)
 }
 
 // UpdateNote mocks base method.
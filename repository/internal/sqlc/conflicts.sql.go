@@ -0,0 +1,60 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.31.1
+// source: conflicts.sql
+
+package sqlc
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const FetchTitleConflicts = `-- name: FetchTitleConflicts :many
+SELECT lower(normalize(btrim(title), NFKC))::TEXT AS normalized_title, id, title, description, created_at, updated_at
+FROM core.notes
+WHERE lower(normalize(btrim(title), NFKC)) IN (
+    SELECT lower(normalize(btrim(title), NFKC)) FROM core.notes GROUP BY 1 HAVING COUNT(*) > 1
+)
+ORDER BY normalized_title, created_at, id
+`
+
+type FetchTitleConflictsRow struct {
+	NormalizedTitle string
+	ID              uuid.UUID
+	Title           string
+	Description     string
+	CreatedAt       time.Time
+	UpdatedAt       *time.Time
+}
+
+// The expression is inlined rather than calling core.normalize_title,
+// so conflicts can be reported before that migration is applied
+func (q *Queries) FetchTitleConflicts(ctx context.Context, db DBTX) ([]FetchTitleConflictsRow, error) {
+	rows, err := db.Query(ctx, FetchTitleConflicts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []FetchTitleConflictsRow{}
+	for rows.Next() {
+		var i FetchTitleConflictsRow
+		if err := rows.Scan(
+			&i.NormalizedTitle,
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,103 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.31.1
+// source: events.sql
+
+package sqlc
+
+import (
+	"context"
+	"time"
+)
+
+const DeleteNoteEventsBefore = `-- name: DeleteNoteEventsBefore :execrows
+DELETE FROM core.note_events
+WHERE created_at < $1::TIMESTAMPTZ
+`
+
+func (q *Queries) DeleteNoteEventsBefore(ctx context.Context, db DBTX, before time.Time) (int64, error) {
+	result, err := db.Exec(ctx, DeleteNoteEventsBefore, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const FetchLastReleasedNoteEventID = `-- name: FetchLastReleasedNoteEventID :one
+SELECT id FROM core.note_events
+WHERE transaction_id < pg_snapshot_xmin(pg_current_snapshot())
+ORDER BY transaction_id DESC, id DESC
+LIMIT 1
+`
+
+// The ID of the last event FetchNoteEventsSince would return, if it was given no limit
+func (q *Queries) FetchLastReleasedNoteEventID(ctx context.Context, db DBTX) (int64, error) {
+	row := db.QueryRow(ctx, FetchLastReleasedNoteEventID)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const FetchNoteEventsSince = `-- name: FetchNoteEventsSince :many
+WITH after AS (
+    SELECT transaction_id FROM core.note_events WHERE id = $1::BIGINT
+)
+SELECT e.id, e.event_type, e.note_id, e.payload, e.created_at, e.transaction_id FROM core.note_events e
+WHERE e.transaction_id < pg_snapshot_xmin(pg_current_snapshot())
+    AND (
+        (e.transaction_id, e.id) > ((SELECT transaction_id FROM after), $1::BIGINT)
+        -- The event may have been deleted since, leaving only its ID to go by
+        OR (NOT EXISTS (SELECT FROM after) AND e.id > $1::BIGINT)
+    )
+ORDER BY e.transaction_id, e.id
+LIMIT $2::INTEGER
+`
+
+type FetchNoteEventsSinceParams struct {
+	AfterID  int64
+	PageSize int32
+}
+
+// Events are ordered by the transaction recording them, then by ID, resuming after the event given.
+// Events of transactions that may still be running are held back, as those could yet commit events
+// ordered before them.
+func (q *Queries) FetchNoteEventsSince(ctx context.Context, db DBTX, arg FetchNoteEventsSinceParams) ([]CoreNoteEvent, error) {
+	rows, err := db.Query(ctx, FetchNoteEventsSince, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CoreNoteEvent{}
+	for rows.Next() {
+		var i CoreNoteEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventType,
+			&i.NoteID,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.TransactionID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const HasHeldBackNoteEvents = `-- name: HasHeldBackNoteEvents :one
+SELECT EXISTS (
+    SELECT FROM core.note_events WHERE transaction_id >= pg_snapshot_xmin(pg_current_snapshot())
+)
+`
+
+// Reports whether committed events are held back behind transactions that are still running
+func (q *Queries) HasHeldBackNoteEvents(ctx context.Context, db DBTX) (bool, error) {
+	row := db.QueryRow(ctx, HasHeldBackNoteEvents)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
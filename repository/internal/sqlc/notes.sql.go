@@ -0,0 +1,252 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.31.1
+// source: notes.sql
+
+package sqlc
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const CountNotesByOwner = `-- name: CountNotesByOwner :one
+SELECT COUNT(*) AS count FROM core.notes WHERE owner = $1::TEXT
+`
+
+func (q *Queries) CountNotesByOwner(ctx context.Context, db DBTX, owner string) (int64, error) {
+	row := db.QueryRow(ctx, CountNotesByOwner, owner)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const DeleteNote = `-- name: DeleteNote :exec
+DELETE FROM core.notes WHERE id = $1
+`
+
+func (q *Queries) DeleteNote(ctx context.Context, db DBTX, id uuid.UUID) error {
+	_, err := db.Exec(ctx, DeleteNote, id)
+	return err
+}
+
+const FetchNoteIDByTitle = `-- name: FetchNoteIDByTitle :one
+SELECT id FROM core.notes
+WHERE core.normalize_title(title) = core.normalize_title($1::TEXT) AND id <> $2
+LIMIT 1
+`
+
+type FetchNoteIDByTitleParams struct {
+	Title     string
+	ExcludeID uuid.UUID
+}
+
+func (q *Queries) FetchNoteIDByTitle(ctx context.Context, db DBTX, arg FetchNoteIDByTitleParams) (uuid.UUID, error) {
+	row := db.QueryRow(ctx, FetchNoteIDByTitle, arg.Title, arg.ExcludeID)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const FetchNotesByIDs = `-- name: FetchNotesByIDs :many
+SELECT id, title, description, created_at, updated_at, owner, format FROM core.notes
+WHERE id = ANY($1::UUID[])
+`
+
+func (q *Queries) FetchNotesByIDs(ctx context.Context, db DBTX, ids []uuid.UUID) ([]CoreNote, error) {
+	rows, err := db.Query(ctx, FetchNotesByIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CoreNote{}
+	for rows.Next() {
+		var i CoreNote
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Owner,
+			&i.Format,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const FetchNotesPage = `-- name: FetchNotesPage :many
+SELECT id, title, description, created_at, updated_at, owner, format FROM core.notes
+WHERE (created_at, id) > ($1::TIMESTAMPTZ, $2::UUID)
+ORDER BY created_at, id
+LIMIT $3::INTEGER
+`
+
+type FetchNotesPageParams struct {
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	PageSize       int32
+}
+
+// Pages through every note oldest first, starting after the note at the position given
+func (q *Queries) FetchNotesPage(ctx context.Context, db DBTX, arg FetchNotesPageParams) ([]CoreNote, error) {
+	rows, err := db.Query(ctx, FetchNotesPage, arg.AfterCreatedAt, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CoreNote{}
+	for rows.Next() {
+		var i CoreNote
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Owner,
+			&i.Format,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const InsertNote = `-- name: InsertNote :exec
+INSERT INTO core.notes (id, title, description, format, created_at, updated_at, owner)
+VALUES ($1, $2, $3, $4, $5, $5, NULLIF($6::TEXT, ''))
+`
+
+type InsertNoteParams struct {
+	ID          uuid.UUID
+	Title       string
+	Description string
+	Format      string
+	CreatedAt   time.Time
+	Owner       string
+}
+
+func (q *Queries) InsertNote(ctx context.Context, db DBTX, arg InsertNoteParams) error {
+	_, err := db.Exec(ctx, InsertNote,
+		arg.ID,
+		arg.Title,
+		arg.Description,
+		arg.Format,
+		arg.CreatedAt,
+		arg.Owner,
+	)
+	return err
+}
+
+const InsertNoteUnlessTitleTaken = `-- name: InsertNoteUnlessTitleTaken :execrows
+INSERT INTO core.notes (id, title, description, format, created_at, updated_at, owner)
+VALUES ($1, $2, $3, $4, $5, $5, NULLIF($6::TEXT, ''))
+ON CONFLICT ((core.normalize_title(title))) DO NOTHING
+`
+
+type InsertNoteUnlessTitleTakenParams struct {
+	ID          uuid.UUID
+	Title       string
+	Description string
+	Format      string
+	CreatedAt   time.Time
+	Owner       string
+}
+
+// Inserts nothing if another note holds the title
+func (q *Queries) InsertNoteUnlessTitleTaken(ctx context.Context, db DBTX, arg InsertNoteUnlessTitleTakenParams) (int64, error) {
+	result, err := db.Exec(ctx, InsertNoteUnlessTitleTaken,
+		arg.ID,
+		arg.Title,
+		arg.Description,
+		arg.Format,
+		arg.CreatedAt,
+		arg.Owner,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const UpdateNote = `-- name: UpdateNote :exec
+UPDATE core.notes
+SET updated_at = $1::TIMESTAMPTZ, title = COALESCE($2, title),
+    description = COALESCE($3, description), format = COALESCE($4, format)
+WHERE id = $5
+`
+
+type UpdateNoteParams struct {
+	UpdatedAt   time.Time
+	Title       *string
+	Description *string
+	Format      *string
+	ID          uuid.UUID
+}
+
+// Fields left NULL keep their value
+func (q *Queries) UpdateNote(ctx context.Context, db DBTX, arg UpdateNoteParams) error {
+	_, err := db.Exec(ctx, UpdateNote,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Description,
+		arg.Format,
+		arg.ID,
+	)
+	return err
+}
+
+const UpsertNote = `-- name: UpsertNote :one
+INSERT INTO core.notes (id, title, description, format, created_at, updated_at, owner)
+VALUES ($1, $2, $3, $4, $5, $5, NULLIF($6::TEXT, ''))
+ON CONFLICT ((core.normalize_title(title)))
+DO UPDATE SET description = EXCLUDED.description, format = EXCLUDED.format, updated_at = EXCLUDED.updated_at
+WHERE core.notes.owner IS NOT DISTINCT FROM EXCLUDED.owner
+RETURNING id, title, description, created_at, updated_at, owner, format
+`
+
+type UpsertNoteParams struct {
+	ID          uuid.UUID
+	Title       string
+	Description string
+	Format      string
+	CreatedAt   time.Time
+	Owner       string
+}
+
+// Updates the description and format of the note holding the title instead, if one does and has the
+// same owner. Returns no row if the note has another owner.
+func (q *Queries) UpsertNote(ctx context.Context, db DBTX, arg UpsertNoteParams) (CoreNote, error) {
+	row := db.QueryRow(ctx, UpsertNote,
+		arg.ID,
+		arg.Title,
+		arg.Description,
+		arg.Format,
+		arg.CreatedAt,
+		arg.Owner,
+	)
+	var i CoreNote
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Description,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Owner,
+		&i.Format,
+	)
+	return i, err
+}
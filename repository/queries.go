@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/the-code-genin/golang_integration_testing/repository/internal/sqlc"
+)
+
+// The queries are written in queries/*.sql, and their code generated into internal/sqlc by
+// sqlc, typed according to the schema the migrations leave behind. Queries whose columns,
+// conditions or order depend on how they are called are built by hand below.
+//go:generate go run github.com/sqlc-dev/sqlc/cmd/sqlc@v1.31.1 generate
+
+var queries = sqlc.New()
+
+// Every query whose SQL is fixed, prepared on each connection by PrepareQueries.
+var preparedQueries = []string{
+	sqlc.FetchTitleConflicts,
+	sqlc.FetchNoteEventsSince,
+	sqlc.FetchLastReleasedNoteEventID,
+	sqlc.HasHeldBackNoteEvents,
+	sqlc.DeleteNoteEventsBefore,
+	sqlc.InsertNote,
+	sqlc.InsertNoteUnlessTitleTaken,
+	sqlc.UpsertNote,
+	sqlc.UpdateNote,
+	sqlc.DeleteNote,
+	sqlc.FetchNotesPage,
+	sqlc.FetchNotesByIDs,
+	sqlc.CountNotesByOwner,
+	sqlc.FetchNoteIDByTitle,
+}
+
+// Prepares every query in preparedQueries on the connection, meant as a pool's AfterConnect
+// hook. Queries run by their SQL use the statements prepared for them.
+//
+// Queries that can't be prepared, e.g. as the database hasn't been migrated yet, are logged
+// and left for pgx to prepare when they are run, so the connection can still be used.
+func PrepareQueries(ctx context.Context, conn *pgx.Conn) error {
+	for _, sql := range preparedQueries {
+		if _, err := conn.Prepare(ctx, sql, sql); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Printf("unable to prepare query %s: %v", queryName(sql), err)
+		}
+	}
+	return nil
+}
+
+// Queries start with a comment naming them, as sqlc writes them.
+var queryNamePattern = regexp.MustCompile(`^-- name: (\w+)`)
+
+// Returns the name of the query, if it was run by the repository.
+func queryName(sql string) string {
+	if m := queryNamePattern.FindStringSubmatch(sql); m != nil {
+		return m[1]
+	}
+	return "unnamed"
+}
+
+// The databases the repository runs queries on, i.e. pools and transactions.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Returns the fields of the row to scan the columns given into, which must be columns of core.notes.
+func scanTargets(row *sqlc.CoreNote, columns []string) ([]any, error) {
+	targets := make([]any, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			targets[i] = &row.ID
+		case "title":
+			targets[i] = &row.Title
+		case "description":
+			targets[i] = &row.Description
+		case "created_at":
+			targets[i] = &row.CreatedAt
+		case "updated_at":
+			targets[i] = &row.UpdatedAt
+		case "owner":
+			targets[i] = &row.Owner
+		case "format":
+			targets[i] = &row.Format
+		default:
+			return nil, fmt.Errorf("unknown column %q of core.notes", column)
+		}
+	}
+	return targets, nil
+}
+
+// Fetches the columns of the notes matching where, which is built by NoteFilter.compile
+// along with orderBy and args. Only the columns selected are set.
+func fetchNotes(ctx context.Context, db querier, columns []string, where, orderBy string, args ...any) ([]sqlc.CoreNote, error) {
+	var row sqlc.CoreNote
+	targets, err := scanTargets(&row, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, "-- name: FetchNotes :many\nSELECT "+strings.Join(columns, ", ")+"\nFROM core.notes\n"+
+		where+"\n"+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []sqlc.CoreNote{}
+	for rows.Next() {
+		row = sqlc.CoreNote{}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+		items = append(items, row)
+	}
+	return items, rows.Err()
+}
+
+// Counts the notes matching where, built by NoteFilter.compile along with args, and
+// finds when they were last updated.
+func fetchNotesVersion(ctx context.Context, db querier, where string, args ...any) (count int64, lastUpdatedAt *time.Time, err error) {
+	err = db.QueryRow(ctx, "-- name: FetchNotesVersion :one\nSELECT COUNT(*) AS count, MAX(updated_at) AS last_updated_at\n"+
+		"FROM core.notes\n"+where, args...).Scan(&count, &lastUpdatedAt)
+	return count, lastUpdatedAt, err
+}
+
+// Fetches the columns of a note. Only the columns selected are set.
+func fetchNoteByID(ctx context.Context, db querier, columns []string, id uuid.UUID) (sqlc.CoreNote, error) {
+	var row sqlc.CoreNote
+	targets, err := scanTargets(&row, columns)
+	if err != nil {
+		return row, err
+	}
+	err = db.QueryRow(ctx, "-- name: FetchNoteByID :one\nSELECT "+strings.Join(columns, ", ")+"\nFROM core.notes\nWHERE id = $1", id).
+		Scan(targets...)
+	return row, err
+}
@@ -0,0 +1,9 @@
+-- name: FetchTitleConflicts :many
+-- The expression is inlined rather than calling core.normalize_title,
+-- so conflicts can be reported before that migration is applied
+SELECT lower(normalize(btrim(title), NFKC))::TEXT AS normalized_title, id, title, description, created_at, updated_at
+FROM core.notes
+WHERE lower(normalize(btrim(title), NFKC)) IN (
+    SELECT lower(normalize(btrim(title), NFKC)) FROM core.notes GROUP BY 1 HAVING COUNT(*) > 1
+)
+ORDER BY normalized_title, created_at, id;
@@ -0,0 +1,33 @@
+-- name: FetchNoteEventsSince :many
+-- Events are ordered by the transaction recording them, then by ID, resuming after the event given.
+-- Events of transactions that may still be running are held back, as those could yet commit events
+-- ordered before them.
+WITH after AS (
+    SELECT transaction_id FROM core.note_events WHERE id = @after_id::BIGINT
+)
+SELECT e.* FROM core.note_events e
+WHERE e.transaction_id < pg_snapshot_xmin(pg_current_snapshot())
+    AND (
+        (e.transaction_id, e.id) > ((SELECT transaction_id FROM after), @after_id::BIGINT)
+        -- The event may have been deleted since, leaving only its ID to go by
+        OR (NOT EXISTS (SELECT FROM after) AND e.id > @after_id::BIGINT)
+    )
+ORDER BY e.transaction_id, e.id
+LIMIT @page_size::INTEGER;
+
+-- name: FetchLastReleasedNoteEventID :one
+-- The ID of the last event FetchNoteEventsSince would return, if it was given no limit
+SELECT id FROM core.note_events
+WHERE transaction_id < pg_snapshot_xmin(pg_current_snapshot())
+ORDER BY transaction_id DESC, id DESC
+LIMIT 1;
+
+-- name: HasHeldBackNoteEvents :one
+-- Reports whether committed events are held back behind transactions that are still running
+SELECT EXISTS (
+    SELECT FROM core.note_events WHERE transaction_id >= pg_snapshot_xmin(pg_current_snapshot())
+);
+
+-- name: DeleteNoteEventsBefore :execrows
+DELETE FROM core.note_events
+WHERE created_at < @before::TIMESTAMPTZ;
@@ -0,0 +1,48 @@
+-- name: InsertNote :exec
+INSERT INTO core.notes (id, title, description, format, created_at, updated_at, owner)
+VALUES (@id, @title, @description, @format, @created_at, @created_at, NULLIF(@owner::TEXT, ''));
+
+-- name: InsertNoteUnlessTitleTaken :execrows
+-- Inserts nothing if another note holds the title
+INSERT INTO core.notes (id, title, description, format, created_at, updated_at, owner)
+VALUES (@id, @title, @description, @format, @created_at, @created_at, NULLIF(@owner::TEXT, ''))
+ON CONFLICT ((core.normalize_title(title))) DO NOTHING;
+
+-- name: UpsertNote :one
+-- Updates the description and format of the note holding the title instead, if one does and has the
+-- same owner. Returns no row if the note has another owner.
+INSERT INTO core.notes (id, title, description, format, created_at, updated_at, owner)
+VALUES (@id, @title, @description, @format, @created_at, @created_at, NULLIF(@owner::TEXT, ''))
+ON CONFLICT ((core.normalize_title(title)))
+DO UPDATE SET description = EXCLUDED.description, format = EXCLUDED.format, updated_at = EXCLUDED.updated_at
+WHERE core.notes.owner IS NOT DISTINCT FROM EXCLUDED.owner
+RETURNING *;
+
+-- name: UpdateNote :exec
+-- Fields left NULL keep their value
+UPDATE core.notes
+SET updated_at = @updated_at::TIMESTAMPTZ, title = COALESCE(sqlc.narg(title), title),
+    description = COALESCE(sqlc.narg(description), description), format = COALESCE(sqlc.narg(format), format)
+WHERE id = @id;
+
+-- name: DeleteNote :exec
+DELETE FROM core.notes WHERE id = @id;
+
+-- name: FetchNotesPage :many
+-- Pages through every note oldest first, starting after the note at the position given
+SELECT * FROM core.notes
+WHERE (created_at, id) > (@after_created_at::TIMESTAMPTZ, @after_id::UUID)
+ORDER BY created_at, id
+LIMIT @page_size::INTEGER;
+
+-- name: FetchNotesByIDs :many
+SELECT * FROM core.notes
+WHERE id = ANY(@ids::UUID[]);
+
+-- name: CountNotesByOwner :one
+SELECT COUNT(*) AS count FROM core.notes WHERE owner = @owner::TEXT;
+
+-- name: FetchNoteIDByTitle :one
+SELECT id FROM core.notes
+WHERE core.normalize_title(title) = core.normalize_title(@title::TEXT) AND id <> @exclude_id
+LIMIT 1;
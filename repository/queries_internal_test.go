@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/the-code-genin/golang_integration_testing/repository/internal/sqlc"
+)
+
+func TestPreparedQueries(t *testing.T) {
+	t.Run("should prepare every generated query", func(t *testing.T) {
+		files, err := filepath.Glob("internal/sqlc/*.sql.go")
+		require.NoError(t, err)
+		require.NotEmpty(t, files)
+
+		// sqlc declares each query as a constant named after it
+		var names []string
+		for _, file := range files {
+			f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+			require.NoError(t, err)
+			for _, decl := range f.Decls {
+				if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.CONST {
+					for _, spec := range decl.Specs {
+						for _, name := range spec.(*ast.ValueSpec).Names {
+							names = append(names, name.Name)
+						}
+					}
+				}
+			}
+		}
+
+		prepared := make([]string, len(preparedQueries))
+		for i, sql := range preparedQueries {
+			prepared[i] = queryName(sql)
+		}
+		slices.Sort(names)
+		slices.Sort(prepared)
+		assert.Equal(t, names, prepared)
+	})
+
+	t.Run("should name queries by their leading comment", func(t *testing.T) {
+		assert.Equal(t, "InsertNote", queryName(sqlc.InsertNote))
+		assert.Equal(t, "unnamed", queryName("SELECT 1"))
+	})
+}
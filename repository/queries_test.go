@@ -0,0 +1,25 @@
+package repository_test
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The sqlc version internal/sqlc is generated with, as pinned by go:generate.
+const sqlcVersion = "v1.31.1"
+
+func TestGeneratedQueries(t *testing.T) {
+	t.Run("should be up to date with the queries and migrations", func(t *testing.T) {
+		// An installed sqlc of the same version saves building it
+		cmd := exec.Command("go", "run", "github.com/sqlc-dev/sqlc/cmd/sqlc@"+sqlcVersion, "diff")
+		if out, err := exec.Command("sqlc", "version").Output(); err == nil && strings.TrimSpace(string(out)) == sqlcVersion {
+			cmd = exec.Command("sqlc", "diff")
+		}
+
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, "internal/sqlc is stale, run go generate -run sqlc ./repository\n%s", out)
+	})
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// How long a session's reads go to the primary after it writes, by default
+	defaultReadYourWritesWindow = 5 * time.Second
+	// How often unhealthy replicas are pinged to tell if they are back
+	replicaRecheckInterval = 5 * time.Second
+	// How often sessions that no longer read from the primary are forgotten
+	sessionPruneInterval = time.Minute
+)
+
+// Configures optional behaviour of the repository.
+type Option func(*repository)
+
+// Sends reads of notes to the replicas, in turn, rather than to the primary. Replicas that
+// can't be reached are skipped until they answer again, falling back to the primary.
+func WithReplicas(replicas ...*pgxpool.Pool) Option {
+	return func(r *repository) {
+		for _, pool := range replicas {
+			r.replicas = append(r.replicas, &replica{pool: pool})
+		}
+	}
+}
+
+// Sets how long a session's reads go to the primary after it writes, so it reads
+// its own writes even while replicas lag behind.
+func WithReadYourWritesWindow(window time.Duration) Option {
+	return func(r *repository) {
+		r.sessionWrites.window = window
+	}
+}
+
+type sessionKey struct{}
+
+// Returns a context for the session's queries, e.g. a client's, so it reads its own writes.
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+type primaryKey struct{}
+
+// Returns a context whose reads go to the primary.
+func onPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+type replica struct {
+	pool      *pgxpool.Pool
+	unhealthy atomic.Bool
+}
+
+// Stops reading from the replica, pinging it until it answers again.
+func (rep *replica) markUnhealthy(err error) {
+	if !rep.unhealthy.CompareAndSwap(false, true) {
+		return
+	}
+	log.Printf("replica %s is unavailable, reading from the primary: %v", rep.pool.Config().ConnConfig.Host, err)
+
+	go func() {
+		for {
+			time.Sleep(replicaRecheckInterval)
+			ctx, cancel := context.WithTimeout(context.Background(), replicaRecheckInterval)
+			err := rep.pool.Ping(ctx)
+			cancel()
+			if err == nil {
+				rep.unhealthy.Store(false)
+				return
+			}
+		}
+	}()
+}
+
+// Remembers which sessions wrote within the last window.
+type sessionWrites struct {
+	window time.Duration
+
+	mu         sync.Mutex
+	writtenAt  map[string]time.Time
+	lastPruned time.Time
+}
+
+func (s *sessionWrites) wrote(session string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget sessions once their window is over, so they don't hold on to memory
+	if now.Sub(s.lastPruned) >= sessionPruneInterval {
+		for session, writtenAt := range s.writtenAt {
+			if now.Sub(writtenAt) >= s.window {
+				delete(s.writtenAt, session)
+			}
+		}
+		s.lastPruned = now
+	}
+
+	if s.writtenAt == nil {
+		s.writtenAt = map[string]time.Time{}
+	}
+	s.writtenAt[session] = now
+}
+
+func (s *sessionWrites) recentlyWrote(session string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writtenAt, ok := s.writtenAt[session]
+	return ok && now.Sub(writtenAt) < s.window
+}
+
+// Records a write by the ctx's session, returning a context whose reads go to the primary.
+func (r *repository) wrote(ctx context.Context) context.Context {
+	if len(r.replicas) == 0 {
+		return ctx
+	}
+	if session, ok := ctx.Value(sessionKey{}).(string); ok {
+		r.sessionWrites.wrote(session, time.Now())
+	}
+	return onPrimary(ctx)
+}
+
+// Returns the next healthy replica to read from, or nil if reads must go to the primary.
+func (r *repository) replicaFor(ctx context.Context) *replica {
+	if len(r.replicas) == 0 || ctx.Value(primaryKey{}) != nil {
+		return nil
+	}
+	if session, ok := ctx.Value(sessionKey{}).(string); ok {
+		if r.sessionWrites.recentlyWrote(session, time.Now()) {
+			return nil
+		}
+	}
+
+	start := r.nextReplica.Add(1)
+	for i := range uint64(len(r.replicas)) {
+		if rep := r.replicas[(start+i)%uint64(len(r.replicas))]; !rep.unhealthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// Runs a read on a replica if there is one to read from, retrying it on the primary if the replica can't be reached.
+func (r *repository) read(ctx context.Context, fn func(db querier) error) error {
+	rep := r.replicaFor(ctx)
+	if rep == nil {
+		return fn(r.conn)
+	}
+
+	err := fn(rep.pool)
+	if err == nil || ctx.Err() != nil || !isUnavailable(err) {
+		return err
+	}
+	rep.markUnhealthy(err)
+	return fn(r.conn)
+}
+
+// Reports whether the error means the database couldn't be reached or can't take queries,
+// rather than that the query failed.
+func isUnavailable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) || pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	// Connection exceptions, and the database shutting down or starting up
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "08") || slices.Contains(unavailableCodes, pgErr.Code))
+}
+
+// admin_shutdown, crash_shutdown and cannot_connect_now
+var unavailableCodes = []string{"57P01", "57P02", "57P03"}
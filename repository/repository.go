@@ -6,42 +6,69 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/the-code-genin/golang_integration_testing/repository/internal/sqlc"
 )
 
 type repository struct {
+	// The primary, which every write goes to
 	conn *pgxpool.Pool
+
+	replicas    []*replica
+	nextReplica atomic.Uint64
+	// Sessions whose reads go to the primary, as they wrote recently
+	sessionWrites sessionWrites
 }
 
-func NewRepository(conn *pgxpool.Pool) Repository {
-	return &repository{conn}
+func NewRepository(conn *pgxpool.Pool, opts ...Option) Repository {
+	r := &repository{conn: conn, sessionWrites: sessionWrites{window: defaultReadYourWritesWindow}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
+// The most titles tried before giving up on renaming a note.
+const maxRenameAttempts = 100
+
+// The notes StreamNotes fetches at a time.
+const streamPageSize = 500
+
 func (r *repository) CreateNote(ctx context.Context, dto CreateNoteDTO) (*Note, error) {
+	ctx = r.wrote(ctx)
+
+	if dto.Format == "" {
+		dto.Format = NoteFormatPlain
+	}
+
+	switch dto.OnConflict {
+	case ConflictRename:
+		return r.createNoteRenamingOnConflict(ctx, dto)
+	case ConflictUpsert:
+		return r.upsertNote(ctx, dto)
+	case "", ConflictError:
+	default:
+		return nil, fmt.Errorf("unknown conflict strategy %q", dto.OnConflict)
+	}
+
 	// Generate an ID and timestamp for the note
 	id := uuid.New()
 	createdAt := time.Now()
 
 	// Insert the note into the database
-	_, err := r.conn.Exec(
-		ctx,
-		`INSERT INTO core.notes (id, title, description, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $4)`,
-		id.String(), dto.Title, dto.Description, createdAt,
-	)
+	err := queries.InsertNote(ctx, r.conn, sqlc.InsertNoteParams{
+		ID: id, Title: dto.Title, Description: dto.Description, Format: string(dto.Format), CreatedAt: createdAt, Owner: dto.Owner,
+	})
 	if err != nil {
 		// Return a custom error if a unique constraint was violated
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			return nil, fmt.Errorf("duplicate key error: %s", pgErr.ConstraintName)
-		}
-
-		return nil, err
+		return nil, r.duplicateKeyError(ctx, err, dto.Title, id)
 	}
 
 	// Return the created note
@@ -49,41 +76,92 @@ func (r *repository) CreateNote(ctx context.Context, dto CreateNoteDTO) (*Note,
 		ID:          id,
 		Title:       dto.Title,
 		Description: dto.Description,
+		Format:      dto.Format,
 		CreatedAt:   createdAt,
 		UpdatedAt:   &createdAt,
 	}, nil
 }
 
-func (r *repository) UpdateNote(ctx context.Context, id uuid.UUID, dto UpdateNoteDTO) (*Note, error) {
-	args := []any{id.String()}
-	setClauses := []string{}
+// Inserts the note under the first of its title, "title (2)", "title (3)"... that is free,
+// cutting the title short to fit the suffix. Every attempt is a single statement, so
+// concurrent creates can't take the same title. Returns a DuplicateKeyError if every
+// title tried is taken.
+func (r *repository) createNoteRenamingOnConflict(ctx context.Context, dto CreateNoteDTO) (*Note, error) {
+	id := uuid.New()
+	createdAt := time.Now()
+
+	for attempt := 1; attempt <= maxRenameAttempts; attempt++ {
+		title := dto.Title
+		if attempt > 1 {
+			title = renamedTitle(dto.Title, attempt)
+		}
+
+		inserted, err := queries.InsertNoteUnlessTitleTaken(ctx, r.conn, sqlc.InsertNoteUnlessTitleTakenParams{
+			ID: id, Title: title, Description: dto.Description, Format: string(dto.Format), CreatedAt: createdAt, Owner: dto.Owner,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if inserted == 0 {
+			continue
+		}
 
-	// Always update updated_at
-	args = append(args, time.Now())
-	setClauses = append(setClauses, fmt.Sprintf("updated_at = $%d", len(args)))
+		return &Note{
+			ID:          id,
+			Title:       title,
+			Description: dto.Description,
+			Format:      dto.Format,
+			CreatedAt:   createdAt,
+			UpdatedAt:   &createdAt,
+		}, nil
+	}
 
-	if dto.Title != nil {
-		args = append(args, *dto.Title)
-		setClauses = append(setClauses, fmt.Sprintf("title = $%d", len(args)))
+	err := r.titleTakenError(ctx, noteTitleConstraint, dto.Title, id)
+	return nil, fmt.Errorf("no free title found after %d attempts: %w", maxRenameAttempts, err)
+}
+
+// Suffixes the title with the attempt, e.g. "Groceries (2)", dropping as many characters
+// from the end of the title as it takes to keep it within MaxTitleLength.
+func renamedTitle(title string, attempt int) string {
+	suffix := fmt.Sprintf(" (%d)", attempt)
+	if keep := MaxTitleLength - utf8.RuneCountInString(suffix); utf8.RuneCountInString(title) > keep {
+		title = strings.TrimRightFunc(string([]rune(title)[:keep]), unicode.IsSpace)
 	}
+	return title + suffix
+}
 
-	if dto.Description != nil {
-		args = append(args, *dto.Description)
-		setClauses = append(setClauses, fmt.Sprintf("description = $%d", len(args)))
+// Inserts the note, or updates the description of the note already holding its title if
+// it has the same owner. Notes of other owners are never overwritten, a DuplicateKeyError
+// is returned instead.
+func (r *repository) upsertNote(ctx context.Context, dto CreateNoteDTO) (*Note, error) {
+	id := uuid.New()
+	row, err := queries.UpsertNote(ctx, r.conn, sqlc.UpsertNoteParams{
+		ID: id, Title: dto.Title, Description: dto.Description, Format: string(dto.Format), CreatedAt: time.Now(), Owner: dto.Owner,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, r.titleTakenError(ctx, noteTitleConstraint, dto.Title, id)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	note := noteOf(row)
+	// The note keeps its ID when updated
+	note.Updated = note.ID != id
+	return &note, nil
+}
 
-	_, err := r.conn.Exec(
-		ctx,
-		fmt.Sprintf(`UPDATE core.notes SET %s WHERE id = $1`, strings.Join(setClauses, ", ")),
-		args...,
-	)
+func (r *repository) UpdateNote(ctx context.Context, id uuid.UUID, dto UpdateNoteDTO) (*Note, error) {
+	ctx = r.wrote(ctx)
+
+	err := queries.UpdateNote(ctx, r.conn, sqlc.UpdateNoteParams{
+		ID: id, UpdatedAt: time.Now(), Title: dto.Title, Description: dto.Description, Format: (*string)(dto.Format),
+	})
 	if err != nil {
 		// Return a custom error if a unique constraint was violated
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			return nil, fmt.Errorf("duplicate key error: %s", pgErr.ConstraintName)
+		if dto.Title != nil {
+			return nil, r.duplicateKeyError(ctx, err, *dto.Title, id)
 		}
-
 		return nil, err
 	}
 
@@ -91,44 +169,73 @@ func (r *repository) UpdateNote(ctx context.Context, id uuid.UUID, dto UpdateNot
 }
 
 func (r *repository) DeleteNote(ctx context.Context, id uuid.UUID) error {
-	_, err := r.conn.Exec(ctx, `DELETE FROM core.notes WHERE id = $1`, id.String())
-	return err
+	ctx = r.wrote(ctx)
+
+	return queries.DeleteNote(ctx, r.conn, id)
 }
 
-func (r *repository) FetchNotes(ctx context.Context) ([]Note, error) {
-	rows, err := r.conn.Query(ctx, `
-		SELECT id, title, description, created_at, updated_at
-		FROM core.notes
-		ORDER BY created_at ASC
-	`)
+func (r *repository) FetchNotes(ctx context.Context, filter NoteFilter, fields ...NoteField) ([]Note, error) {
+	columns, err := noteColumns(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	where, orderBy, args, err := filter.compile(nil)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var notes []Note
-	for rows.Next() {
-		var note Note
-		var updatedAt time.Time
-		if err := rows.Scan(&note.ID, &note.Title, &note.Description, &note.CreatedAt, &updatedAt); err != nil {
-			return nil, err
+	err = r.read(ctx, func(db querier) error {
+		rows, err := fetchNotes(ctx, db, columns, where, orderBy, args...)
+		if err != nil {
+			return err
+		}
+
+		notes = make([]Note, len(rows))
+		for i, row := range rows {
+			notes[i] = noteOf(row).Project(fields...)
 		}
-		note.UpdatedAt = &updatedAt
-		notes = append(notes, note)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return notes, nil
 }
 
-func (r *repository) FetchNoteByID(ctx context.Context, id uuid.UUID) (*Note, error) {
-	var note Note
-	var updatedAt time.Time
+func (r *repository) FetchNotesVersion(ctx context.Context, filter NoteFilter) (*NotesVersion, error) {
+	// The order and pages don't change the version
+	filter.Sort, filter.After, filter.Limit = nil, nil, 0
+	where, _, args, err := filter.compile(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	var lastUpdatedAt *time.Time
+	err = r.read(ctx, func(db querier) (err error) {
+		count, lastUpdatedAt, err = fetchNotesVersion(ctx, db, where, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotesVersion{Count: int(count), LastUpdatedAt: lastUpdatedAt}, nil
+}
 
-	err := r.conn.QueryRow(ctx, `
-		SELECT id, title, description, created_at, updated_at
-		FROM core.notes
-		WHERE id = $1
-	`, id.String()).Scan(&note.ID, &note.Title, &note.Description, &note.CreatedAt, &updatedAt)
+func (r *repository) FetchNoteByID(ctx context.Context, id uuid.UUID, fields ...NoteField) (*Note, error) {
+	columns, err := noteColumns(fields)
+	if err != nil {
+		return nil, err
+	}
 
+	var row sqlc.CoreNote
+	err = r.read(ctx, func(db querier) (err error) {
+		row, err = fetchNoteByID(ctx, db, columns, id)
+		return err
+	})
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, sql.ErrNoRows
@@ -136,6 +243,63 @@ func (r *repository) FetchNoteByID(ctx context.Context, id uuid.UUID) (*Note, er
 		return nil, err
 	}
 
-	note.UpdatedAt = &updatedAt
+	note := noteOf(row).Project(fields...)
 	return &note, nil
 }
+
+func (r *repository) FetchNotesByIDs(ctx context.Context, ids []uuid.UUID) ([]Note, error) {
+	var notes []Note
+	err := r.read(ctx, func(db querier) error {
+		rows, err := queries.FetchNotesByIDs(ctx, db, ids)
+		if err != nil {
+			return err
+		}
+
+		notes = make([]Note, len(rows))
+		for i, row := range rows {
+			notes[i] = noteOf(row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// Pages through the notes with a keyset cursor, so every page is a range of the
+// (created_at, id) index and no transaction is held open between pages.
+func (r *repository) StreamNotes(ctx context.Context, fn func(Note) error) error {
+	var after sqlc.FetchNotesPageParams
+	after.PageSize = streamPageSize
+
+	for {
+		var rows []sqlc.CoreNote
+		err := r.read(ctx, func(db querier) error {
+			var err error
+			rows, err = queries.FetchNotesPage(ctx, db, after)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := fn(noteOf(row)); err != nil {
+				return err
+			}
+		}
+		if len(rows) < streamPageSize {
+			return nil
+		}
+
+		last := rows[len(rows)-1]
+		after.AfterCreatedAt, after.AfterID = last.CreatedAt, last.ID
+	}
+}
+
+func (r *repository) CountNotesByOwner(ctx context.Context, owner string) (int, error) {
+	count, err := queries.CountNotesByOwner(ctx, r.conn, owner)
+	return int(count), err
+}
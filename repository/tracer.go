@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type slowQueryLogger struct {
+	threshold time.Duration
+}
+
+// Logs queries taking longer than threshold with their name and duration, meant as a
+// pool's tracer. Queries returning rows are timed until the rows are closed.
+func NewSlowQueryLogger(threshold time.Duration) pgx.QueryTracer {
+	return &slowQueryLogger{threshold}
+}
+
+type queryStartKey struct{}
+
+// When a query started, and its SQL to name it by.
+type queryStart struct {
+	at  time.Time
+	sql string
+}
+
+func (l *slowQueryLogger) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{time.Now(), data.SQL})
+}
+
+func (l *slowQueryLogger) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(start.at)
+	if duration < l.threshold {
+		return
+	}
+	if data.Err != nil {
+		log.Printf("slow query %s failed after %s: %v", queryName(start.sql), duration, data.Err)
+		return
+	}
+	log.Printf("slow query %s took %s", queryName(start.sql), duration)
+}
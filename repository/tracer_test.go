@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+)
+
+func TestSlowQueryLogger(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	trace := func(threshold time.Duration, err error) {
+		tracer := repository.NewSlowQueryLogger(threshold)
+		ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+		time.Sleep(time.Millisecond)
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: err})
+	}
+
+	t.Run("should log queries taking longer than the threshold", func(t *testing.T) {
+		logs.Reset()
+		trace(time.Nanosecond, nil)
+		assert.Regexp(t, `slow query unnamed took \d`, logs.String())
+	})
+
+	t.Run("should log why slow queries failed", func(t *testing.T) {
+		logs.Reset()
+		trace(time.Nanosecond, errors.New("canceling statement due to statement timeout"))
+		assert.Regexp(t, `slow query unnamed failed after \d.*: canceling statement due to statement timeout`, logs.String())
+	})
+
+	t.Run("should not log queries faster than the threshold", func(t *testing.T) {
+		logs.Reset()
+		trace(time.Hour, nil)
+		assert.Empty(t, logs.String())
+	})
+}
@@ -10,6 +10,78 @@ type Note struct {
 	ID          uuid.UUID  `json:"id"`
 	Title       string     `json:"title"`
 	Description string     `json:"description"`
+	Format      NoteFormat `json:"format"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   *time.Time `json:"updated_at"`
+
+	// Set when CreateNote upserted the note, updating the note already holding its title
+	// instead of creating one
+	Updated bool `json:"-"`
+
+	// The fields the note was fetched with, nil if it has all of them
+	fields []NoteField
+}
+
+// The most characters a title can have.
+const MaxTitleLength = 255
+
+// How the description of a note is written.
+type NoteFormat string
+
+const (
+	NoteFormatPlain    NoteFormat = "plain"
+	NoteFormatMarkdown NoteFormat = "markdown"
+)
+
+var NoteFormats = []NoteFormat{NoteFormatPlain, NoteFormatMarkdown}
+
+// How CreateNote resolves a title already held by another note.
+type ConflictStrategy string
+
+const (
+	// Fail with a DuplicateKeyError
+	ConflictError ConflictStrategy = "error"
+	// Suffix the title with the first free number, e.g. "Groceries (2)"
+	ConflictRename ConflictStrategy = "rename"
+	// Update the description of the note holding the title
+	ConflictUpsert ConflictStrategy = "upsert"
+)
+
+var ConflictStrategies = []ConflictStrategy{ConflictError, ConflictRename, ConflictUpsert}
+
+const (
+	NoteEventCreated = "created"
+	NoteEventUpdated = "updated"
+	NoteEventDeleted = "deleted"
+)
+
+type NoteEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	NoteID    uuid.UUID `json:"note_id"`
+	Note      Note      `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	// The transaction that recorded the event, which events are ordered by before their IDs
+	TransactionID uint64 `json:"-"`
+}
+
+// Reports whether the event is ordered after other, i.e. if it was recorded by a later
+// transaction, or later by the same one.
+func (e NoteEvent) After(other NoteEvent) bool {
+	if e.TransactionID != other.TransactionID {
+		return e.TransactionID > other.TransactionID
+	}
+	return e.ID > other.ID
+}
+
+// Changes whenever a note matching a filter is created, updated or deleted.
+type NotesVersion struct {
+	Count         int
+	LastUpdatedAt *time.Time
+}
+
+// Notes sharing the same normalized title, oldest first.
+type TitleConflict struct {
+	NormalizedTitle string `json:"normalized_title"`
+	Notes           []Note `json:"notes"`
 }
@@ -1,9 +1,39 @@
 package service
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/the-code-genin/golang_integration_testing/repository"
+)
 
 var (
-	ErrInternal       = errors.New("an internal error occurred")
-	ErrNoteNotFound   = errors.New("no note was found with the ID")
-	ErrNoteTitleTaken = errors.New("an existing note was found with the title provided")
+	ErrInternal          = errors.New("an internal error occurred")
+	ErrNoteNotFound      = errors.New("no note was found with the ID")
+	ErrNoteTitleTaken    = errors.New("an existing note was found with the title provided")
+	ErrNoteQuotaExceeded = errors.New("the quota of notes has been reached")
 )
+
+// Returned when a note's title is taken by another note, matching ErrNoteTitleTaken.
+type TitleTakenError struct {
+	NoteID uuid.UUID
+}
+
+func (e *TitleTakenError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrNoteTitleTaken, e.NoteID)
+}
+
+func (e *TitleTakenError) Is(target error) bool {
+	return target == ErrNoteTitleTaken
+}
+
+// Maps a duplicate key error from the repository to the error describing it,
+// including the conflicting note if the repository found it.
+func titleTakenError(err error) error {
+	var dupErr *repository.DuplicateKeyError
+	if errors.As(err, &dupErr) && dupErr.ConflictingID != uuid.Nil {
+		return &TitleTakenError{NoteID: dupErr.ConflictingID}
+	}
+	return ErrNoteTitleTaken
+}
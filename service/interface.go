@@ -14,6 +14,15 @@ type Service interface {
 	UpdateNote(ctx context.Context, id uuid.UUID, dto repository.UpdateNoteDTO) (*repository.Note, error)
 	DeleteNote(ctx context.Context, id uuid.UUID) error
 
-	FetchNotes(ctx context.Context) ([]repository.Note, error)
-	FetchNoteByID(ctx context.Context, id uuid.UUID) (*repository.Note, error)
+	// Only fetch the fields given, or every field if none are
+	FetchNotes(ctx context.Context, filter repository.NoteFilter, fields ...repository.NoteField) ([]repository.Note, error)
+	FetchNoteByID(ctx context.Context, id uuid.UUID, fields ...repository.NoteField) (*repository.Note, error)
+	FetchNotesVersion(ctx context.Context, filter repository.NoteFilter) (*repository.NotesVersion, error)
+	FetchNotesByIDs(ctx context.Context, ids []uuid.UUID) ([]repository.Note, error)
+	// Calls fn for every note, oldest first, without holding them all in memory.
+	// Errors returned by fn are returned as they are.
+	StreamNotes(ctx context.Context, fn func(repository.Note) error) error
+
+	ListenNoteEvents(ctx context.Context, listening func(), fn func(repository.NoteEvent) error) error
+	FetchNoteEventsSince(ctx context.Context, afterID int64, limit int) ([]repository.NoteEvent, error)
 }
@@ -71,33 +71,116 @@ func (mr *MockServiceMockRecorder) DeleteNote(ctx, id any) *gomock.Call {
 }
 
 // FetchNoteByID mocks base method.
-func (m *MockService) FetchNoteByID(ctx context.Context, id uuid.UUID) (*repository.Note, error) {
+func (m *MockService) FetchNoteByID(ctx context.Context, id uuid.UUID, fields ...repository.NoteField) (*repository.Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FetchNoteByID", ctx, id)
+	varargs := []any{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FetchNoteByID", varargs...)
 	ret0, _ := ret[0].(*repository.Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FetchNoteByID indicates an expected call of FetchNoteByID.
-func (mr *MockServiceMockRecorder) FetchNoteByID(ctx, id any) *gomock.Call {
+func (mr *MockServiceMockRecorder) FetchNoteByID(ctx, id any, fields ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNoteByID", reflect.TypeOf((*MockService)(nil).FetchNoteByID), ctx, id)
+	varargs := append([]any{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNoteByID", reflect.TypeOf((*MockService)(nil).FetchNoteByID), varargs...)
+}
+
+// FetchNoteEventsSince mocks base method.
+func (m *MockService) FetchNoteEventsSince(ctx context.Context, afterID int64, limit int) ([]repository.NoteEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchNoteEventsSince", ctx, afterID, limit)
+	ret0, _ := ret[0].([]repository.NoteEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchNoteEventsSince indicates an expected call of FetchNoteEventsSince.
+func (mr *MockServiceMockRecorder) FetchNoteEventsSince(ctx, afterID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNoteEventsSince", reflect.TypeOf((*MockService)(nil).FetchNoteEventsSince), ctx, afterID, limit)
 }
 
 // FetchNotes mocks base method.
-func (m *MockService) FetchNotes(ctx context.Context) ([]repository.Note, error) {
+func (m *MockService) FetchNotes(ctx context.Context, filter repository.NoteFilter, fields ...repository.NoteField) ([]repository.Note, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FetchNotes", ctx)
+	varargs := []any{ctx, filter}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FetchNotes", varargs...)
 	ret0, _ := ret[0].([]repository.Note)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FetchNotes indicates an expected call of FetchNotes.
-func (mr *MockServiceMockRecorder) FetchNotes(ctx any) *gomock.Call {
+func (mr *MockServiceMockRecorder) FetchNotes(ctx, filter any, fields ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, filter}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotes", reflect.TypeOf((*MockService)(nil).FetchNotes), varargs...)
+}
+
+// FetchNotesByIDs mocks base method.
+func (m *MockService) FetchNotesByIDs(ctx context.Context, ids []uuid.UUID) ([]repository.Note, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchNotesByIDs", ctx, ids)
+	ret0, _ := ret[0].([]repository.Note)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchNotesByIDs indicates an expected call of FetchNotesByIDs.
+func (mr *MockServiceMockRecorder) FetchNotesByIDs(ctx, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotesByIDs", reflect.TypeOf((*MockService)(nil).FetchNotesByIDs), ctx, ids)
+}
+
+// FetchNotesVersion mocks base method.
+func (m *MockService) FetchNotesVersion(ctx context.Context, filter repository.NoteFilter) (*repository.NotesVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchNotesVersion", ctx, filter)
+	ret0, _ := ret[0].(*repository.NotesVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchNotesVersion indicates an expected call of FetchNotesVersion.
+func (mr *MockServiceMockRecorder) FetchNotesVersion(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotesVersion", reflect.TypeOf((*MockService)(nil).FetchNotesVersion), ctx, filter)
+}
+
+// ListenNoteEvents mocks base method.
+func (m *MockService) ListenNoteEvents(ctx context.Context, listening func(), fn func(repository.NoteEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenNoteEvents", ctx, listening, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListenNoteEvents indicates an expected call of ListenNoteEvents.
+func (mr *MockServiceMockRecorder) ListenNoteEvents(ctx, listening, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenNoteEvents", reflect.TypeOf((*MockService)(nil).ListenNoteEvents), ctx, listening, fn)
+}
+
+// StreamNotes mocks base method.
+func (m *MockService) StreamNotes(ctx context.Context, fn func(repository.Note) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamNotes", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamNotes indicates an expected call of StreamNotes.
+func (mr *MockServiceMockRecorder) StreamNotes(ctx, fn any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchNotes", reflect.TypeOf((*MockService)(nil).FetchNotes), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamNotes", reflect.TypeOf((*MockService)(nil).StreamNotes), ctx, fn)
 }
 
 // UpdateNote mocks base method.
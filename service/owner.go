@@ -0,0 +1,17 @@
+package service
+
+import "context"
+
+type ownerKey struct{}
+
+// Returns a context identifying the client making the request, whose notes are counted
+// against the note quota.
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+// Returns the client set by WithOwner, or an empty string if there is none.
+func OwnerFromContext(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerKey{}).(string)
+	return owner
+}
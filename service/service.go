@@ -13,21 +13,50 @@ import (
 
 type service struct {
 	repo repository.Repository
+
+	// The most notes a single owner can have, or 0 for no limit
+	noteQuota int
 }
 
-func NewService(repo repository.Repository) Service {
-	return &service{repo}
+// Configures optional behaviour of the service.
+type Option func(*service)
+
+// Limits the notes each owner can have, as identified by WithOwner. Notes created
+// without an owner aren't limited. Concurrent creates can briefly exceed the quota.
+func WithNoteQuota(quota int) Option {
+	return func(s *service) {
+		s.noteQuota = quota
+	}
+}
+
+func NewService(repo repository.Repository, opts ...Option) Service {
+	s := &service{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *service) CreateNote(
 	ctx context.Context, dto repository.CreateNoteDTO,
 ) (*repository.Note, error) {
+	dto, err := validateCreateNote(dto)
+	if err != nil {
+		log.Printf("invalid note: %v", err)
+		return nil, err
+	}
+
+	dto.Owner = OwnerFromContext(ctx)
+	if err := s.checkNoteQuota(ctx, dto.Owner); err != nil {
+		return nil, err
+	}
+
 	note, err := s.repo.CreateNote(ctx, dto)
 	if err != nil {
 		log.Printf("an error occurred while creating a note: %v", err)
 
 		if strings.Contains(err.Error(), "duplicate key error") {
-			return nil, ErrNoteTitleTaken
+			return nil, titleTakenError(err)
 		}
 
 		return nil, ErrInternal
@@ -35,18 +64,45 @@ func (s *service) CreateNote(
 	return note, nil
 }
 
+// Returns ErrNoteQuotaExceeded if the owner already has as many notes as they can.
+func (s *service) checkNoteQuota(ctx context.Context, owner string) error {
+	if s.noteQuota <= 0 || owner == "" {
+		return nil
+	}
+
+	count, err := s.repo.CountNotesByOwner(ctx, owner)
+	if err != nil {
+		log.Printf("an error occurred while counting the notes of %s: %v", owner, err)
+		return ErrInternal
+	}
+	if count >= s.noteQuota {
+		log.Printf("%s has reached the note quota of %d", owner, s.noteQuota)
+		return ErrNoteQuotaExceeded
+	}
+	return nil
+}
+
 func (s *service) UpdateNote(
 	ctx context.Context, id uuid.UUID, dto repository.UpdateNoteDTO,
 ) (*repository.Note, error) {
+	dto, err := validateUpdateNote(dto)
+	if err != nil {
+		log.Printf("invalid update to note with id %s: %v", id.String(), err)
+		return nil, err
+	}
+
 	note, err := s.repo.UpdateNote(ctx, id, dto)
 	if err != nil {
 		log.Printf("an error occurred while updating note with id %s: %v", id.String(), err)
 
-		if strings.Contains(err.Error(), "duplicate key error") {
-			return nil, ErrNoteTitleTaken
+		switch {
+		case strings.Contains(err.Error(), "duplicate key error"):
+			return nil, titleTakenError(err)
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoteNotFound
+		default:
+			return nil, ErrInternal
 		}
-
-		return nil, ErrInternal
 	}
 	return note, nil
 }
@@ -60,8 +116,15 @@ func (s *service) DeleteNote(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (s *service) FetchNotes(ctx context.Context) ([]repository.Note, error) {
-	notes, err := s.repo.FetchNotes(ctx)
+func (s *service) FetchNotes(
+	ctx context.Context, filter repository.NoteFilter, fields ...repository.NoteField,
+) ([]repository.Note, error) {
+	if err := validateNoteFilter(filter, fields); err != nil {
+		log.Printf("invalid note filter: %v", err)
+		return nil, err
+	}
+
+	notes, err := s.repo.FetchNotes(ctx, filter, fields...)
 	if err != nil {
 		log.Printf("an error occurred while fetching notes: %v", err)
 		return nil, ErrInternal
@@ -69,10 +132,31 @@ func (s *service) FetchNotes(ctx context.Context) ([]repository.Note, error) {
 	return notes, nil
 }
 
+func (s *service) FetchNotesVersion(
+	ctx context.Context, filter repository.NoteFilter,
+) (*repository.NotesVersion, error) {
+	if err := validateNoteFilter(filter, nil); err != nil {
+		log.Printf("invalid note filter: %v", err)
+		return nil, err
+	}
+
+	version, err := s.repo.FetchNotesVersion(ctx, filter)
+	if err != nil {
+		log.Printf("an error occurred while fetching the version of notes: %v", err)
+		return nil, ErrInternal
+	}
+	return version, nil
+}
+
 func (s *service) FetchNoteByID(
-	ctx context.Context, id uuid.UUID,
+	ctx context.Context, id uuid.UUID, fields ...repository.NoteField,
 ) (*repository.Note, error) {
-	note, err := s.repo.FetchNoteByID(ctx, id)
+	if err := validateNoteFields(fields); err != nil {
+		log.Printf("invalid note fields: %v", err)
+		return nil, err
+	}
+
+	note, err := s.repo.FetchNoteByID(ctx, id, fields...)
 	if err != nil {
 		log.Printf("an error occurred while fetching note with id %s: %v", id.String(), err)
 
@@ -85,3 +169,54 @@ func (s *service) FetchNoteByID(
 	}
 	return note, nil
 }
+
+func (s *service) FetchNotesByIDs(
+	ctx context.Context, ids []uuid.UUID,
+) ([]repository.Note, error) {
+	notes, err := s.repo.FetchNotesByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("an error occurred while fetching notes with ids %v: %v", ids, err)
+		return nil, ErrInternal
+	}
+	return notes, nil
+}
+
+func (s *service) StreamNotes(
+	ctx context.Context, fn func(repository.Note) error,
+) error {
+	var fnErr error
+	err := s.repo.StreamNotes(ctx, func(note repository.Note) error {
+		fnErr = fn(note)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		log.Printf("an error occurred while streaming notes: %v", err)
+		return ErrInternal
+	}
+	return nil
+}
+
+func (s *service) ListenNoteEvents(
+	ctx context.Context, listening func(), fn func(repository.NoteEvent) error,
+) error {
+	err := s.repo.ListenNoteEvents(ctx, listening, fn)
+	if err != nil {
+		log.Printf("an error occurred while listening for note events: %v", err)
+		return ErrInternal
+	}
+	return nil
+}
+
+func (s *service) FetchNoteEventsSince(
+	ctx context.Context, afterID int64, limit int,
+) ([]repository.NoteEvent, error) {
+	events, err := s.repo.FetchNoteEventsSince(ctx, afterID, limit)
+	if err != nil {
+		log.Printf("an error occurred while fetching note events after id %d: %v", afterID, err)
+		return nil, ErrInternal
+	}
+	return events, nil
+}
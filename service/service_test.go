@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/google/uuid"
@@ -67,6 +70,33 @@ func TestService(t *testing.T) {
 			assert.Nil(t, note)
 		})
 
+		t.Run("should include the ID of the note holding the title if the DBAL found it", func(t *testing.T) {
+			t.Parallel()
+
+			dto := repository.CreateNoteDTO{
+				Title:       gofakeit.Sentence(3),
+				Description: gofakeit.Sentence(10),
+			}
+			conflictingID := uuid.New()
+
+			mockRepo.EXPECT().
+				CreateNote(gomock.Any(), dto).
+				Return(nil, &repository.DuplicateKeyError{
+					Constraint:    "notes_unique_normalized_title_index",
+					ConflictingID: conflictingID,
+				})
+
+			note, err := service.CreateNote(ctx, dto)
+			assert.Nil(t, note)
+			assert.ErrorIs(t, err, ErrNoteTitleTaken)
+
+			var titleTaken *TitleTakenError
+			if assert.ErrorAs(t, err, &titleTaken) {
+				assert.Equal(t, conflictingID, titleTaken.NoteID)
+			}
+			assert.Contains(t, err.Error(), conflictingID.String())
+		})
+
 		t.Run("should return ErrInternal if an unknown error occurred while creating the note", func(t *testing.T) {
 			t.Parallel()
 
@@ -87,6 +117,169 @@ func TestService(t *testing.T) {
 		})
 	})
 
+	t.Run("CreateNote with a note quota", func(t *testing.T) {
+		mockRepo := repository.NewMockRepository(gomock.NewController(t))
+		service := NewService(mockRepo, WithNoteQuota(2))
+		ctx := WithOwner(ctx, "key:abc")
+
+		dto := repository.CreateNoteDTO{
+			Title:       gofakeit.Sentence(3),
+			Description: gofakeit.Sentence(10),
+		}
+		owned := dto
+		owned.Owner = "key:abc"
+
+		t.Run("should create notes for owners under the quota", func(t *testing.T) {
+			expectedNote := &repository.Note{ID: uuid.New(), Title: dto.Title, Description: dto.Description}
+			mockRepo.EXPECT().CountNotesByOwner(gomock.Any(), "key:abc").Return(1, nil)
+			mockRepo.EXPECT().CreateNote(gomock.Any(), owned).Return(expectedNote, nil)
+
+			note, err := service.CreateNote(ctx, dto)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNote, note)
+		})
+
+		t.Run("should return ErrNoteQuotaExceeded for owners at the quota", func(t *testing.T) {
+			mockRepo.EXPECT().CountNotesByOwner(gomock.Any(), "key:abc").Return(2, nil)
+
+			note, err := service.CreateNote(ctx, dto)
+			assert.ErrorIs(t, err, ErrNoteQuotaExceeded)
+			assert.Nil(t, note)
+		})
+
+		t.Run("should return ErrInternal if the notes can't be counted", func(t *testing.T) {
+			mockRepo.EXPECT().CountNotesByOwner(gomock.Any(), "key:abc").Return(0, assert.AnError)
+
+			_, err := service.CreateNote(ctx, dto)
+			assert.Equal(t, ErrInternal, err)
+		})
+
+		t.Run("should not limit notes without an owner", func(t *testing.T) {
+			mockRepo.EXPECT().CreateNote(gomock.Any(), dto).Return(&repository.Note{}, nil)
+
+			_, err := service.CreateNote(context.Background(), dto)
+			assert.NoError(t, err)
+		})
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		t.Run("should normalize titles before creating a note", func(t *testing.T) {
+			t.Parallel()
+
+			// "Café" with the accent as a combining character
+			dto := repository.CreateNoteDTO{Title: "  Cafe\u0301  ", Description: gofakeit.Sentence(10)}
+			normalized := repository.CreateNoteDTO{Title: "Caf\u00e9", Description: dto.Description}
+
+			expectedNote := &repository.Note{ID: uuid.New(), Title: normalized.Title, Description: dto.Description}
+			mockRepo.EXPECT().CreateNote(gomock.Any(), normalized).Return(expectedNote, nil)
+
+			note, err := service.CreateNote(ctx, dto)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNote, note)
+		})
+
+		t.Run("should replace compatibility characters in titles", func(t *testing.T) {
+			t.Parallel()
+
+			// "file" with the "fi" ligature, and "１２" in fullwidth digits
+			dto := repository.CreateNoteDTO{Title: "\ufb01le \uff11\uff12", Description: gofakeit.Sentence(10)}
+			normalized := repository.CreateNoteDTO{Title: "file 12", Description: dto.Description}
+
+			expectedNote := &repository.Note{ID: uuid.New(), Title: normalized.Title, Description: dto.Description}
+			mockRepo.EXPECT().CreateNote(gomock.Any(), normalized).Return(expectedNote, nil)
+
+			note, err := service.CreateNote(ctx, dto)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNote, note)
+		})
+
+		t.Run("should return a ValidationError listing every violation", func(t *testing.T) {
+			t.Parallel()
+
+			dto := repository.CreateNoteDTO{Title: "   ", Description: strings.Repeat("a", MaxDescriptionLength+1)}
+
+			note, err := service.CreateNote(ctx, dto)
+			assert.Nil(t, note)
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, []Violation{
+					{Field: "title", Message: "must not be empty"},
+					{Field: "description", Message: fmt.Sprintf("must be at most %d characters long", MaxDescriptionLength)},
+				}, validationErr.Violations)
+			}
+		})
+
+		t.Run("should reject control characters", func(t *testing.T) {
+			t.Parallel()
+
+			dto := repository.CreateNoteDTO{Title: "Bell\a", Description: "Line one\nLine two\x00"}
+
+			_, err := service.CreateNote(ctx, dto)
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, []string{"title", "description"}, []string{
+					validationErr.Violations[0].Field, validationErr.Violations[1].Field,
+				})
+			}
+		})
+
+		t.Run("should reject unknown conflict strategies", func(t *testing.T) {
+			t.Parallel()
+
+			dto := repository.CreateNoteDTO{Title: gofakeit.Sentence(3), Description: gofakeit.Sentence(10), OnConflict: "ignore"}
+
+			_, err := service.CreateNote(ctx, dto)
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, []Violation{{Field: "on_conflict", Message: "must be one of error, rename, upsert"}}, validationErr.Violations)
+			}
+		})
+
+		t.Run("should reject unknown description formats", func(t *testing.T) {
+			t.Parallel()
+
+			format := repository.NoteFormat("html")
+			note, err := service.UpdateNote(ctx, uuid.New(), repository.UpdateNoteDTO{Format: &format})
+			assert.Nil(t, note)
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, []Violation{{Field: "format", Message: "must be one of plain, markdown"}}, validationErr.Violations)
+			}
+		})
+
+		t.Run("should reject an update setting the title to an empty string", func(t *testing.T) {
+			t.Parallel()
+
+			title := ""
+			note, err := service.UpdateNote(ctx, uuid.New(), repository.UpdateNoteDTO{Title: &title})
+			assert.Nil(t, note)
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, []Violation{{Field: "title", Message: "must not be empty"}}, validationErr.Violations)
+			}
+		})
+
+		t.Run("should allow an update leaving fields unset", func(t *testing.T) {
+			t.Parallel()
+
+			id := uuid.New()
+			desc := "Tabs\tand\r\nline breaks are fine"
+			dto := repository.UpdateNoteDTO{Description: &desc}
+
+			expectedNote := &repository.Note{ID: id, Description: desc}
+			mockRepo.EXPECT().UpdateNote(gomock.Any(), id, dto).Return(expectedNote, nil)
+
+			note, err := service.UpdateNote(ctx, id, dto)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNote, note)
+		})
+	})
+
 	t.Run("FetchNoteByID", func(t *testing.T) {
 		id := uuid.New()
 
@@ -123,6 +316,30 @@ func TestService(t *testing.T) {
 		})
 	})
 
+	t.Run("FetchNoteByID with fields", func(t *testing.T) {
+		t.Run("should pass the fields to the repository", func(t *testing.T) {
+			id := uuid.New()
+			expectedNote := repository.Note{ID: id, Title: gofakeit.Sentence(3)}.Project(repository.NoteFieldID, repository.NoteFieldTitle)
+
+			mockRepo.EXPECT().
+				FetchNoteByID(gomock.Any(), id, repository.NoteFieldID, repository.NoteFieldTitle).
+				Return(&expectedNote, nil)
+
+			note, err := service.FetchNoteByID(ctx, id, repository.NoteFieldID, repository.NoteFieldTitle)
+			assert.NoError(t, err)
+			assert.Equal(t, &expectedNote, note)
+		})
+
+		t.Run("should return a ValidationError for unknown fields", func(t *testing.T) {
+			_, err := service.FetchNoteByID(ctx, uuid.New(), "body")
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, "fields", validationErr.Violations[0].Field)
+			}
+		})
+	})
+
 	t.Run("UpdateNote", func(t *testing.T) {
 		id := uuid.New()
 		title := gofakeit.Sentence(3)
@@ -147,6 +364,14 @@ func TestService(t *testing.T) {
 			assert.Nil(t, note)
 		})
 
+		t.Run("should return ErrNoteNotFound if the note doesn't exist", func(t *testing.T) {
+			mockRepo.EXPECT().UpdateNote(gomock.Any(), id, dto).Return(nil, sql.ErrNoRows)
+
+			note, err := service.UpdateNote(ctx, id, dto)
+			assert.Equal(t, ErrNoteNotFound, err)
+			assert.Nil(t, note)
+		})
+
 		t.Run("should return ErrInternal for unknown errors", func(t *testing.T) {
 			mockRepo.EXPECT().UpdateNote(gomock.Any(), id, dto).Return(nil, assert.AnError)
 
@@ -183,20 +408,181 @@ func TestService(t *testing.T) {
 		}
 
 		t.Run("should fetch all notes successfully", func(t *testing.T) {
-			mockRepo.EXPECT().FetchNotes(gomock.Any()).Return(expectedNotes, nil)
+			mockRepo.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{}).Return(expectedNotes, nil)
 
-			notes, err := service.FetchNotes(ctx)
+			notes, err := service.FetchNotes(ctx, repository.NoteFilter{})
 			assert.NoError(t, err)
 			assert.Equal(t, expectedNotes, notes)
 		})
 
 		t.Run("should return ErrInternal for repository errors", func(t *testing.T) {
-			mockRepo.EXPECT().FetchNotes(gomock.Any()).Return(nil, assert.AnError)
+			mockRepo.EXPECT().FetchNotes(gomock.Any(), repository.NoteFilter{}).Return(nil, assert.AnError)
 
-			notes, err := service.FetchNotes(ctx)
+			notes, err := service.FetchNotes(ctx, repository.NoteFilter{})
 			assert.Error(t, err)
 			assert.Equal(t, ErrInternal, err)
 			assert.Nil(t, notes)
 		})
+		t.Run("should pass the filter to the repository", func(t *testing.T) {
+			filter := repository.NoteFilter{
+				TitlePrefix: "groceries",
+				Sort:        []repository.NoteSort{{Field: "updated_at", Descending: true}},
+			}
+			mockRepo.EXPECT().FetchNotes(gomock.Any(), filter).Return(expectedNotes, nil)
+
+			notes, err := service.FetchNotes(ctx, filter)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNotes, notes)
+		})
+
+		t.Run("should return a ValidationError for empty time ranges, negative limits and unknown sort fields", func(t *testing.T) {
+			now := time.Now()
+			filter := repository.NoteFilter{
+				CreatedAfter:  &now,
+				CreatedBefore: &now,
+				Sort:          []repository.NoteSort{{Field: "description"}},
+				Limit:         -1,
+			}
+
+			_, err := service.FetchNotes(ctx, filter)
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, []Violation{
+					{Field: "created_before", Message: "must be after created_after"},
+					{Field: "limit", Message: "must not be negative"},
+					{Field: "sort", Message: "must only contain the fields created_at, title, updated_at"},
+				}, validationErr.Violations)
+			}
+		})
+	})
+	t.Run("StreamNotes", func(t *testing.T) {
+		expectedNotes := []repository.Note{
+			{ID: uuid.New(), Title: gofakeit.Sentence(3), Description: gofakeit.Sentence(10)},
+			{ID: uuid.New(), Title: gofakeit.Sentence(3), Description: gofakeit.Sentence(10)},
+		}
+		streamNotes := func(err error) {
+			mockRepo.EXPECT().
+				StreamNotes(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, fn func(repository.Note) error) error {
+					for _, note := range expectedNotes {
+						if err := fn(note); err != nil {
+							return err
+						}
+					}
+					return err
+				})
+		}
+
+		t.Run("should pass every note to the callback", func(t *testing.T) {
+			streamNotes(nil)
+
+			var notes []repository.Note
+			err := service.StreamNotes(ctx, func(note repository.Note) error {
+				notes = append(notes, note)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNotes, notes)
+		})
+
+		t.Run("should return errors of the callback as is", func(t *testing.T) {
+			streamNotes(nil)
+
+			err := service.StreamNotes(ctx, func(repository.Note) error { return assert.AnError })
+			assert.Equal(t, assert.AnError, err)
+		})
+
+		t.Run("should return ErrInternal for repository errors", func(t *testing.T) {
+			streamNotes(assert.AnError)
+
+			err := service.StreamNotes(ctx, func(repository.Note) error { return nil })
+			assert.Equal(t, ErrInternal, err)
+		})
+	})
+	t.Run("FetchNotesVersion", func(t *testing.T) {
+		t.Run("should fetch the version of the notes matching the filter", func(t *testing.T) {
+			now := time.Now()
+			filter := repository.NoteFilter{TitlePrefix: "groceries"}
+			expected := &repository.NotesVersion{Count: 2, LastUpdatedAt: &now}
+			mockRepo.EXPECT().FetchNotesVersion(gomock.Any(), filter).Return(expected, nil)
+
+			version, err := service.FetchNotesVersion(ctx, filter)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, version)
+		})
+
+		t.Run("should return ErrInternal for repository errors", func(t *testing.T) {
+			mockRepo.EXPECT().FetchNotesVersion(gomock.Any(), repository.NoteFilter{}).Return(nil, assert.AnError)
+
+			version, err := service.FetchNotesVersion(ctx, repository.NoteFilter{})
+			assert.Equal(t, ErrInternal, err)
+			assert.Nil(t, version)
+		})
+
+		t.Run("should return a ValidationError for invalid filters", func(t *testing.T) {
+			_, err := service.FetchNotesVersion(ctx, repository.NoteFilter{Sort: []repository.NoteSort{{Field: "description"}}})
+
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+		})
+	})
+	t.Run("FetchNotesByIDs", func(t *testing.T) {
+		ids := []uuid.UUID{uuid.New(), uuid.New()}
+		expectedNotes := []repository.Note{
+			{ID: ids[0], Title: gofakeit.Sentence(3), Description: gofakeit.Sentence(10)},
+			{ID: ids[1], Title: gofakeit.Sentence(3), Description: gofakeit.Sentence(10)},
+		}
+
+		t.Run("should fetch the notes with the given IDs", func(t *testing.T) {
+			mockRepo.EXPECT().FetchNotesByIDs(gomock.Any(), ids).Return(expectedNotes, nil)
+
+			notes, err := service.FetchNotesByIDs(ctx, ids)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedNotes, notes)
+		})
+
+		t.Run("should return ErrInternal for repository errors", func(t *testing.T) {
+			mockRepo.EXPECT().FetchNotesByIDs(gomock.Any(), ids).Return(nil, assert.AnError)
+
+			notes, err := service.FetchNotesByIDs(ctx, ids)
+			assert.Error(t, err)
+			assert.Equal(t, ErrInternal, err)
+			assert.Nil(t, notes)
+		})
+	})
+
+	t.Run("FetchNoteEventsSince", func(t *testing.T) {
+		expectedEvents := []repository.NoteEvent{
+			{ID: 2, Type: repository.NoteEventCreated, NoteID: uuid.New()},
+			{ID: 3, Type: repository.NoteEventDeleted, NoteID: uuid.New()},
+		}
+
+		t.Run("should fetch the events after the given ID", func(t *testing.T) {
+			mockRepo.EXPECT().FetchNoteEventsSince(gomock.Any(), int64(1), 100).Return(expectedEvents, nil)
+
+			events, err := service.FetchNoteEventsSince(ctx, 1, 100)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedEvents, events)
+		})
+
+		t.Run("should return ErrInternal for repository errors", func(t *testing.T) {
+			mockRepo.EXPECT().FetchNoteEventsSince(gomock.Any(), int64(1), 100).Return(nil, assert.AnError)
+
+			events, err := service.FetchNoteEventsSince(ctx, 1, 100)
+			assert.Error(t, err)
+			assert.Equal(t, ErrInternal, err)
+			assert.Nil(t, events)
+		})
+	})
+
+	t.Run("ListenNoteEvents", func(t *testing.T) {
+		t.Run("should return ErrInternal if the listener fails", func(t *testing.T) {
+			mockRepo.EXPECT().ListenNoteEvents(gomock.Any(), gomock.Any(), gomock.Any()).Return(assert.AnError)
+
+			err := service.ListenNoteEvents(ctx, func() {}, func(repository.NoteEvent) error { return nil })
+			assert.Error(t, err)
+			assert.Equal(t, ErrInternal, err)
+		})
 	})
 }
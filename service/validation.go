@@ -0,0 +1,193 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/the-code-genin/golang_integration_testing/repository"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MaxTitleLength       = repository.MaxTitleLength
+	MaxDescriptionLength = 65535
+)
+
+// A single rule broken by a field of a note.
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Returned when a note breaks one or more validation rules.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Field + " " + violation.Message
+	}
+	return "invalid note: " + strings.Join(messages, "; ")
+}
+
+type validator struct {
+	violations []Violation
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.violations = append(v.violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{v.violations}
+}
+
+// Trims and normalizes a title to NFKC, as core.normalize_title does before comparing
+// titles, so titles that only differ in how their characters are encoded, or in using
+// compatibility characters such as ligatures, are stored the same way.
+func normalizeTitle(title string) string {
+	return norm.NFKC.String(strings.TrimSpace(title))
+}
+
+func (v *validator) title(title string) {
+	switch {
+	case title == "":
+		v.add("title", "must not be empty")
+	case utf8.RuneCountInString(title) > MaxTitleLength:
+		v.add("title", "must be at most %d characters long", MaxTitleLength)
+	case strings.ContainsFunc(title, unicode.IsControl):
+		v.add("title", "must not contain control characters")
+	}
+}
+
+func (v *validator) description(description string) {
+	switch {
+	case description == "":
+		v.add("description", "must not be empty")
+	case utf8.RuneCountInString(description) > MaxDescriptionLength:
+		v.add("description", "must be at most %d characters long", MaxDescriptionLength)
+	case strings.ContainsFunc(description, isForbiddenInDescription):
+		v.add("description", "must not contain control characters other than tabs and line breaks")
+	}
+}
+
+func (v *validator) conflictStrategy(strategy repository.ConflictStrategy) {
+	if strategy != "" && !slices.Contains(repository.ConflictStrategies, strategy) {
+		names := make([]string, len(repository.ConflictStrategies))
+		for i, strategy := range repository.ConflictStrategies {
+			names[i] = string(strategy)
+		}
+		v.add("on_conflict", "must be one of %s", strings.Join(names, ", "))
+	}
+}
+
+func (v *validator) format(format repository.NoteFormat) {
+	if format != "" && !slices.Contains(repository.NoteFormats, format) {
+		names := make([]string, len(repository.NoteFormats))
+		for i, format := range repository.NoteFormats {
+			names[i] = string(format)
+		}
+		v.add("format", "must be one of %s", strings.Join(names, ", "))
+	}
+}
+
+func isForbiddenInDescription(r rune) bool {
+	return unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r'
+}
+
+// Validates a note to be created, returning it with its title normalized.
+func validateCreateNote(dto repository.CreateNoteDTO) (repository.CreateNoteDTO, error) {
+	var v validator
+
+	dto.Title = normalizeTitle(dto.Title)
+	v.title(dto.Title)
+	v.description(dto.Description)
+	v.format(dto.Format)
+	v.conflictStrategy(dto.OnConflict)
+
+	return dto, v.err()
+}
+
+// Validates the fields set on a note update, returning it with its title normalized.
+func validateUpdateNote(dto repository.UpdateNoteDTO) (repository.UpdateNoteDTO, error) {
+	var v validator
+
+	if dto.Title != nil {
+		title := normalizeTitle(*dto.Title)
+		dto.Title = &title
+		v.title(title)
+	}
+	if dto.Description != nil {
+		v.description(*dto.Description)
+	}
+	if dto.Format != nil {
+		v.format(*dto.Format)
+	}
+
+	return dto, v.err()
+}
+
+// Validates a description on its own, for callers building one up before updating the note.
+func ValidateDescription(description string) error {
+	var v validator
+	v.description(description)
+	return v.err()
+}
+
+// Validates the sort fields and time ranges of a note filter, and the fields to fetch.
+func validateNoteFilter(filter repository.NoteFilter, fields []repository.NoteField) error {
+	var v validator
+
+	v.fields(fields)
+	v.timeRange("created", filter.CreatedAfter, filter.CreatedBefore)
+	v.timeRange("updated", filter.UpdatedAfter, filter.UpdatedBefore)
+
+	if filter.Limit < 0 {
+		v.add("limit", "must not be negative")
+	}
+
+	sortable := repository.SortableNoteFields()
+	for _, sort := range filter.Sort {
+		if !slices.Contains(sortable, sort.Field) {
+			v.add("sort", "must only contain the fields %s", strings.Join(sortable, ", "))
+			break
+		}
+	}
+
+	return v.err()
+}
+
+func (v *validator) timeRange(field string, after, before *time.Time) {
+	if after != nil && before != nil && !after.Before(*before) {
+		v.add(field+"_before", "must be after %s_after", field)
+	}
+}
+
+// Validates the fields of the notes to fetch.
+func validateNoteFields(fields []repository.NoteField) error {
+	var v validator
+	v.fields(fields)
+	return v.err()
+}
+
+func (v *validator) fields(fields []repository.NoteField) {
+	for _, field := range fields {
+		if !slices.Contains(repository.NoteFields, field) {
+			names := make([]string, len(repository.NoteFields))
+			for i, field := range repository.NoteFields {
+				names[i] = string(field)
+			}
+			v.add("fields", "must only contain the fields %s", strings.Join(names, ", "))
+			return
+		}
+	}
+}